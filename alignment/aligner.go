@@ -0,0 +1,140 @@
+package alignment
+
+import (
+	"context"
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// An Aligner aligns queries against one reference with one score
+// handler. It keeps the buffers of the dynamic programming between
+// queries and only grows them, so that once it has aligned the longest
+// query, aligning another one allocates little more than its report.
+//
+// An Aligner isn't safe for concurrent use; each goroutine should have
+// its own.
+type Aligner struct {
+	aSeq         []a.AminoAcid
+	scoreHandler *handler
+	limits       Limits
+	workspace    workspace
+}
+
+func NewAligner(aSeq []a.AminoAcid, scoreHandler s.ScoreHandler) *Aligner {
+	return &Aligner{aSeq: aSeq, scoreHandler: newHandler(scoreHandler, len(aSeq))}
+}
+
+// SetLimits sets the limits applied to each alignment of the aligner.
+func (self *Aligner) SetLimits(limits Limits) {
+	self.limits = limits
+}
+
+// Align aligns nSeq against the reference and returns the report.
+func (self *Aligner) Align(nSeq []n.NucleicAcid) (*AlignmentReport, error) {
+	return self.AlignContext(context.Background(), nSeq)
+}
+
+// AlignContext works like Align, but gives up once ctx is done or the
+// alignment exceeds the limits of the aligner (see NewAlignmentContext).
+func (self *Aligner) AlignContext(ctx context.Context, nSeq []n.NucleicAcid) (*AlignmentReport, error) {
+	result, err := self.NewAlignmentContext(ctx, nSeq)
+	if err != nil {
+		return nil, err
+	}
+	return result.GetReport(), nil
+}
+
+// NewAlignment works like the function of the same name, using the
+// buffers and the limits of the aligner. It can serve as an AlignFunc.
+func (self *Aligner) NewAlignment(nSeq []n.NucleicAcid) (*Alignment, error) {
+	return self.NewAlignmentContext(context.Background(), nSeq)
+}
+
+// NewAlignmentContext works like the function of the same name, using
+// the buffers and the limits of the aligner.
+func (self *Aligner) NewAlignmentContext(ctx context.Context, nSeq []n.NucleicAcid) (*Alignment, error) {
+	return newAlignment(ctx, nSeq, self.aSeq, self.scoreHandler, self.limits, &self.workspace)
+}
+
+// NewAlignmentBothStrands works like the function of the same name,
+// using the buffers of the aligner.
+func (self *Aligner) NewAlignmentBothStrands(nSeq []n.NucleicAcid) (*Alignment, error) {
+	return alignBothStrands(nSeq, self.NewAlignment)
+}
+
+// The rows of scores a workspace holds for the forward and the
+// backward passes. The backward pass only uses the first four.
+const (
+	gScoresRow = iota
+	dScoresRow
+	gScoresCurRow
+	dScoresCurRow
+	simplesCountRow
+	simplesCountCurRow
+	iScoresRow
+	iScoresCurRow
+	codonScoresRow
+	workspaceRowCount
+)
+
+// A workspace holds the buffers used while aligning a query: the rows
+// of the forward and backward passes and their edges, the traceback
+// matrix and the scores of the codons of the query at positions where
+// the handler has no table of them.
+type workspace struct {
+	rows        [workspaceRowCount][]int
+	moveRows    [scoreTypeCount][]tMove
+	edges       tRowEdges
+	moves       []tMove
+	codonScores *s.CodonScores
+}
+
+// getRow returns the row of the given kind with length cells, all zero.
+func (self *workspace) getRow(row int, length int) []int {
+	if cap(self.rows[row]) < length {
+		self.rows[row] = make([]int, length)
+		return self.rows[row]
+	}
+	result := self.rows[row][:length]
+	for idx := range result {
+		result[idx] = 0
+	}
+	return result
+}
+
+// getMoveRow returns the row of moves of the given score type with
+// length cells, all moveOrigin.
+func (self *workspace) getMoveRow(scoreType tScoreType, length int) []tMove {
+	if cap(self.moveRows[scoreType]) < length {
+		self.moveRows[scoreType] = make([]tMove, length)
+		return self.moveRows[scoreType]
+	}
+	result := self.moveRows[scoreType][:length]
+	for idx := range result {
+		result[idx] = moveOrigin
+	}
+	return result
+}
+
+// getCodonScores returns the table of codon scores of the workspace.
+// Only the codons of the query need to be scored in it.
+func (self *workspace) getCodonScores() *s.CodonScores {
+	if self.codonScores == nil {
+		self.codonScores = new(s.CodonScores)
+	}
+	return self.codonScores
+}
+
+// getMoves returns a traceback matrix of length cells, all moveOrigin.
+func (self *workspace) getMoves(length int) []tMove {
+	if cap(self.moves) < length {
+		self.moves = make([]tMove, length)
+		return self.moves
+	}
+	result := self.moves[:length]
+	for idx := range result {
+		result[idx] = moveOrigin
+	}
+	return result
+}
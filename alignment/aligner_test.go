@@ -0,0 +1,87 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestAligner(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligner := NewAligner(ASEQ, handler)
+	// align every sequence twice, so that the buffers are reused by
+	// both longer and shorter queries
+	for round := 0; round < 2; round++ {
+		for _, seq := range linearTestSeqs {
+			nseq := n.ReadString(seq)
+			expect, _ := NewAlignment(nseq, ASEQ, handler)
+			report, err := aligner.Align(nseq)
+			if err != nil {
+				t.Errorf("Unexpected error for %v: %v", seq, err)
+				continue
+			}
+			if !reflect.DeepEqual(expect.GetReport(), report) {
+				t.Errorf(MSG_NOT_EQUAL, expect.GetReport(), report)
+			}
+		}
+	}
+}
+
+func TestAlignerRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	aseq := randomAminoAcids(rnd, 150)
+	for _, mode := range allAlignmentModes {
+		handler := handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode })
+		aligner := NewAligner(aseq, handler)
+		for round := 0; round < 5; round++ {
+			nseq := randomQuery(rnd, aseq[rnd.Intn(50):])
+			expect, _ := NewAlignment(nseq, aseq, handler)
+			report, _ := aligner.Align(nseq)
+			if !reflect.DeepEqual(expect.GetReport(), report) {
+				t.Errorf(MSG_NOT_EQUAL, expect.GetReport(), report)
+			}
+		}
+	}
+}
+
+func TestAlignerMisaligned(t *testing.T) {
+	aseq := a.ReadString("GGGGGGGGGGGGGGGGGG")
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	report, err := NewAligner(aseq, handler).Align(n.ReadString("AAAAAAAAAAAAAAAAAA"))
+	if report != nil || err == nil {
+		t.Errorf("Expected the query to be misaligned")
+	}
+}
+
+func TestAlignerSharedHandler(t *testing.T) {
+	// the workers of the command line share the handler of a gene, each
+	// with its own aligner
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	reports := make([][]*AlignmentReport, 4)
+	var wg sync.WaitGroup
+	for worker := range reports {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			aligner := NewAligner(ASEQ, handler)
+			for _, seq := range linearTestSeqs {
+				report, _ := aligner.Align(n.ReadString(seq))
+				reports[worker] = append(reports[worker], report)
+			}
+		}(worker)
+	}
+	wg.Wait()
+	for idx, seq := range linearTestSeqs {
+		expect, _ := NewAlignment(n.ReadString(seq), ASEQ, handler)
+		for _, workerReports := range reports {
+			if report := workerReports[idx]; !reflect.DeepEqual(expect.GetReport(), report) {
+				t.Errorf(MSG_NOT_EQUAL, expect.GetReport(), report)
+			}
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package alignment
 
 import (
+	"context"
 	"errors"
-	h "github.com/hivdb/nucamino/scorehandler/general"
+	s "github.com/hivdb/nucamino/scorehandler"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
 	f "github.com/hivdb/nucamino/types/frameshift"
 	m "github.com/hivdb/nucamino/types/mutation"
 	n "github.com/hivdb/nucamino/types/nucleic"
@@ -27,20 +29,68 @@ type AlignedSite struct {
 	PosAA    int
 	PosNA    int
 	LengthNA int
+	// Breakdown of the score of the site in the units of the alignment
+	// profile: codon substitution score, gap opening and extension
+	// penalties, and indel codon bonuses. CumulativeScore is the score
+	// of the alignment up to and including the site.
+	SubstitutionScore float64 `json:"-"`
+	GapScore          float64 `json:"-"`
+	IndelBonus        float64 `json:"-"`
+	CumulativeScore   float64 `json:"-"`
 }
 
 type AlignmentReport struct {
-	FirstAA           int
-	FirstNA           int
-	LastAA            int
-	LastNA            int
-	Mutations         []m.Mutation
-	FrameShifts       []f.FrameShift
+	FirstAA int
+	// FirstNA and LastNA are the query positions aligned to FirstAA and
+	// LastAA. They count from the start of the query as it was given,
+	// even for a hit on the reverse complement, where FirstNA is then
+	// greater than LastNA. The other nucleotide positions of the report,
+	// such as the ones of its mutations, are mapped the same way, so that
+	// on the reverse strand they point at the highest position of their
+	// codon.
+	FirstNA     int
+	LastAA      int
+	LastNA      int
+	Mutations   []m.Mutation
+	FrameShifts []f.FrameShift
+	// Frameshifts which the profile declares for the gene, such as
+	// programmed ribosomal frameshifts; they aren't listed among
+	// FrameShifts
+	ExpectedFrameShifts []f.FrameShift `json:",omitempty"`
+	// Aligned codons which differ from the reference coding sequence,
+	// including synonymous ones; only reported when the profile has the
+	// coding sequence of the gene
+	CodonDifferences []m.CodonDifference `json:",omitempty"`
+	// Runs of aligned codons made only of N or three-fold mixtures (B,
+	// D, H, V); no mutation is reported within them
+	UnsequencedRegions []SequenceRange `json:",omitempty"`
+	// Ranges of the alignment outside of the unsequenced regions
+	Coverage          []SequenceRange
 	AlignedSites      []AlignedSite
 	AminoAcidsLine    string
 	ControlLine       string
 	NucleicAcidsLine  string
 	IsSimpleAlignment bool
+	// Set when the reverse complement of the query was aligned; see
+	// FirstNA
+	IsReverseComplement bool
+	IsBandedAlignment   bool
+	// Score of the alignment in the units of the alignment profile
+	Score float64
+	// Score divided by the number of aligned reference positions
+	NormalizedScore float64
+	// Percent of aligned codons which are identical to (Identity) or
+	// score positively against (Similarity) the reference in the
+	// substitution matrix of the profile
+	Identity   float64
+	Similarity float64
+	// Expected number of random hits scoring at least Score; zero
+	// unless assessed with a ScoreDistribution
+	EValue float64
+	// Parts of the alignment covering the regions of the gene which
+	// the profile declares, in order; regions without any aligned site
+	// are left out
+	Regions []RegionReport `json:"-"`
 }
 
 type Alignment struct {
@@ -53,25 +103,67 @@ type Alignment struct {
 	endPosN                       int
 	endPosA                       int
 	maxScore                      int
-	scoreHandler                  *h.GeneralScoreHandler
-	nwMatrix                      []int
+	scoreHandler                  *handler
+	codonScores                   *s.CodonScores
+	queryCodons                   []c.Codon
+	moves                         []tMove
+	workspace                     *workspace
+	ctx                           context.Context
+	linearTraceback               map[int]int
+	maxFullMatrixCells            int
 	q                             int
 	r                             int
 	supportPositionalIndel        bool
 	constIndelCodonOpeningScore   int
 	constIndelCodonExtensionScore int
 	boundaryOnly                  bool
+	bandWidth                     int
+	freeQueryEnds                 bool
+	freeReferenceEnds             bool
+	isLocal                       bool
 	isSimpleAlignment             bool
 	report                        *AlignmentReport
 }
 
-func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.GeneralScoreHandler) (*Alignment, error) {
+// ErrMisaligned is returned when no part of the query aligns to the
+// reference.
+var ErrMisaligned = errors.New("sequence misaligned")
+
+// errInterrupted is returned by align when the context of the
+// alignment is done before it completes.
+var errInterrupted = errors.New("alignment interrupted")
+
+// isUnaligned tells whether err only means that the query doesn't
+// align, as opposed to an alignment cut short.
+func isUnaligned(err error) bool {
+	return err == ErrMisaligned || err == ErrGeneNotPresent
+}
+
+func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler s.ScoreHandler) (*Alignment, error) {
+	return newAlignment(context.Background(), nSeq, aSeq, newHandler(scoreHandler, len(aSeq)), Limits{}, &workspace{})
+}
+
+// NewAlignmentContext works like NewAlignment, but gives up once ctx is
+// done or the alignment exceeds the limits. The error is then ctx.Err(),
+// a *MatrixTooLargeError or a *TimeoutError.
+func NewAlignmentContext(ctx context.Context, nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler s.ScoreHandler, limits Limits) (*Alignment, error) {
+	return newAlignment(ctx, nSeq, aSeq, newHandler(scoreHandler, len(aSeq)), limits, &workspace{})
+}
+
+func newAlignment(
+	parent context.Context, nSeq []n.NucleicAcid, aSeq []a.AminoAcid,
+	scoreHandler *handler, limits Limits, ws *workspace) (result *Alignment, err error) {
+	if err = limits.checkMatrixCells(len(nSeq), len(aSeq)); err != nil {
+		return nil, err
+	}
+	ctx, cancel := limits.withTimeout(parent)
+	defer cancel()
 	nSeqLen := len(nSeq)
 	aSeqLen := len(aSeq)
 	supportPositionalIndel := scoreHandler.IsPositionalIndelScoreSupported()
 	constIndelCodonOpeningScore, constIndelCodonExtensionScore :=
 		scoreHandler.GetConstantIndelCodonScore()
-	result := &Alignment{
+	result = &Alignment{
 		q:                             scoreHandler.GetGapOpeningScore(),
 		r:                             scoreHandler.GetGapExtensionScore(),
 		nSeq:                          nSeq,
@@ -79,14 +171,24 @@ func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.Gene
 		nSeqLen:                       nSeqLen,
 		aSeqLen:                       aSeqLen,
 		scoreHandler:                  scoreHandler,
-		nwMatrix:                      make([]int, 0),
+		moves:                         make([]tMove, 0),
+		workspace:                     ws,
+		ctx:                           ctx,
 		supportPositionalIndel:        supportPositionalIndel,
 		constIndelCodonOpeningScore:   constIndelCodonOpeningScore,
 		constIndelCodonExtensionScore: constIndelCodonExtensionScore,
+		maxFullMatrixCells:            limits.maxFullMatrixCells(),
 	}
-	ok := result.align()
-	if !ok {
-		return nil, errors.New("sequence misaligned")
+	result.setAlignmentMode(scoreHandler.GetAlignmentMode())
+	err = result.align()
+	// the buffers may be reused by the next alignment, and the codon
+	// scores only serve the pass over the row being filled
+	result.workspace, result.ctx = nil, nil
+	result.codonScores, result.queryCodons = nil, nil
+	if err == errInterrupted {
+		return nil, limits.contextError(parent, ctx)
+	} else if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -97,11 +199,18 @@ func (self *Alignment) GetReport() *AlignmentReport {
 
 func (self *Alignment) generateReport() bool {
 	var (
-		nLine, aLine, cLine              string
+		lineList                         = make([]siteLines, 0, self.aSeqLen)
+		metricsList                      = make([]codonMetrics, 0, self.aSeqLen)
 		firstAA, lastAA, firstNA, lastNA int
 		mutList                          = make([]m.Mutation, 0, 10)
 		fsList                           = make([]f.FrameShift, 0, 3)
+		expectedFsList                   []f.FrameShift
+		codonDiffList                    []m.CodonDifference
+		unsequencedList                  = make([]bool, 0, self.aSeqLen)
 		siteList                         = make([]AlignedSite, 0, 50)
+		siteScores                       = make([]stepScore, 0, 50)
+		pendingScore                     stepScore
+		metrics                          codonMetrics
 		LastPosN                         = -1
 		LastPosA                         = -1
 		lastScoreType                    = GENERAL
@@ -111,21 +220,25 @@ func (self *Alignment) generateReport() bool {
 		singleMtLen                      = (self.nSeqLen + 1) * (self.aSeqLen + 1) * 2
 	)
 	for endMtIdx%singleMtLen >= startMtIdx%singleMtLen {
-		scoreType, posN, posA := self.getTypedPos(endMtIdx)
-		if scoreType != GENERAL && (posN == 0 || posA == 0) {
+		curMtIdx := endMtIdx
+		scoreType, posN, posA := self.getTypedPos(curMtIdx)
+		if scoreType != GENERAL && self.isFreeStart(posN, posA) {
 			break
 		}
 		if self.isSimpleAlignment {
 			endMtIdx = self.getMatrixIndex(GENERAL, posN-3, posA-1)
 		} else {
-			endMtIdx = self.nwMatrix[endMtIdx]
+			endMtIdx = self.getPrevMatrixIndex(endMtIdx)
 		}
+		step := self.scoreStep(endMtIdx, curMtIdx)
 		if lastAA == 0 && lastNA == 0 {
 			if scoreType != GENERAL {
+				pendingScore.add(step)
 				continue
 			}
 			nextScoreType, _, _ := self.getTypedPos(endMtIdx)
-			if nextScoreType != GENERAL {
+			if nextScoreType != GENERAL && self.hasFreeEndGaps() {
+				pendingScore.add(step)
 				continue
 			}
 			lastAA, lastNA = posA, posN
@@ -136,6 +249,7 @@ func (self *Alignment) generateReport() bool {
 				partialNLine string
 				partialALine string
 				partialCLine string
+				nas          []n.NucleicAcid
 				mutation     *m.Mutation
 				frameshift   *f.FrameShift
 			)
@@ -145,12 +259,34 @@ func (self *Alignment) generateReport() bool {
 				absPosA := posA + 1 + self.aSeqOffset
 				absPosN := posN + 1 + self.nSeqOffset
 				lenNA := 3
-				mutation = m.MakeMutation(
+				nas = self.nSeq[posN:LastPosN]
+				expectedShift := self.getExpectedFrameShift(posA + 1)
+				if expectedShift < 0 && LastPosN > 2 && len(nas) == 3+expectedShift {
+					// the codon reads nucleotides of the previous codon again
+					nas = self.nSeq[LastPosN-3 : LastPosN]
+				}
+				mutation = m.MakeMutationWith(
 					absPosA, absPosN,
-					self.nSeq[posN:LastPosN], self.aSeq[posA])
-				frameshift = f.MakeFrameShift(
+					nas, self.aSeq[posA],
+					self.scoreHandler.GetGeneticCode(), self.scoreHandler.IsMatchingCodon)
+				frameshift = f.MakeFrameShiftWith(
 					absPosA, absPosN,
-					self.nSeq[posN:LastPosN])
+					self.nSeq[posN:LastPosN], expectedShift)
+				var siteMetrics codonMetrics
+				if mutation == nil || !mutation.IsDeletion {
+					siteMetrics.addCodon(
+						nas, self.aSeq[posA], self.scoreHandler.GetGeneticCode(),
+						self.scoreHandler.GetSubstitutionMatrix())
+					metrics.add(siteMetrics)
+					if refCodon, ok := self.getReferenceCodon(absPosA, posA); ok {
+						codonDiff := m.MakeCodonDifference(
+							absPosA, absPosN,
+							nas, refCodon, self.aSeq[posA], self.scoreHandler.GetGeneticCode())
+						if codonDiff != nil {
+							codonDiffList = append(codonDiffList, *codonDiff)
+						}
+					}
+				}
 				if mutation != nil {
 					mutList = append(mutList, *mutation)
 					if mutation.IsDeletion {
@@ -159,7 +295,10 @@ func (self *Alignment) generateReport() bool {
 						lenNA += len(mutation.InsertedCodonsText)
 					}
 				}
-				if frameshift != nil {
+				if frameshift != nil && frameshift.IsExpected {
+					expectedFsList = append(expectedFsList, *frameshift)
+					lenNA += frameshift.Shift()
+				} else if frameshift != nil {
 					fsList = append(fsList, *frameshift)
 					if frameshift.IsInsertion {
 						lenNA += frameshift.GapLength
@@ -172,10 +311,15 @@ func (self *Alignment) generateReport() bool {
 					PosNA:    absPosN,
 					LengthNA: lenNA,
 				})
+				metricsList = append(metricsList, siteMetrics)
+				unsequencedList = append(unsequencedList, isUnsequenced(self.nSeq[posN:LastPosN]))
+				// the steps since the previous site belong to this one
+				siteScores = append(siteScores, pendingScore)
+				pendingScore = stepScore{}
 			}
 			/* those are only for generate three lines */
-			if LastPosA > posA && LastPosN-posN > 2 && mutation == nil {
-				partialNLine += n.WriteString(self.nSeq[posN : posN+3])
+			if LastPosA > posA && len(nas) > 2 && mutation == nil {
+				partialNLine += n.WriteString(nas[:3])
 				partialALine += u.PadRightSpace(a.WriteString(self.aSeq[posA:LastPosA]), 3)
 				partialCLine += ":::"
 			} else {
@@ -203,9 +347,11 @@ func (self *Alignment) generateReport() bool {
 			}
 			/* end */
 
-			nLine = partialNLine + nLine
-			aLine = partialALine + aLine
-			cLine = partialCLine + cLine
+			if LastPosA > posA {
+				// the lines are built backwards, one site at a time, and
+				// joined once traced back
+				lineList = append(lineList, siteLines{partialNLine, partialALine, partialCLine})
+			}
 		}
 		if lastScoreType == INS {
 			hasUnprocessedNAs = true
@@ -213,27 +359,61 @@ func (self *Alignment) generateReport() bool {
 			LastPosN = posN
 			LastPosA = posA
 		}
+		pendingScore.add(step)
 		lastScoreType = scoreType
-		if posN == 0 || posA == 0 {
+		if self.isPathStart(curMtIdx, endMtIdx) {
 			break
 		}
 	}
+	if len(siteScores) > 0 {
+		// leading gaps belong to the first site
+		siteScores[len(siteScores)-1].add(pendingScore)
+	}
 	sortutil.Reverse(mutList)
 	sortutil.Reverse(fsList)
+	sortutil.Reverse(expectedFsList)
+	sortutil.Reverse(codonDiffList)
 	sortutil.Reverse(siteList)
+	sortutil.Reverse(siteScores)
+	sortutil.Reverse(lineList)
+	sortutil.Reverse(metricsList)
+	sortutil.Reverse(unsequencedList)
+	setSiteScores(siteList, siteScores, self.scoreHandler.GetScoreScale())
+	var (
+		score                = float64(self.maxScore) / float64(self.scoreHandler.GetScoreScale())
+		normalizedScore      float64
+		identity, similarity = metrics.percentages()
+		nLine, aLine, cLine  = joinSiteLines(lineList)
+		unsequenced          = findUnsequencedRegions(
+			siteList, unsequencedList, self.scoreHandler.GetMinUnsequencedCodons())
+	)
+	if lastAA >= firstAA {
+		normalizedScore = score / float64(lastAA-firstAA+1)
+	}
 	self.report = &AlignmentReport{
-		FirstAA:           firstAA + self.aSeqOffset,
-		FirstNA:           firstNA + self.nSeqOffset,
-		LastAA:            lastAA + self.aSeqOffset,
-		LastNA:            lastNA + self.nSeqOffset,
-		Mutations:         mutList,
-		FrameShifts:       fsList,
-		AlignedSites:      siteList,
-		AminoAcidsLine:    aLine,
-		ControlLine:       cLine,
-		NucleicAcidsLine:  nLine,
-		IsSimpleAlignment: self.isSimpleAlignment,
+		FirstAA:             firstAA + self.aSeqOffset,
+		FirstNA:             firstNA + self.nSeqOffset,
+		LastAA:              lastAA + self.aSeqOffset,
+		LastNA:              lastNA + self.nSeqOffset,
+		Mutations:           dropUnsequencedMutations(mutList, unsequenced),
+		FrameShifts:         fsList,
+		ExpectedFrameShifts: expectedFsList,
+		CodonDifferences:    dropUnsequencedCodonDifferences(codonDiffList, unsequenced),
+		UnsequencedRegions:  unsequenced,
+		Coverage:            getCoverage(siteList, unsequenced),
+		AlignedSites:        siteList,
+		AminoAcidsLine:      aLine,
+		ControlLine:         cLine,
+		NucleicAcidsLine:    nLine,
+		IsSimpleAlignment:   self.isSimpleAlignment,
+		IsBandedAlignment:   self.bandWidth > 0,
+		Score:               score,
+		NormalizedScore:     normalizedScore,
+		Identity:            identity,
+		Similarity:          similarity,
 	}
+	self.report.Regions = splitRegions(
+		self.report, self.scoreHandler.GetRegions(), lineList, metricsList)
 	return true
 }
 
@@ -249,9 +429,17 @@ func (self *Alignment) getTypedPos(matrixIndex int) (scoreType tScoreType, posN
 	return
 }
 
-func (self *Alignment) setPrevMatrixIndex(scoreType tScoreType, posN int, posA int, prevMatrixIdx int) {
+func (self *Alignment) setMove(scoreType tScoreType, posN int, posA int, move tMove) {
 	mtIdx := self.getMatrixIndex(scoreType, posN, posA)
-	self.nwMatrix[mtIdx] = prevMatrixIdx
+	self.moves[mtIdx] = move
+}
+
+func (self *Alignment) getPrevMatrixIndex(mtIdx int) int {
+	if self.linearTraceback != nil {
+		return self.linearTraceback[mtIdx]
+	}
+	scoreType, posN, posA := self.getTypedPos(mtIdx)
+	return self.getMoveTarget(scoreType, posN, posA, self.moves[mtIdx])
 }
 
 func (self *Alignment) getNA(nPos int) n.NucleicAcid {
@@ -262,22 +450,85 @@ func (self *Alignment) getAA(aPos int) a.AminoAcid {
 	return self.aSeq[aPos-1]
 }
 
-func (self *Alignment) align() bool {
+// selectCodonScores looks up the table of the scores of the codons
+// aligned to the amino acid at aPos once for the row of cells which
+// scores it. Without a table, the codons of the query are scored into
+// the one of the workspace.
+func (self *Alignment) selectCodonScores(aPos int) {
+	if aPos <= 0 {
+		return
+	}
+	position, ref := aPos+self.aSeqOffset, self.getAA(aPos)
+	self.codonScores = self.scoreHandler.GetCodonScores(position, ref)
+	if self.codonScores != nil {
+		return
+	}
+	if self.queryCodons == nil {
+		self.queryCodons = getDistinctCodons(self.nSeq)
+	}
+	self.codonScores = self.workspace.getCodonScores()
+	for _, codon := range self.queryCodons {
+		self.codonScores[codon.Base1][codon.Base2][codon.Base3] =
+			self.scoreHandler.GetSubstitutionScoreNoCache(position, codon.Base1, codon.Base2, codon.Base3, ref)
+	}
+}
+
+// getDistinctCodons returns the codons made of three consecutive
+// nucleotides of nSeq, once each.
+func getDistinctCodons(nSeq []n.NucleicAcid) []c.Codon {
+	var (
+		result []c.Codon
+		found  [n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]bool
+	)
+	for pos := 0; pos+3 <= len(nSeq); pos++ {
+		codon := c.Codon{Base1: nSeq[pos], Base2: nSeq[pos+1], Base3: nSeq[pos+2]}
+		if !found[codon.Base1][codon.Base2][codon.Base3] {
+			found[codon.Base1][codon.Base2][codon.Base3] = true
+			result = append(result, codon)
+		}
+	}
+	return result
+}
+
+// getReferenceCodon returns the codon of the reference coding sequence
+// at absPosA, unless it doesn't code the amino acid at posA, as happens
+// when aSeq isn't the reference of the profile.
+func (self *Alignment) getReferenceCodon(absPosA int, posA int) (c.Codon, bool) {
+	codon, ok := self.scoreHandler.GetReferenceCodon(absPosA)
+	if !ok || self.scoreHandler.GetGeneticCode().ToAminoAcidUnsafe(codon) != self.aSeq[posA] {
+		return codon, false
+	}
+	return codon, true
+}
+
+// getExpectedFrameShift returns the number of nucleotides the reading
+// frame of the gene is expected to shift by at posA, or zero.
+func (self *Alignment) getExpectedFrameShift(posA int) int {
+	return self.scoreHandler.GetExpectedFrameShift(posA + self.aSeqOffset)
+}
+
+func (self *Alignment) align() error {
 	var (
 		startPosN, startPosA           int
 		endPosN, endPosA, simplesCount int
+		ok                             bool
 	)
 	self.boundaryOnly = true
 	// set boundary for nSeq, so we don't have to build a huge nSeqLen * aSeqLen matrix
-	endPosN, endPosA, self.maxScore, simplesCount = self.calcScoreMainForward()
+	endPosN, endPosA, self.maxScore, simplesCount, ok = self.calcScoreMainForward()
+	if !ok {
+		return errInterrupted
+	}
 	self.nSeq = self.nSeq[:endPosN]
 	self.nSeqLen = len(self.nSeq)
 	self.aSeq = self.aSeq[:endPosA]
 	self.aSeqLen = len(self.aSeq)
 	if self.nSeqLen == 0 || self.aSeqLen == 0 {
-		return false
+		return ErrMisaligned
+	}
+	if startPosN, startPosA, _, ok = self.calcScoreMainBackward(); !ok {
+		return errInterrupted
 	}
-	startPosN, startPosA, _ = self.calcScoreMainBackward()
 	self.nSeqOffset = startPosN - 1
 	self.aSeqOffset = startPosA - 1
 	self.boundaryOnly = false
@@ -285,14 +536,34 @@ func (self *Alignment) align() bool {
 	self.nSeqLen = len(self.nSeq)
 	self.aSeq = self.aSeq[startPosA-1:]
 	self.aSeqLen = len(self.aSeq)
-	if endPosA-self.aSeqOffset == simplesCount {
+	if endPosA-self.aSeqOffset == simplesCount && endPosN-self.nSeqOffset == 3*simplesCount {
 		self.isSimpleAlignment = true
 		self.endPosN = endPosN - self.nSeqOffset
 		self.endPosA = endPosA - self.aSeqOffset
-		return self.generateReport()
+		return self.reportPath()
+	}
+	self.bandWidth = self.scoreHandler.GetBandWidth()
+	if (self.nSeqLen+1)*(self.aSeqLen+1) > self.maxFullMatrixCells {
+		// the traceback matrix is too large; find the end with another
+		// boundary pass and trace back in linear memory
+		if !self.calcScoreBanded(true) {
+			return errInterrupted
+		}
+		return self.reportPath()
 	}
 	typedPosLen := scoreTypeCount * (self.nSeqLen + 1) * (self.aSeqLen + 1)
-	self.nwMatrix = make([]int, typedPosLen)
-	self.endPosN, self.endPosA, self.maxScore, _ = self.calcScoreMainForward()
-	return self.generateReport()
+	self.moves = self.workspace.getMoves(typedPosLen)
+	if !self.calcScoreBanded(false) {
+		return errInterrupted
+	}
+	return self.reportPath()
+}
+
+// reportPath generates the report of the traced path, returning
+// ErrMisaligned if there is none.
+func (self *Alignment) reportPath() error {
+	if !self.generateReport() {
+		return ErrMisaligned
+	}
+	return nil
 }
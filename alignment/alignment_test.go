@@ -1,6 +1,7 @@
 package alignment
 
 import (
+	"context"
 	"errors"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	h "github.com/hivdb/nucamino/scorehandler/general"
@@ -30,18 +31,29 @@ var (
 	}
 )
 
+// handlerWith returns a handler of gene A for a copy of
+// EXAMPLE_ALIGNMENT_PROFILE changed by the given function.
+func handlerWith(change func(profile *ap.AlignmentProfile)) *h.GeneralScoreHandler {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	change(&profile)
+	return h.New(ap.Gene("A"), profile)
+}
+
 func TestNewAlignment(t *testing.T) {
 	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
 	result, _ := NewAlignment(NSEQ, ASEQ, handler)
 	expect := &Alignment{
 		q: -1000, r: -200,
 		nSeq: NSEQ, aSeq: ASEQ, nSeqLen: 57, aSeqLen: 19,
-		scoreHandler: handler, nwMatrix: []int{},
+		scoreHandler: newHandler(handler, len(ASEQ)), moves: []tMove{},
 		endPosN: 57, endPosA: 19, maxScore: 9100,
 		isSimpleAlignment:             true,
 		supportPositionalIndel:        false,
 		constIndelCodonOpeningScore:   0,
 		constIndelCodonExtensionScore: 200,
+		maxFullMatrixCells:            DefaultMaxFullMatrixCells,
+		freeQueryEnds:                 true,
+		freeReferenceEnds:             true,
 	}
 	result.report = nil
 	if !reflect.DeepEqual(expect, result) {
@@ -56,14 +68,17 @@ func TestNewAlignmentWithIns(t *testing.T) {
 	expect := &Alignment{
 		q: -1000, r: -200,
 		nSeq: NSEQ_INS, aSeq: ASEQ, nSeqLen: 60, aSeqLen: 19,
-		scoreHandler: handler, nwMatrix: []int{},
+		scoreHandler: newHandler(handler, len(ASEQ)), moves: []tMove{},
 		endPosN: 60, endPosA: 19, maxScore: 7700,
 		isSimpleAlignment:             false,
 		supportPositionalIndel:        false,
 		constIndelCodonOpeningScore:   0,
 		constIndelCodonExtensionScore: 200,
+		maxFullMatrixCells:            DefaultMaxFullMatrixCells,
+		freeQueryEnds:                 true,
+		freeReferenceEnds:             true,
 	}
-	result.nwMatrix = []int{}
+	result.moves = []tMove{}
 	result.report = nil
 	if !reflect.DeepEqual(expect, result) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -77,14 +92,17 @@ func TestNewAlignmentWithInsFs(t *testing.T) {
 	expect := &Alignment{
 		q: -1000, r: -200,
 		nSeq: NSEQ_INSFS, aSeq: ASEQ, nSeqLen: 59, aSeqLen: 19,
-		scoreHandler: handler, nwMatrix: []int{},
+		scoreHandler: newHandler(handler, len(ASEQ)), moves: []tMove{},
 		endPosN: 59, endPosA: 19, maxScore: 7700,
 		isSimpleAlignment:             false,
 		supportPositionalIndel:        false,
 		constIndelCodonOpeningScore:   0,
 		constIndelCodonExtensionScore: 200,
+		maxFullMatrixCells:            DefaultMaxFullMatrixCells,
+		freeQueryEnds:                 true,
+		freeReferenceEnds:             true,
 	}
-	result.nwMatrix = []int{}
+	result.moves = []tMove{}
 	result.report = nil
 	if !reflect.DeepEqual(expect, result) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -98,14 +116,17 @@ func TestNewAlignmentWithDelFs(t *testing.T) {
 	expect := &Alignment{
 		q: -1000, r: -200,
 		nSeq: NSEQ_DELFS, aSeq: ASEQ, nSeqLen: 55, aSeqLen: 19,
-		scoreHandler: handler, nwMatrix: []int{},
+		scoreHandler: newHandler(handler, len(ASEQ)), moves: []tMove{},
 		endPosN: 55, endPosA: 19, maxScore: 6500,
 		isSimpleAlignment:             false,
 		supportPositionalIndel:        false,
 		constIndelCodonOpeningScore:   0,
 		constIndelCodonExtensionScore: 200,
+		maxFullMatrixCells:            DefaultMaxFullMatrixCells,
+		freeQueryEnds:                 true,
+		freeReferenceEnds:             true,
 	}
-	result.nwMatrix = []int{}
+	result.moves = []tMove{}
 	result.report = nil
 	if !reflect.DeepEqual(expect, result) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -119,14 +140,17 @@ func TestNewAlignmentWithDel(t *testing.T) {
 	expect := &Alignment{
 		q: -1000, r: -200,
 		nSeq: NSEQ_DEL, aSeq: ASEQ, nSeqLen: 53, aSeqLen: 19,
-		scoreHandler: handler, nwMatrix: []int{},
+		scoreHandler: newHandler(handler, len(ASEQ)), moves: []tMove{},
 		endPosN: 53, endPosA: 19, maxScore: 7200,
 		isSimpleAlignment:             false,
 		supportPositionalIndel:        false,
 		constIndelCodonOpeningScore:   0,
 		constIndelCodonExtensionScore: 200,
+		maxFullMatrixCells:            DefaultMaxFullMatrixCells,
+		freeQueryEnds:                 true,
+		freeReferenceEnds:             true,
 	}
-	result.nwMatrix = []int{}
+	result.moves = []tMove{}
 	result.report = nil
 	if !reflect.DeepEqual(expect, result) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -199,30 +223,35 @@ func TestGetReport(t *testing.T) {
 			*m.MakeMutation(14, 37, []n.NucleicAcid{n.A, n.G, n.A, n.A, n.A, n.A}, a.R),
 		},
 		FrameShifts: []f.FrameShift{},
+		Coverage:    []SequenceRange{{1, 18, 1, 54}},
 		AlignedSites: []AlignedSite{
-			AlignedSite{1, 1, 3},
-			AlignedSite{2, 4, 3},
-			AlignedSite{3, 7, 3},
-			AlignedSite{4, 10, 3},
-			AlignedSite{5, 13, 3},
-			AlignedSite{6, 16, 3},
-			AlignedSite{7, 19, 3},
-			AlignedSite{8, 22, 3},
-			AlignedSite{9, 25, 0},
-			AlignedSite{10, 25, 3},
-			AlignedSite{11, 28, 3},
-			AlignedSite{12, 31, 3},
-			AlignedSite{13, 34, 3},
-			AlignedSite{14, 37, 6},
-			AlignedSite{15, 43, 3},
-			AlignedSite{16, 46, 3},
-			AlignedSite{17, 49, 3},
-			AlignedSite{18, 52, 3},
+			AlignedSite{1, 1, 3, 5, 0, 0, 5},
+			AlignedSite{2, 4, 3, 4, 0, 0, 9},
+			AlignedSite{3, 7, 3, 4, 0, 0, 13},
+			AlignedSite{4, 10, 3, 4, 0, 0, 17},
+			AlignedSite{5, 13, 3, 6, 0, 0, 23},
+			AlignedSite{6, 16, 3, 7, 0, 0, 30},
+			AlignedSite{7, 19, 3, 5, 0, 0, 35},
+			AlignedSite{8, 22, 3, 7, 0, 0, 42},
+			AlignedSite{9, 25, 0, 0, -16, 2, 28},
+			AlignedSite{10, 25, 3, 6, 0, 0, 34},
+			AlignedSite{11, 28, 3, 4, 0, 0, 38},
+			AlignedSite{12, 31, 3, 4, 0, 0, 42},
+			AlignedSite{13, 34, 3, 6, 0, 0, 48},
+			AlignedSite{14, 37, 6, 5, -16, 2, 39},
+			AlignedSite{15, 43, 3, 6, 0, 0, 45},
+			AlignedSite{16, 46, 3, 4, 0, 0, 49},
+			AlignedSite{17, 49, 3, 4, 0, 0, 53},
+			AlignedSite{18, 52, 3, 5, 0, 0, 58},
 		},
 		AminoAcidsLine:    "T  V  L  V  G  P  T  P  V  N  I  I  G  R     N  L  L  T  ",
 		ControlLine:       "::::::::::::::::::::::::---:::::::::::::::+++::::::::::::",
 		NucleicAcidsLine:  "ACAGTRTTAGTAGGACCTACACCT   AACATAATTGGAAGAAAAAATCTGTTGACY",
 		IsSimpleAlignment: false,
+		Score:             58,
+		NormalizedScore:   58.0 / 18,
+		Identity:          100,
+		Similarity:        100,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -304,3 +333,110 @@ func TestPosInsCodonScore(t *testing.T) {
 	}
 
 }
+
+func TestNewAlignmentBothStrands(t *testing.T) {
+	nseq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTAACATAATTGGAAGAAAAAATCTGTTGACYCA")
+	nseqLen := len(nseq)
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aln, _ := NewAlignment(nseq, ASEQ, handler)
+	expect := aln.GetReport()
+	aln, _ = NewAlignmentBothStrands(nseq, ASEQ, handler)
+	result := aln.GetReport()
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+
+	aln, _ = NewAlignmentBothStrands(n.ReverseComplement(nseq), ASEQ, handler)
+	result = aln.GetReport()
+	if !result.IsReverseComplement {
+		t.Errorf(MSG_NOT_EQUAL, true, result.IsReverseComplement)
+	}
+	if result.FirstNA != nseqLen || result.LastNA != nseqLen-53 {
+		t.Errorf(MSG_NOT_EQUAL, []int{nseqLen, nseqLen - 53}, []int{result.FirstNA, result.LastNA})
+	}
+	if result.FirstAA != expect.FirstAA || result.LastAA != expect.LastAA {
+		t.Errorf(MSG_NOT_EQUAL, []int{expect.FirstAA, expect.LastAA}, []int{result.FirstAA, result.LastAA})
+	}
+	if result.ControlLine != expect.ControlLine {
+		t.Errorf(MSG_NOT_EQUAL, expect.ControlLine, result.ControlLine)
+	}
+	for idx, mut := range result.Mutations {
+		expectMut := expect.Mutations[idx]
+		if mut.ToString() != expectMut.ToString() || mut.NAPosition != nseqLen-expectMut.NAPosition+1 {
+			t.Errorf(MSG_NOT_EQUAL, expectMut, mut)
+		}
+	}
+	for idx, site := range result.AlignedSites {
+		expectSite := expect.AlignedSites[idx]
+		if site.PosAA != expectSite.PosAA || site.PosNA != nseqLen-expectSite.PosNA+1 {
+			t.Errorf(MSG_NOT_EQUAL, expectSite, site)
+		}
+	}
+}
+
+func TestBothStrandsInterrupted(t *testing.T) {
+	// the forward strand doesn't align, and the alignment of the
+	// reverse strand is cut short
+	errs := []error{ErrMisaligned, context.Canceled}
+	align := func(nseq []n.NucleicAcid) (*Alignment, error) {
+		err := errs[0]
+		errs = errs[1:]
+		return nil, err
+	}
+	if _, err := BothStrandsAlignFunc(align)(NSEQ); err != context.Canceled {
+		t.Errorf(MSG_NOT_EQUAL, context.Canceled, err)
+	}
+
+	// the alignment of the forward strand is cut short, so the reverse
+	// strand isn't aligned
+	calls := 0
+	align = func(nseq []n.NucleicAcid) (*Alignment, error) {
+		calls++
+		return nil, context.DeadlineExceeded
+	}
+	if _, err := BothStrandsAlignFunc(align)(NSEQ); err != context.DeadlineExceeded || calls != 1 {
+		t.Errorf(MSG_NOT_EQUAL, context.DeadlineExceeded, err)
+	}
+}
+
+func TestCodonDifferences(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.ReferenceSequences = ap.ReferenceSeqs{"A": ASEQ}
+		profile.ReferenceCDS = ap.ReferenceNASeqs{
+			"A": n.ReadString("ACAGTATTAGTAGGACCTACACCTGTCAACATAATTGGAAGAAATCTGTTGACTCAG"),
+		}
+	})
+	nseq := n.ReadString("ACAGTACTAGTAGGACCTACACCTGCCAACATAATTGGAAGGAATCTGTTGACTCAG")
+	aln, _ := NewAlignment(nseq, ASEQ, handler)
+	result := aln.GetReport()
+	expect := []m.CodonDifference{
+		{3, 7, "CTA", "TTA", "L", "L", true, []int{1}},
+		{9, 25, "GCC", "GTC", "A", "V", false, []int{2}},
+		{14, 40, "AGG", "AGA", "R", "R", true, []int{3}},
+	}
+	if !reflect.DeepEqual(result.CodonDifferences, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result.CodonDifferences)
+	}
+	if len(result.Mutations) != 1 || result.Mutations[0].ToString() != "V9A:GCC" {
+		t.Errorf("Expected only V9A but received %v", result.Mutations)
+	}
+
+	aln, _ = NewAlignmentBothStrands(n.ReverseComplement(nseq), ASEQ, handler)
+	result = aln.GetReport()
+	for idx, diff := range result.CodonDifferences {
+		if diff.ToString() != expect[idx].ToString() || diff.NAPosition != len(nseq)-expect[idx].NAPosition+1 {
+			t.Errorf(MSG_NOT_EQUAL, expect[idx], diff)
+		}
+	}
+
+	// without coding sequence, or against another reference, no codon
+	// differences are reported
+	aln, _ = NewAlignment(nseq, ASEQ, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	if diffs := aln.GetReport().CodonDifferences; len(diffs) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, nil, diffs)
+	}
+	aln, _ = NewAlignment(nseq, a.ReadString("TVLVGPTPVNIIGKNLLTQ"), handler)
+	if diffs := aln.GetReport().CodonDifferences; len(diffs) != 2 {
+		t.Errorf("Expected no codon difference at 14 but received %v", diffs)
+	}
+}
@@ -0,0 +1,71 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+// codon 5 (GRA) is a mixture of the reference G (GGA) and E (GAA)
+var AMBIGUOUS_NSEQ = n.ReadString("ACAGTATTAGTAGRACCTACACCTGTAAACATAATTGGAAGAAATCTGTTGACTCAG")
+
+func TestAmbiguousCodonPolicies(t *testing.T) {
+	cases := []struct {
+		policy      ap.AmbiguousCodonPolicy
+		prevalences ap.AminoAcidPrevalences
+		score       float64
+		mutations   []string
+	}{
+		{"", nil, 2, []string{"G5EG:GRA"}},
+		{ap.AverageAmbiguousCodons, nil, 2, []string{"G5EG:GRA"}},
+		{ap.BestAmbiguousCodons, nil, 6, []string{}},
+		{ap.WorstAmbiguousCodons, nil, -2, []string{"G5EG:GRA"}},
+		// without prevalences at the position, like average
+		{ap.WeightedAmbiguousCodons, ap.AminoAcidPrevalences{4: {a.G: 100}}, 2, []string{"G5EG:GRA"}},
+		{ap.WeightedAmbiguousCodons, ap.AminoAcidPrevalences{5: {a.G: 75, a.E: 25}}, 4, []string{"G5EG:GRA"}},
+		{ap.WeightedAmbiguousCodons, ap.AminoAcidPrevalences{5: {a.G: 100}}, 6, []string{}},
+	}
+	for _, testCase := range cases {
+		handler := handlerWith(func(profile *ap.AlignmentProfile) {
+			profile.AmbiguousCodonPolicy = testCase.policy
+			profile.GenePrevalences = map[ap.Gene]ap.AminoAcidPrevalences{"A": testCase.prevalences}
+		})
+		aligned, _ := NewAlignment(AMBIGUOUS_NSEQ, ASEQ, handler)
+		report := aligned.GetReport()
+		assertSiteScores(t, report)
+		if score := report.AlignedSites[4].SubstitutionScore; score != testCase.score {
+			t.Errorf(MSG_NOT_EQUAL, testCase.score, score)
+		}
+		if texts := mutationTexts(report); !reflect.DeepEqual(texts, testCase.mutations) {
+			t.Errorf(MSG_NOT_EQUAL, testCase.mutations, texts)
+		}
+		control := report.ControlLine[12:15]
+		expect := "..."
+		if len(testCase.mutations) == 0 {
+			expect = ":::"
+		}
+		if control != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, control)
+		}
+	}
+}
+
+func TestWeightedAmbiguousCodonsNotCached(t *testing.T) {
+	// GRA at codons 5 and 13, which are both G; only codon 13 has
+	// prevalences
+	nseq := n.ReadString("ACAGTATTAGTAGRACCTACACCTGTAAACATAATTGRAAGAAATCTGTTGACTCAG")
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.AmbiguousCodonPolicy = ap.WeightedAmbiguousCodons
+		profile.GenePrevalences = map[ap.Gene]ap.AminoAcidPrevalences{"A": {13: {a.G: 100}}}
+	})
+	for round := 0; round < 2; round++ {
+		aligned, _ := NewAlignment(nseq, ASEQ, handler)
+		report := aligned.GetReport()
+		scores := [2]float64{report.AlignedSites[4].SubstitutionScore, report.AlignedSites[12].SubstitutionScore}
+		if expect := [2]float64{2, 6}; scores != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, scores)
+		}
+	}
+}
@@ -23,7 +23,7 @@ func (self *Alignment) calcExtInsScoreBackward(
 		//control = strings.Repeat("---", pos.a)
 	} else {
 		score = negInf
-		if posA == 1 {
+		if posA == 1 && self.hasFreeEndGaps() {
 			q, r, insOpeningScore, insExtensionScore = 0, 0, 0, 0
 		} else {
 			if self.supportPositionalIndel {
@@ -41,12 +41,22 @@ func (self *Alignment) calcExtInsScoreBackward(
 				score = cand // "+++"
 			}
 		}
+		var (
+			fsScore20     = q + r + r
+			fsScore10     = q + r
+			expectedShift = self.getExpectedFrameShift(posA - 1)
+		)
+		if expectedShift == 2 {
+			fsScore20 = 0
+		} else if expectedShift == 1 {
+			fsScore10 = 0
+		}
 		if posN < self.nSeqLen-2 {
-			if cand = gScore20 + q + r + r; cand > score {
+			if cand = gScore20 + fsScore20; cand > score {
 				score = cand // "++"
 			}
 		}
-		if cand = gScore10 + q + r; cand > score {
+		if cand = gScore10 + fsScore10; cand > score {
 			score = cand // "+"
 		}
 	}
@@ -72,7 +82,7 @@ func (self *Alignment) calcDelScoreBackward(
 			q, q2, r, r2      = self.q, self.q, self.r, self.r
 		)
 		score = negInf
-		if posN == 1 {
+		if posN == 1 && self.hasFreeEndGaps() {
 			q, r, delOpeningScore, delExtensionScore = 0, 0, 0, 0
 		} else {
 			if self.supportPositionalIndel {
@@ -129,16 +139,28 @@ func (self *Alignment) calcScoreBackward(
 			curAA = self.getAA(posA)
 		)
 
-		score = negInf
-		if cand := /* #1 */ gScore11 + q + r + r; cand > score {
+		expectedShift := self.getExpectedFrameShift(posA)
+		switch {
+		case expectedShift == -2 && posN > 2:
+			// the codon reads the two nucleotides before it again
+			tmpScore := sh.GetSubstitutionScore(posA+self.aSeqOffset, self.getNA(posN-2), self.getNA(posN-1), curNA, curAA)
+			score = gScore11 + tmpScore // "(..)."
+		case expectedShift == -1 && posN > 1 && posN < self.nSeqLen-1:
+			// the codon reads the nucleotide before it again
+			tmpScore := sh.GetSubstitutionScore(posA+self.aSeqOffset, self.getNA(posN-1), curNA, self.getNA(posN+1), curAA)
+			score = gScore21 + tmpScore // "(.).."
+		default:
+			expectedShift = 0
+		}
+		if cand := /* #1 */ gScore11 + q + r + r; expectedShift != -2 && cand > score {
 			score = cand // ".--"
 		}
 		if posN < self.nSeqLen-1 {
 			prevNA = self.getNA(posN + 1)
-			if cand := /* #2 */ gScore21 + q + r; cand > score {
+			if cand := /* #2 */ gScore21 + q + r; expectedShift != -1 && cand > score {
 				score = cand // ".-."
 			}
-			if cand := /* #3 */ gScore21 + q + r; cand > score {
+			if cand := /* #3 */ gScore21 + q + r; expectedShift != -1 && cand > score {
 				score = cand // "..-"
 			}
 			if cand := /* #7 */ dScore11 + r + r; cand >= score {
@@ -147,10 +169,7 @@ func (self *Alignment) calcScoreBackward(
 		}
 		if posN < self.nSeqLen-2 {
 			prevNA2 = self.getNA(posN + 2)
-			tmpScore, present := sh.GetCachedSubstitutionScore(posA, curNA, prevNA, prevNA2, curAA)
-			if !present {
-				tmpScore = sh.GetSubstitutionScoreNoCache(posA, curNA, prevNA, prevNA2, curAA)
-			}
+			tmpScore := self.codonScores[curNA][prevNA][prevNA2]
 			if cand := /* #4 */ gScore31 + tmpScore; cand > score {
 				score = cand // "..."
 			}
@@ -165,19 +184,22 @@ func (self *Alignment) calcScoreBackward(
 		if cand := /* #10 */ dScore00; cand >= score {
 			score = cand // ""
 		}
+		if self.isLocal && score < 0 {
+			score = 0
+		}
 	}
 	return score
 }
 
-func (self *Alignment) calcScoreMainBackward() (int, int, int) {
+func (self *Alignment) calcScoreMainBackward() (int, int, int, bool) {
 	var (
 		maxScore     = negInf
 		maxScorePosN = self.nSeqLen
 		maxScorePosA = self.aSeqLen
-		gScores      = make([]int, self.nSeqLen+1)
-		dScores      = make([]int, self.nSeqLen+1)
-		gScoresCur   = make([]int, self.nSeqLen+1)
-		dScoresCur   = make([]int, self.nSeqLen+1)
+		gScores      = self.workspace.getRow(gScoresRow, self.nSeqLen+1)
+		dScores      = self.workspace.getRow(dScoresRow, self.nSeqLen+1)
+		gScoresCur   = self.workspace.getRow(gScoresCurRow, self.nSeqLen+1)
+		dScoresCur   = self.workspace.getRow(dScoresCurRow, self.nSeqLen+1)
 
 		gScore30, gScore20 int
 		gScore00, gScore10 int
@@ -192,6 +214,10 @@ func (self *Alignment) calcScoreMainBackward() (int, int, int) {
 	)
 
 	for j := self.aSeqLen; j >= 1; j-- {
+		if isDone(self.ctx) {
+			return 0, 0, negInf, false
+		}
+		self.selectCodonScores(j)
 		gScore30, iScore30 = negInf, negInf
 		gScore20, iScore20 = negInf, negInf
 		gScore10, iScore10 = negInf, negInf
@@ -230,7 +256,9 @@ func (self *Alignment) calcScoreMainBackward() (int, int, int) {
 
 			gScoresCur[i] = gScore00
 
-			if (i == 1 || j == 1) && gScore00 > maxScore {
+			// cell (i, j) of the backward pass precedes nucleotide i
+			// and amino acid j
+			if gScore00 > maxScore && (self.isLocal || self.isFreeStart(i-1, j-1)) {
 				maxScore = gScore00
 				maxScorePosN = i
 				maxScorePosA = j
@@ -244,5 +272,5 @@ func (self *Alignment) calcScoreMainBackward() (int, int, int) {
 		dScores, dScoresCur = dScoresCur, dScores
 
 	}
-	return maxScorePosN, maxScorePosA, maxScore
+	return maxScorePosN, maxScorePosA, maxScore, true
 }
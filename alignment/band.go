@@ -0,0 +1,88 @@
+package alignment
+
+// This file implements the banded mode of the second forward pass.
+//
+// After the boundary pass trimmed nSeq and aSeq, the optimal path of a
+// near-reference sequence runs close to the diagonal from (0, 0) to
+// (nSeqLen, aSeqLen). In banded mode only the cells within bandWidth
+// nucleotides of that diagonal are filled. Cells outside of the band
+// score windowNegInf, so a path can't pass through them. When the
+// resulting path comes close to the band edge, a better path may lie
+// outside of the band. The same is true when the banded path scores
+// lower than the best path found by the boundary pass, which wasn't
+// banded. In both cases the band is widened until the banded path is
+// trusted or the band covers the whole matrix.
+
+// Cells closer than this to the band edge may have had predecessors
+// outside of the band
+const bandEdgeMargin = 3
+
+// getBandRange returns the first and the last posN of row posA that
+// are filled by the forward pass.
+func (self *Alignment) getBandRange(posA int) (int, int) {
+	if self.bandWidth == 0 {
+		return 0, self.nSeqLen
+	}
+	center := posA * self.nSeqLen / self.aSeqLen
+	lo, hi := center-self.bandWidth, center+self.bandWidth
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > self.nSeqLen {
+		hi = self.nSeqLen
+	}
+	return lo, hi
+}
+
+// isPathNearBandEdge reports whether any cell of the optimal path lies
+// within bandEdgeMargin of an edge of the band that isn't also an edge
+// of the matrix.
+func (self *Alignment) isPathNearBandEdge() bool {
+	mtIdx := self.getMatrixIndex(GENERAL, self.endPosN, self.endPosA)
+	for {
+		_, posN, posA := self.getTypedPos(mtIdx)
+		lo, hi := self.getBandRange(posA)
+		if lo > 0 && posN < lo+bandEdgeMargin {
+			return true
+		}
+		if hi < self.nSeqLen && posN > hi-bandEdgeMargin {
+			return true
+		}
+		prevIdx := self.getPrevMatrixIndex(mtIdx)
+		if self.isPathStart(mtIdx, prevIdx) {
+			return false
+		}
+		mtIdx = prevIdx
+	}
+}
+
+// calcScoreBanded runs the second forward pass and traces back the
+// optimal path, doubling the band width until the path neither comes
+// close to the band edge nor scores lower than the boundary pass.
+// Banding is disabled once the band covers the whole matrix. It returns
+// false if the alignment was interrupted.
+func (self *Alignment) calcScoreBanded(useLinearTraceback bool) bool {
+	var (
+		boundaryScore = self.maxScore
+		ok            bool
+	)
+	for {
+		if self.bandWidth >= self.nSeqLen {
+			self.bandWidth = 0
+		}
+		self.boundaryOnly = useLinearTraceback
+		self.endPosN, self.endPosA, self.maxScore, _, ok = self.calcScoreMainForward()
+		self.boundaryOnly = false
+		if ok && useLinearTraceback {
+			self.linearTraceback, ok = self.calcLinearTraceback()
+		}
+		if !ok {
+			return false
+		}
+		if self.bandWidth == 0 ||
+			(self.maxScore >= boundaryScore && !self.isPathNearBandEdge()) {
+			return true
+		}
+		self.bandWidth *= 2
+	}
+}
@@ -0,0 +1,93 @@
+package alignment
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomAminoAcids(rnd *rand.Rand, length int) []a.AminoAcid {
+	aseq := make([]a.AminoAcid, length)
+	for idx := range aseq {
+		aseq[idx] = a.AminoAcids[rnd.Intn(a.NumAminoAcids)]
+	}
+	return aseq
+}
+
+// assertBandedReport checks that the banded alignment reports the same
+// path as the full one, and returns the banded alignment.
+func assertBandedReport(t *testing.T, nseq []n.NucleicAcid, aseq []a.AminoAcid, bandWidth int) *Alignment {
+	return assertBandedReportWith(t, nseq, aseq, bandWidth, Limits{})
+}
+
+// assertBandedReportWith works like assertBandedReport, with the limits
+// of the banded alignment.
+func assertBandedReportWith(t *testing.T, nseq []n.NucleicAcid, aseq []a.AminoAcid, bandWidth int, limits Limits) *Alignment {
+	full, _ := NewAlignment(nseq, aseq, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	banded, _ := NewAlignmentContext(context.Background(), nseq, aseq, handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.BandWidth = bandWidth
+	}), limits)
+	if full == nil || banded == nil {
+		if full != banded {
+			t.Errorf(MSG_NOT_EQUAL, full, banded)
+		}
+		return banded
+	}
+	report := *banded.GetReport()
+	if report.IsBandedAlignment != (banded.bandWidth > 0) {
+		t.Errorf(MSG_NOT_EQUAL, banded.bandWidth > 0, report.IsBandedAlignment)
+	}
+	report.IsBandedAlignment = false
+	if !reflect.DeepEqual(*full.GetReport(), report) {
+		t.Errorf(MSG_NOT_EQUAL, *full.GetReport(), report)
+	}
+	return banded
+}
+
+func TestBandedAlignment(t *testing.T) {
+	for _, seq := range linearTestSeqs {
+		assertBandedReport(t, n.ReadString(seq), ASEQ, 3)
+	}
+}
+
+func TestBandedAlignmentRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		aseq := randomAminoAcids(rnd, 50+rnd.Intn(150))
+		nseq := randomQuery(rnd, aseq)
+		assertBandedReport(t, nseq, aseq, 6)
+		withLinearTraceback(func(limits Limits) {
+			assertBandedReportWith(t, nseq, aseq, 6, limits)
+		})
+	}
+}
+
+func TestBandedAlignmentUsesBand(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	aseq := randomAminoAcids(rnd, 300)
+	nseq := randomQuery(rnd, aseq)
+	banded := assertBandedReport(t, nseq, aseq, 24)
+	if !banded.GetReport().IsBandedAlignment {
+		t.Errorf(MSG_NOT_EQUAL, true, banded.GetReport().IsBandedAlignment)
+	}
+	if banded.bandWidth != 24 {
+		t.Errorf(MSG_NOT_EQUAL, 24, banded.bandWidth)
+	}
+}
+
+func TestBandedAlignmentWidensBand(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	aseq := randomAminoAcids(rnd, 300)
+	nseq := randomQuery(rnd, aseq)
+	// a long insertion moves the path far away from the diagonal
+	nseq = append(nseq[:300:300], append(randomQuery(rnd, aseq[:40]), nseq[300:]...)...)
+	banded := assertBandedReport(t, nseq, aseq, 6)
+	if banded.bandWidth == 6 {
+		t.Errorf("Expected the band to be widened")
+	}
+}
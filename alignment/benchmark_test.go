@@ -0,0 +1,148 @@
+package alignment
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	d "github.com/hivdb/nucamino/data"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"testing"
+)
+
+// benchmarkAlignment aligns a query with random mutations to a random
+// reference of aSeqLen amino acids; 1000 is about the length of POL.
+// The first pssmLen positions of the reference are scored by a PSSM
+// holding the BLOSUM62 scores of their amino acids.
+func benchmarkAlignment(b *testing.B, aSeqLen int, pssmLen int, limits Limits) {
+	rnd := rand.New(rand.NewSource(1))
+	aseq := randomAminoAcids(rnd, aSeqLen)
+	nseq := randomQuery(rnd, aseq)
+	handler := h.New(ap.Gene("A"), pssmProfile(aseq, pssmLen))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewAlignmentContext(context.Background(), nseq, aseq, handler, limits)
+	}
+}
+
+// pssmProfile returns the example profile, with the first pssmLen
+// positions of aseq scored by a PSSM of gene A.
+func pssmProfile(aseq []a.AminoAcid, pssmLen int) ap.AlignmentProfile {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	if pssmLen > 0 {
+		pssm := make(ap.PSSM)
+		for pos := 1; pos <= pssmLen; pos++ {
+			var scores [a.NumAminoAcids]int
+			for _, aa := range a.AminoAcids {
+				scores[aa] = d.Blosum62.Lookup(aa, aseq[pos-1])
+			}
+			pssm[pos] = scores
+		}
+		profile.GenePSSMs = map[ap.Gene]ap.PSSM{"A": pssm}
+	}
+	return profile
+}
+
+func BenchmarkAlignment300(b *testing.B) {
+	benchmarkAlignment(b, 300, 0, Limits{})
+}
+
+func BenchmarkAlignment1000(b *testing.B) {
+	benchmarkAlignment(b, 1000, 0, Limits{})
+}
+
+func BenchmarkAlignmentPSSM1000(b *testing.B) {
+	benchmarkAlignment(b, 1000, 1000, Limits{})
+}
+
+// The linear traceback benchmarks compare with BenchmarkAlignment1000
+// and BenchmarkAlignmentFull3000; 3000 is about the length of the HCV
+// polyprotein.
+func BenchmarkAlignmentLinear1000(b *testing.B) {
+	benchmarkAlignment(b, 1000, 0, Limits{MaxFullMatrixCells: 1})
+}
+
+func BenchmarkAlignmentFull3000(b *testing.B) {
+	benchmarkAlignment(b, 3000, 0, Limits{MaxFullMatrixCells: 1 << 30})
+}
+
+func BenchmarkAlignmentLinear3000(b *testing.B) {
+	benchmarkAlignment(b, 3000, 0, Limits{MaxFullMatrixCells: 1})
+}
+
+func BenchmarkAlignerAlignment300(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	aseq := randomAminoAcids(rnd, 300)
+	nseq := randomQuery(rnd, aseq)
+	aligner := NewAligner(aseq, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aligner.Align(nseq)
+	}
+}
+
+// benchmarkTestSeqs aligns each of the test sequences once per
+// iteration, as a worker of the command line does.
+func benchmarkTestSeqs(b *testing.B, align func(nSeq []n.NucleicAcid)) {
+	nseqs := make([][]n.NucleicAcid, len(linearTestSeqs))
+	for idx, seq := range linearTestSeqs {
+		nseqs[idx] = n.ReadString(seq)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, nseq := range nseqs {
+			align(nseq)
+		}
+	}
+}
+
+func BenchmarkNewAlignmentTestSeqs(b *testing.B) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	benchmarkTestSeqs(b, func(nSeq []n.NucleicAcid) {
+		NewAlignment(nSeq, ASEQ, handler)
+	})
+}
+
+func BenchmarkAlignerTestSeqs(b *testing.B) {
+	aligner := NewAligner(ASEQ, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	benchmarkTestSeqs(b, func(nSeq []n.NucleicAcid) {
+		aligner.Align(nSeq)
+	})
+}
+
+// BenchmarkNewScoreHandlerPSSM1000 builds the substitution tables of a
+// reference as long as POL, which is done once per gene.
+func BenchmarkNewScoreHandlerPSSM1000(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	aseq := randomAminoAcids(rnd, 1000)
+	profile := pssmProfile(aseq, 1000)
+	profile.ReferenceSequences = map[ap.Gene][]a.AminoAcid{"A": aseq}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.New(ap.Gene("A"), profile)
+	}
+}
+
+// BenchmarkFindSeedWindowLongQuery locates a gene as long as POL in a
+// query holding ten genomes of about 11,000 nucleotides, each with a
+// copy of the gene, so that the hits of every copy are chained.
+func BenchmarkFindSeedWindowLongQuery(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	aseq := randomAminoAcids(rnd, 1000)
+	nseq := []n.NucleicAcid{}
+	for copies := 0; copies < 10; copies++ {
+		nseq = append(nseq, randomQuery(rnd, randomAminoAcids(rnd, 2700))...)
+		nseq = append(nseq, randomQuery(rnd, aseq)...)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findSeedWindow(nseq, aseq, c.StandardCode)
+	}
+}
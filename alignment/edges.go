@@ -0,0 +1,192 @@
+package alignment
+
+import (
+	"math"
+)
+
+// This file holds the edges of the dynamic programming: for each type
+// of cell, the candidates that the forward pass compares for its
+// score, in the order they're compared. An edge leads to the
+// predecessor of its move and gains the score of one step of the path.
+// The forward pass follows the edges to fill the matrix, the linear
+// traceback follows them backward, and the traceback scores the sites
+// of the path with their steps, so a new transition is only added
+// here.
+
+// A tEdge is a candidate of the forward pass for the score of a cell.
+type tEdge struct {
+	move tMove
+	// the edge only leads to the cells from minPosN to maxPosN
+	minPosN int
+	maxPosN int
+	// whether the edge wins over an earlier candidate of equal score
+	orEqual bool
+	// whether the step is the substitution of the codon ending at the
+	// cell, which is left out of step and score
+	isCodon bool
+	step    stepScore
+	// the total of step
+	score int
+}
+
+// maxPosN of the edges which lead to every column
+const anyPosN = math.MaxInt32
+
+// maxCellEdges is the largest number of edges leading to a cell.
+const maxCellEdges = 8
+
+// maxEdgeOffset is the largest number of columns an edge leads back.
+const maxEdgeOffset = 3
+
+// tRowEdges holds the edges leading to the cells of a row, by score
+// type. The DEL cells of the last column, whose trailing gaps may be
+// free, have their own edges after the other score types. The edges
+// from the row above come first in each list, up to fromSameRow.
+type tRowEdges struct {
+	edges       [scoreTypeCount + 1][]tEdge
+	fromSameRow [scoreTypeCount + 1]int
+}
+
+// setRowEdges sets the edges leading to the cells of row posA, reusing
+// the slices of edges.
+func (self *Alignment) setRowEdges(edges *tRowEdges, posA int) {
+	for idx := range edges.edges {
+		scoreType, lastColumn := tScoreType(idx), false
+		if idx == scoreTypeCount {
+			scoreType, lastColumn = DEL, true
+		}
+		rowEdges := self.appendEdges(edges.edges[idx][:0], scoreType, posA, lastColumn)
+		fromSameRow := len(rowEdges)
+		for edgeIdx := len(rowEdges) - 1; edgeIdx >= 0 && rowEdges[edgeIdx].isFromSameRow(); edgeIdx-- {
+			fromSameRow = edgeIdx
+		}
+		edges.edges[idx], edges.fromSameRow[idx] = rowEdges, fromSameRow
+	}
+}
+
+// get returns the edges leading to the cells of the given type, of the
+// last column or of the others, along with the index of the first one
+// from the same row.
+func (self *tRowEdges) get(scoreType tScoreType, lastColumn bool) ([]tEdge, int) {
+	idx := int(scoreType)
+	if scoreType == DEL && lastColumn {
+		idx = scoreTypeCount
+	}
+	return self.edges[idx], self.fromSameRow[idx]
+}
+
+// appendEdges appends the edges leading to the cells of the given type
+// in row posA, in the order the forward pass compares them, those from
+// the row above first. The DEL cells of the last column, whose trailing
+// gaps may be free, have their own edges.
+func (self *Alignment) appendEdges(edges []tEdge, scoreType tScoreType, posA int, lastColumn bool) []tEdge {
+	start := len(edges)
+	edges = self.appendEdgeSteps(edges, scoreType, posA, lastColumn)
+	for idx := start; idx < len(edges); idx++ {
+		edges[idx].score = edges[idx].step.total()
+	}
+	return edges
+}
+
+func (self *Alignment) appendEdgeSteps(edges []tEdge, scoreType tScoreType, posA int, lastColumn bool) []tEdge {
+	var (
+		q = self.q
+		r = self.r
+	)
+	switch scoreType {
+	case INS:
+		var openingBonus, extensionBonus int
+		if posA == self.aSeqLen && self.hasFreeEndGaps() {
+			// no penalty for trailing gaps
+			q, r = 0, 0
+		} else {
+			openingBonus, extensionBonus = self.getIndelCodonScore(posA, true)
+		}
+		var (
+			fsStep20      = stepScore{gap: q + r + r}
+			fsStep10      = stepScore{gap: q + r}
+			expectedShift = self.getExpectedFrameShift(posA)
+		)
+		// an expected frameshift isn't penalized
+		if expectedShift == 2 {
+			fsStep20 = stepScore{}
+		} else if expectedShift == 1 {
+			fsStep10 = stepScore{}
+		}
+		return append(edges,
+			tEdge{move: moveI30, minPosN: 4, maxPosN: anyPosN, step: stepScore{gap: r + r + r, bonus: extensionBonus}},                    // "+++"
+			tEdge{move: moveG30, minPosN: 4, maxPosN: anyPosN, step: stepScore{gap: q + r + r + r, bonus: openingBonus + extensionBonus}}, // "+++"
+			tEdge{move: moveG20, minPosN: 3, maxPosN: anyPosN, step: fsStep20},                                                            // "++"
+			tEdge{move: moveG10, minPosN: 1, maxPosN: anyPosN, step: fsStep10},                                                            // "+"
+		)
+	case DEL:
+		var (
+			openingBonus, extensionBonus int
+			q2, r2                       = q, r
+		)
+		if lastColumn && self.hasFreeEndGaps() {
+			// no penalty for trailing gaps
+			q, r = 0, 0
+		} else {
+			openingBonus, extensionBonus = self.getIndelCodonScore(posA, false)
+		}
+		return append(edges,
+			tEdge{move: moveD01, minPosN: 0, maxPosN: anyPosN, orEqual: true, step: stepScore{gap: r + r + r, bonus: extensionBonus}},     // "---"
+			tEdge{move: moveG01, minPosN: 0, maxPosN: anyPosN, step: stepScore{gap: q + r + r + r, bonus: openingBonus + extensionBonus}}, // "---"
+			tEdge{move: moveG11, minPosN: 1, maxPosN: anyPosN, step: stepScore{gap: q + r + r}},                                           // ".--"
+			tEdge{move: moveG11, minPosN: 1, maxPosN: anyPosN, step: stepScore{gap: q2 + r2 + q + r}},                                     // "-.-"
+			tEdge{move: moveD11, minPosN: 2, maxPosN: anyPosN, orEqual: true, step: stepScore{gap: r2 + q + r}},                           // "-.-"
+			tEdge{move: moveG21, minPosN: 2, maxPosN: anyPosN, orEqual: true, step: stepScore{gap: q + r}},                                // "..-"
+		)
+	}
+	// at an expected frameshift which reads nucleotides of the previous
+	// codon again, the codon made of fewer nucleotides is scored as a
+	// whole codon instead of a gap; the first nucleotides can't be read
+	// again
+	var (
+		expectedShift = self.getExpectedFrameShift(posA)
+		maxPosN11     = anyPosN
+		maxPosN21     = anyPosN
+	)
+	if expectedShift == -2 {
+		maxPosN11 = 2
+	} else if expectedShift == -1 {
+		maxPosN21 = 2
+	}
+	edges = append(edges,
+		tEdge{move: moveG11, minPosN: 1, maxPosN: maxPosN11, step: stepScore{gap: q + r + r}},          // #1 "--."
+		tEdge{move: moveG21, minPosN: 2, maxPosN: maxPosN21, step: stepScore{gap: q + r}},              // #2 ".-.", #3 "-.."
+		tEdge{move: moveD11, minPosN: 2, maxPosN: anyPosN, orEqual: true, step: stepScore{gap: r + r}}, // #7 "--."
+		tEdge{move: moveG31, minPosN: 3, maxPosN: anyPosN, isCodon: true},                              // #4 "..."
+	)
+	if expectedShift == -1 {
+		edges = append(edges, tEdge{move: moveG21, minPosN: 3, maxPosN: anyPosN, isCodon: true}) // #5 "(.).."
+	} else if expectedShift == -2 {
+		edges = append(edges, tEdge{move: moveG11, minPosN: 3, maxPosN: anyPosN, isCodon: true}) // #6 "(..)."
+	}
+	return append(edges,
+		tEdge{move: moveD21, minPosN: 3, maxPosN: anyPosN, orEqual: true, step: stepScore{gap: r}}, // #8 "-.."
+		tEdge{move: moveI00, minPosN: 0, maxPosN: anyPosN, orEqual: true},                          // #9
+		tEdge{move: moveD00, minPosN: 0, maxPosN: anyPosN, orEqual: true},                          // #10
+	)
+}
+
+// leadsTo reports whether the edge leads to the cells of column posN.
+func (self *tEdge) leadsTo(posN int) bool {
+	return posN >= self.minPosN && posN <= self.maxPosN
+}
+
+// isFromSameRow reports whether the edge leads to a cell of the same
+// row.
+func (self *tEdge) isFromSameRow() bool {
+	return moveTargets[self.move].aOffset == 0
+}
+
+// getCodonScore returns the substitution score of the codon ending at
+// posN in the row whose codon scores are selected.
+func (self *Alignment) getCodonScore(posN int) int {
+	if posN < 3 {
+		return 0
+	}
+	return self.codonScores[self.getNA(posN-2)][self.getNA(posN-1)][self.getNA(posN)]
+}
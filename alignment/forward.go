@@ -1,358 +1,310 @@
 package alignment
 
-import (
-	n "github.com/hivdb/nucamino/types/nucleic"
-)
+// A forwardRow holds a row of the forward pass and the row above it, by
+// score type. The cell of column posN is at index posN-base; the cells
+// left of the ones computed score windowNegInf.
+type forwardRow struct {
+	base        int
+	scores      [scoreTypeCount][]int
+	prevScores  [scoreTypeCount][]int
+	moves       [scoreTypeCount][]tMove
+	codonScores []int
+	edges       *tRowEdges
+	// the edges from the same row by score type, and how many there are
+	fromSameRow      [scoreTypeCount][maxCellEdges]tSameRowEdge
+	fromSameRowCount [scoreTypeCount]int
+}
 
-func (self *Alignment) calcExtInsScoreForward(
-	posN int, posA int,
-	gScore30 int, iScore30 int,
-	gScore20 int, gScore10 int) (int, int) {
-	var (
-		insOpeningScore     int
-		insExtensionScore   int
-		cand, prevMatrixIdx int
-		score               = negInf
-		sh                  = self.scoreHandler
-		q                   = self.q
-		r                   = self.r
-		calcMtIdx           = !self.boundaryOnly
-	)
-	//var control string
-	if posN == 0 && posA > 0 {
-		score = 0 // no penalty for initial gaps
-		if calcMtIdx {
-			prevMatrixIdx = self.getMatrixIndex(GENERAL, 0, 0)
+// A tSameRowEdge is an edge from the same row as the forward pass
+// follows it, along with the scores of the row it reads. None of them
+// reads a codon, which takes an amino acid.
+type tSameRowEdge struct {
+	prevScores []int
+	nOffset    int
+	score      int
+	minPosN    int
+	maxPosN    int
+	tie        int
+	move       tMove
+}
+
+// setFromSameRow sets the edges from the same row leading to the cells
+// of the given type.
+func (self *forwardRow) setFromSameRow(edges []tEdge, scoreType tScoreType) {
+	sameRowEdges := self.fromSameRow[scoreType][:0]
+	for idx := range edges {
+		edge := &edges[idx]
+		target := moveTargets[edge.move]
+		sameRowEdge := tSameRowEdge{
+			prevScores: self.scores[target.scoreType],
+			nOffset:    target.nOffset,
+			score:      edge.score,
+			minPosN:    edge.minPosN,
+			maxPosN:    edge.maxPosN,
+			move:       edge.move,
 		}
-		//control = strings.Repeat("---", pos.a)
-	} else {
-		score = negInf
-		if posA == self.aSeqLen {
-			// no penalty for trailing gaps
-			r, q, insOpeningScore, insExtensionScore = 0, 0, 0, 0
-		} else {
-			if self.supportPositionalIndel {
-				insOpeningScore, insExtensionScore = sh.GetPositionalIndelCodonScore(posA+self.aSeqOffset, true)
-			} else {
-				insOpeningScore = self.constIndelCodonOpeningScore
-				insExtensionScore = self.constIndelCodonExtensionScore
-			}
+		if edge.orEqual {
+			sameRowEdge.tie = 1
 		}
-		if posN > 3 {
-			if cand = iScore30 + r + r + r + insExtensionScore; cand > score {
-				score = cand
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(INS, posN-3, posA) //, "+++"
-				}
-			}
-			if cand = gScore30 + q + r + r + r + insOpeningScore + insExtensionScore; cand > score {
-				score = cand
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-3, posA) //, "+++"
-				}
-			}
+		sameRowEdges = append(sameRowEdges, sameRowEdge)
+	}
+	self.fromSameRowCount[scoreType] = len(sameRowEdges)
+}
+
+// swap makes the row the row above the next one.
+func (self *forwardRow) swap() {
+	self.scores, self.prevScores = self.prevScores, self.scores
+}
+
+// followEdgesFromAbove compares the candidates of the edges leading from
+// the row above to the cells of the given type from column from to
+// column to, one edge at a time.
+func (self *forwardRow) followEdgesFromAbove(edges []tEdge, scoreType tScoreType, from int, to int) {
+	for idx := range edges {
+		edge := &edges[idx]
+		target := moveTargets[edge.move]
+		first, last := from, to
+		if first < edge.minPosN {
+			first = edge.minPosN
+		}
+		if last > edge.maxPosN {
+			last = edge.maxPosN
+		}
+		if first > last {
+			continue
+		}
+		var (
+			tie         = 0
+			edgeScore   = edge.score
+			edgeMove    = edge.move
+			scores      = self.scores[scoreType][first-self.base : last-self.base+1]
+			moves       = self.moves[scoreType][first-self.base : last-self.base+1]
+			codonScores = self.codonScores[first-self.base : last-self.base+1]
+			prevScores  = self.prevScores[target.scoreType][first-target.nOffset-self.base:]
+		)
+		prevScores = prevScores[:len(scores)]
+		moves = moves[:len(scores)]
+		codonScores = codonScores[:len(scores)]
+		if edge.orEqual {
+			tie = 1
 		}
-		if posN > 2 {
-			if cand = gScore20 + q + r + r; cand > score {
-				score = cand
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-2, posA) //, "++"
+		if edge.isCodon {
+			for k, prevScore := range prevScores {
+				if cand := prevScore + edgeScore + codonScores[k]; cand+tie > scores[k] {
+					scores[k], moves[k] = cand, edgeMove
 				}
 			}
-		}
-		if cand = gScore10 + q + r; cand > score {
-			score = cand
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-1, posA) //, "+"
+		} else {
+			for k, prevScore := range prevScores {
+				if cand := prevScore + edgeScore; cand+tie > scores[k] {
+					scores[k], moves[k] = cand, edgeMove
+				}
 			}
 		}
 	}
-	return score, prevMatrixIdx
 }
 
-func (self *Alignment) calcDelScoreForward(
-	posN int, posA int,
-	gScore01 int, gScore11 int, gScore21 int,
-	dScore01 int, dScore11 int) (int, int) {
+// followEdgesFromSameRow compares the candidates of the edges leading
+// from the same row to the cell of the given type in column posN, after
+// those of the edges from the row above.
+func (self *forwardRow) followEdgesFromSameRow(scoreType tScoreType, posN int) {
 	var (
-		prevMatrixIdx int
-		score         = negInf
-		sh            = self.scoreHandler
-		calcMtIdx     = !self.boundaryOnly
+		k      = posN - self.base
+		scores = self.scores[scoreType]
+		score  = scores[k]
+		move   = self.moves[scoreType][k]
+		edges  = self.fromSameRow[scoreType][:self.fromSameRowCount[scoreType]]
 	)
-	//var control string
-	if posN > 0 && posA == 0 {
-		score = 0 // no penalty for initial gaps
-		if calcMtIdx {
-			prevMatrixIdx = self.getMatrixIndex(GENERAL, 0, 0)
+	for idx := range edges {
+		edge := &edges[idx]
+		if posN < edge.minPosN || posN > edge.maxPosN {
+			continue
 		}
-		//control = strings.Repeat("+", pos.n)
-	} else if posN > 0 {
-		var (
-			delOpeningScore   int
-			delExtensionScore int
-			q, q2, r, r2      = self.q, self.q, self.r, self.r
-		)
-		score = negInf
-		if posN == self.nSeqLen {
-			// no penalty for trailing gaps
-			q, r, delOpeningScore, delExtensionScore = 0, 0, 0, 0
-		} else {
-			if self.supportPositionalIndel {
-				delOpeningScore, delExtensionScore = sh.GetPositionalIndelCodonScore(posA+self.aSeqOffset, false)
-			} else {
-				delOpeningScore = self.constIndelCodonOpeningScore
-				delExtensionScore = self.constIndelCodonExtensionScore
-			}
-		}
-		if cand := dScore01 + r + r + r + delExtensionScore; cand >= score {
-			score = cand
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(DEL, posN, posA-1) //, "---"
-			}
+		if cand := edge.prevScores[k-edge.nOffset] + edge.score; cand+edge.tie > score {
+			score, move = cand, edge.move
 		}
+	}
+	scores[k], self.moves[scoreType][k] = score, move
+}
 
-		if cand := gScore01 + q + r + r + r + delOpeningScore + delExtensionScore; cand > score {
-			score = cand
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(GENERAL, posN, posA-1) //, "---"
+// calcRowForward fills the cells of row posA from column from to column
+// to. The edges from the row above are followed for all of these cells
+// first, then the cells are completed from left to right with the edges
+// from the same row, so each cell still compares its candidates in the
+// order of its edges. The cell of matrix index seed, if any, scores
+// seedScore instead.
+func (self *Alignment) calcRowForward(row *forwardRow, posA int, from int, to int, seed int, seedScore int) {
+	var (
+		edges   = row.edges
+		scores  = &row.scores
+		moves   = &row.moves
+		lastDel = to
+	)
+	self.selectCodonScores(posA)
+	self.setRowEdges(edges, posA)
+	for k := from - maxEdgeOffset - row.base; k < from-row.base; k++ {
+		if k >= 0 {
+			for st := 0; st < scoreTypeCount; st++ {
+				scores[st][k] = windowNegInf
 			}
 		}
-
-		if cand := gScore11 + q + r + r; cand > score {
-			score = cand
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-1, posA-1) //, ".--"
-			}
+	}
+	for i := from; i <= to; i++ {
+		k := i - row.base
+		for st := 0; st < scoreTypeCount; st++ {
+			scores[st][k], moves[st][k] = negInf, moveOrigin
 		}
-
-		if cand := gScore11 + q2 + r2 + q + r; cand > score {
-			score = cand
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-1, posA-1) //, "-.-"
-			}
+	}
+	if posA > 0 {
+		for i := from; i <= to; i++ {
+			row.codonScores[i-row.base] = self.getCodonScore(i)
 		}
-
-		if posN > 1 {
-			if cand := dScore11 + r2 + q + r; cand >= score {
-				score = cand
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(DEL, posN-1, posA-1) //, "-.-"
-				}
-			}
-
-			if cand := gScore21 + q + r; cand >= score {
-				score = cand
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-2, posA-1) //, "..-"
-				}
-			}
+		if to == self.nSeqLen {
+			delEdges, fromSameRow := edges.get(DEL, true)
+			row.followEdgesFromAbove(delEdges[:fromSameRow], DEL, to, to)
+			lastDel--
 		}
+		delEdges, fromSameRow := edges.get(DEL, false)
+		row.followEdgesFromAbove(delEdges[:fromSameRow], DEL, from, lastDel)
+		gEdges, fromSameRow := edges.get(GENERAL, false)
+		row.followEdgesFromAbove(gEdges[:fromSameRow], GENERAL, from, to)
 	}
-	return score, prevMatrixIdx
-}
-
-func (self *Alignment) calcScoreForward(
-	posN int, posA int,
-	gScore11 int, gScore21 int, gScore31 int,
-	iScore00 int,
-	dScore00 int, dScore11 int, dScore21 int) (int, int, bool) {
+	insEdges, insFromSameRow := edges.get(INS, false)
+	row.setFromSameRow(insEdges[insFromSameRow:], INS)
+	gEdges, gFromSameRow := edges.get(GENERAL, false)
+	row.setFromSameRow(gEdges[gFromSameRow:], GENERAL)
 	var (
-		prevMatrixIdx int
-		isSimple      bool
-		score         = negInf
-		calcMtIdx     = !self.boundaryOnly
+		seedType = tScoreType(scoreTypeCount)
+		seedN    = -1
 	)
-	if posN == 0 || posA == 0 {
-		score = 0
-		if calcMtIdx {
-			prevMatrixIdx = self.getMatrixIndex(GENERAL, posN, posA)
+	if seed > -1 {
+		if scoreType, posN, seedA := self.getTypedPos(seed); seedA == posA {
+			seedType, seedN = scoreType, posN
 		}
-	} else {
-		var (
-			prevNA, prevNA2/*, prevNA3*/ n.NucleicAcid
-			sh    = self.scoreHandler
-			q     = self.q
-			r     = self.r
-			curNA = self.getNA(posN)
-			curAA = self.getAA(posA)
-		)
-		score = negInf
-		if cand := /* #1 */ gScore11 + q + r + r; cand > score {
-			score = cand
-			isSimple = false
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-1, posA-1) //, "--."
+	}
+	for i := from; i <= to; i++ {
+		k := i - row.base
+
+		if i == 0 {
+			scores[INS][k] = windowNegInf
+			if posA > 0 && self.hasFreeEndGaps() {
+				scores[INS][k] = 0 // no penalty for initial gaps
 			}
+		} else {
+			row.followEdgesFromSameRow(INS, i)
 		}
-		if posN > 1 {
-			prevNA = self.getNA(posN - 1)
-			if cand := /* #2 */ gScore21 + q + r; cand > score {
-				score = cand
-				isSimple = false
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-2, posA-1) //, ".-."
-				}
-			}
-			if cand := /* #3 */ gScore21 + q + r; cand > score {
-				score = cand
-				isSimple = false
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-2, posA-1) //, "-.."
-				}
-			}
-			if cand := /* #7 */ dScore11 + r + r; cand >= score {
-				score = cand
-				isSimple = false
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(DEL, posN-1, posA-1) //, "--."
-				}
-			}
+		if i == seedN && seedType == INS {
+			scores[INS][k] = seedScore
 		}
-		if posN > 2 {
-			prevNA2 = self.getNA(posN - 2)
-			tmpScore, present := sh.GetCachedSubstitutionScore(posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA)
-			if !present {
-				tmpScore = sh.GetSubstitutionScoreNoCache(posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA)
-			}
-			if cand := /* #4 */ gScore31 + tmpScore; cand > score {
-				score = cand
-				isSimple = true
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(GENERAL, posN-3, posA-1) //, "..."
-				}
-			}
 
-			if cand := /* #8 */ dScore21 + r; cand >= score {
-				score = cand
-				isSimple = false
-				if calcMtIdx {
-					prevMatrixIdx = self.getMatrixIndex(DEL, posN-2, posA-1) //, "-.."
-				}
+		if posA == 0 {
+			scores[DEL][k] = windowNegInf
+			if i > 0 && self.hasFreeEndGaps() {
+				scores[DEL][k] = 0 // no penalty for initial gaps
 			}
+		} else if i == 0 && self.isFreeStart(i, posA) {
+			scores[DEL][k], moves[DEL][k] = negInf, moveOrigin
 		}
-		if cand := /* #9 */ iScore00; cand >= score {
-			score = cand
-			isSimple = false
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(INS, posN, posA) //, ""
-			}
+		if i == seedN && seedType == DEL {
+			scores[DEL][k] = seedScore
 		}
-		if cand := /* #10 */ dScore00; cand >= score {
-			score = cand
-			isSimple = false
-			if calcMtIdx {
-				prevMatrixIdx = self.getMatrixIndex(DEL, posN, posA) //, ""
+
+		if self.isFreeStart(i, posA) {
+			scores[GENERAL][k], moves[GENERAL][k] = 0, moveSelf
+		} else if posA == 0 {
+			// reached through leading insertions
+			scores[GENERAL][k], moves[GENERAL][k] = scores[INS][k], moveI00
+		} else if i == 0 {
+			// reached through leading deletions
+			scores[GENERAL][k], moves[GENERAL][k] = scores[DEL][k], moveD00
+		} else {
+			row.followEdgesFromSameRow(GENERAL, i)
+			if self.isLocal && scores[GENERAL][k] < 0 {
+				// start a new local alignment from this cell
+				scores[GENERAL][k], moves[GENERAL][k] = 0, moveSelf
 			}
 		}
+		if i == seedN && seedType == GENERAL {
+			scores[GENERAL][k] = seedScore
+		}
 	}
-	return score, prevMatrixIdx, isSimple
 }
 
-func (self *Alignment) calcScoreMainForward() (int, int, int, int) {
+func (self *Alignment) calcScoreMainForward() (int, int, int, int, bool) {
 	var (
 		maxScore               = negInf
 		maxScorePosN           = 0
 		maxScorePosA           = 0
 		simplesCountAtMaxScore = 0
-		gScores                = make([]int, self.nSeqLen+1)
-		dScores                = make([]int, self.nSeqLen+1)
-		simplesCountMt         = make([]int, self.nSeqLen+1)
-		gScoresCur             = make([]int, self.nSeqLen+1)
-		dScoresCur             = make([]int, self.nSeqLen+1)
-		simplesCountMtCur      = make([]int, self.nSeqLen+1)
-
-		gScore30, gScore20 int
-		gScore00, gScore10 int
-		gScore01, gScore11 int
-		gScore21, gScore31 int
-		simplesCount       int
-
-		iScore30, iScore20 int
-		iScore00, iScore10 int
-
-		dScore00, dScore01 int
-		dScore11, dScore21 int
-		prevMtIdx          int
-		isSimple           bool
-		calcMtIdx          = !self.boundaryOnly
+		simplesCountMt         = self.workspace.getRow(simplesCountRow, self.nSeqLen+1)
+		simplesCountMtCur      = self.workspace.getRow(simplesCountCurRow, self.nSeqLen+1)
+		calcMtIdx              = !self.boundaryOnly
+		row                    = forwardRow{
+			scores: [scoreTypeCount][]int{
+				GENERAL: self.workspace.getRow(gScoresCurRow, self.nSeqLen+1),
+				INS:     self.workspace.getRow(iScoresCurRow, self.nSeqLen+1),
+				DEL:     self.workspace.getRow(dScoresCurRow, self.nSeqLen+1),
+			},
+			prevScores: [scoreTypeCount][]int{
+				GENERAL: self.workspace.getRow(gScoresRow, self.nSeqLen+1),
+				INS:     self.workspace.getRow(iScoresRow, self.nSeqLen+1),
+				DEL:     self.workspace.getRow(dScoresRow, self.nSeqLen+1),
+			},
+			moves: [scoreTypeCount][]tMove{
+				GENERAL: self.workspace.getMoveRow(GENERAL, self.nSeqLen+1),
+				INS:     self.workspace.getMoveRow(INS, self.nSeqLen+1),
+				DEL:     self.workspace.getMoveRow(DEL, self.nSeqLen+1),
+			},
+			codonScores: self.workspace.getRow(codonScoresRow, self.nSeqLen+1),
+			edges:       &self.workspace.edges,
+		}
 	)
 
 	for j := 0; j <= self.aSeqLen; j++ {
-		gScore30, iScore30 = negInf, negInf
-		gScore20, iScore20 = negInf, negInf
-		gScore10, iScore10 = negInf, negInf
-		for i := 0; i <= self.nSeqLen; i++ {
-			gScore01 = gScores[i]
-			dScore01 = dScores[i]
-			gScore11, gScore21, gScore31 = negInf, negInf, negInf
-			dScore11, dScore21 = negInf, negInf
-			if i > 0 {
-				gScore11 = gScores[i-1]
-				dScore11 = dScores[i-1]
-			}
-			if i > 1 {
-				gScore21 = gScores[i-2]
-				dScore21 = dScores[i-2]
-			}
-			if i > 2 {
-				gScore31 = gScores[i-3]
-				simplesCount = simplesCountMt[i-3]
-			}
-			iScore00, prevMtIdx = self.calcExtInsScoreForward(
-				i, j, gScore30,
-				iScore30, gScore20, gScore10)
-
-			if calcMtIdx {
-				self.setPrevMatrixIndex(INS, i, j, prevMtIdx)
-			}
-
-			dScore00, prevMtIdx = self.calcDelScoreForward(
-				i, j,
-				gScore01, gScore11, gScore21,
-				dScore01, dScore11)
-
-			dScoresCur[i] = dScore00
-
+		if isDone(self.ctx) {
+			return 0, 0, negInf, 0, false
+		}
+		lo, hi := self.getBandRange(j)
+		self.calcRowForward(&row, j, lo, hi, -1, 0)
+		gScoresCur, dScoresCur := row.scores[GENERAL], row.scores[DEL]
+		for i := lo; i <= hi; i++ {
 			if calcMtIdx {
-				self.setPrevMatrixIndex(DEL, i, j, prevMtIdx)
-			}
-
-			gScore00, prevMtIdx, isSimple = self.calcScoreForward(
-				i, j,
-				gScore11, gScore21, gScore31,
-				iScore00,
-				dScore00, dScore11, dScore21)
-
-			if isSimple {
-				simplesCount++
-				simplesCountMtCur[i] = simplesCount
-			} else {
-				// reset simplesCount
-				simplesCount = 0
-				simplesCountMtCur[i] = 0
+				self.setMove(INS, i, j, row.moves[INS][i])
+				self.setMove(DEL, i, j, row.moves[DEL][i])
+				self.setMove(GENERAL, i, j, row.moves[GENERAL][i])
 			}
-			gScoresCur[i] = gScore00
 
-			if calcMtIdx {
-				self.setPrevMatrixIndex(GENERAL, i, j, prevMtIdx)
+			// only the codon of #4 keeps the alignment simple
+			simplesCount := 0
+			if row.moves[GENERAL][i] == moveG31 {
+				simplesCount = simplesCountMt[i-3] + 1
 			}
+			simplesCountMtCur[i] = simplesCount
 
-			if (i == self.nSeqLen || j == self.aSeqLen) && gScore00 > maxScore {
+			if gScore00 := gScoresCur[i]; gScore00 > maxScore && self.isFreeEnd(i, j) {
 				maxScore = gScore00
 				maxScorePosN = i
 				maxScorePosA = j
 				simplesCountAtMaxScore = simplesCount
 			}
+		}
 
-			gScore30, gScore20, gScore10 = gScore20, gScore10, gScore00
-			iScore30, iScore20, iScore10 = iScore20, iScore10, iScore00
+		if self.bandWidth > 0 {
+			// the next row also reads the cells of this row which are
+			// just outside of the band; they may hold stale scores
+			nextLo, nextHi := self.getBandRange(j + 1)
+			for i := nextLo - bandEdgeMargin; i < lo; i++ {
+				if i >= 0 {
+					gScoresCur[i], dScoresCur[i] = windowNegInf, windowNegInf
+				}
+			}
+			for i := hi + 1; i <= nextHi; i++ {
+				gScoresCur[i], dScoresCur[i] = windowNegInf, windowNegInf
+			}
 		}
 
-		gScores, gScoresCur = gScoresCur, gScores
+		row.swap()
 		simplesCountMt, simplesCountMtCur = simplesCountMtCur, simplesCountMt
-		dScores, dScoresCur = dScoresCur, dScores
-
 	}
-	return maxScorePosN, maxScorePosA, maxScore, simplesCountAtMaxScore
+	return maxScorePosN, maxScorePosA, maxScore, simplesCountAtMaxScore, true
 }
@@ -0,0 +1,92 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	f "github.com/hivdb/nucamino/types/frameshift"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+func frameShiftTexts(frameShifts []f.FrameShift) []string {
+	texts := []string{}
+	for _, frameShift := range frameShifts {
+		texts = append(texts, frameShift.ToString())
+	}
+	return texts
+}
+
+func TestExpectedFrameShift(t *testing.T) {
+	// codon 10 (AAC) reads the last nucleotide of codon 9 (GTA) again
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTAACATAATTGGAAGAAATCTGTTGACTCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	if texts := frameShiftTexts(aligned.GetReport().FrameShifts); len(texts) == 0 {
+		t.Errorf("Expected a frameshift without declaring it")
+	}
+
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneFrameShifts = map[ap.Gene][]ap.ExpectedFrameShift{"A": {{10, -1}}}
+	})
+	aligned, _ = NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	if texts := frameShiftTexts(report.FrameShifts); len(texts) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, []string{}, texts)
+	}
+	expect := []string{"10del1bp"}
+	if texts := frameShiftTexts(report.ExpectedFrameShifts); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+	if texts := mutationTexts(report); len(texts) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, []string{}, texts)
+	}
+	site := report.AlignedSites[9]
+	if site.PosNA != 28 || site.LengthNA != 2 || site.GapScore != 0 {
+		t.Errorf(MSG_NOT_EQUAL, []interface{}{28, 2, 0.0},
+			[]interface{}{site.PosNA, site.LengthNA, site.GapScore})
+	}
+	if report.FirstAA != 1 || report.LastAA != 19 || report.LastNA != len(nseq) {
+		t.Errorf(MSG_NOT_EQUAL, [3]int{1, 19, len(nseq)},
+			[3]int{report.FirstAA, report.LastAA, report.LastNA})
+	}
+	assertSameReports(t, nseq, ASEQ, handler)
+}
+
+func TestExpectedFrameShiftInsertion(t *testing.T) {
+	// one nucleotide (G) is skipped after codon 10
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTAAACGATAATTGGAAGAAATCTGTTGACTCAG")
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneFrameShifts = map[ap.Gene][]ap.ExpectedFrameShift{"A": {{10, 1}}}
+	})
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	if texts := frameShiftTexts(report.FrameShifts); len(texts) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, []string{}, texts)
+	}
+	expect := []string{"10ins1bp_G"}
+	if texts := frameShiftTexts(report.ExpectedFrameShifts); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+	if texts := mutationTexts(report); len(texts) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, []string{}, texts)
+	}
+	assertSameReports(t, nseq, ASEQ, handler)
+}
+
+func TestExpectedFrameShiftAbsent(t *testing.T) {
+	// sequences without the expected frameshift still align in frame
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTAAACATAATTGGAAGAAATCTGTTGACTCAG")
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneFrameShifts = map[ap.Gene][]ap.ExpectedFrameShift{"A": {{10, -1}}}
+	})
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	if len(report.FrameShifts) != 0 || len(report.ExpectedFrameShifts) != 0 ||
+		len(report.Mutations) != 0 {
+		t.Errorf("Expected neither frameshift nor mutation but received %v, %v and %v",
+			frameShiftTexts(report.FrameShifts), frameShiftTexts(report.ExpectedFrameShifts),
+			mutationTexts(report))
+	}
+}
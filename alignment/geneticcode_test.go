@@ -0,0 +1,34 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+func TestGeneticCode(t *testing.T) {
+	// codon 11 (ATA) is I in the standard code but M in the vertebrate
+	// mitochondrial code, where codon 14 (AGA) is a stop codon
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTAAACATAATTGGAAGAAATCTGTTGACTCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	if mutations := aligned.GetReport().Mutations; len(mutations) != 0 {
+		t.Errorf(MSG_NOT_EQUAL, 0, len(mutations))
+	}
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneticCode, _ = c.GetGeneticCode(2)
+	})
+	aligned, _ = NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	var result []string
+	for _, mutation := range report.Mutations {
+		result = append(result, mutation.ToString())
+	}
+	expect := []string{"I11M:ATA", "R14*:AGA"}
+	if !reflect.DeepEqual(expect, result) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
@@ -0,0 +1,199 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	d "github.com/hivdb/nucamino/data"
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// A handler holds the ScoreHandler of an alignment together with the
+// optional interfaces it implements, or the defaults of the ones it
+// doesn't, so that the aligner only checks them once. It also copies
+// the positional indel scores and the expected frameshifts of the
+// reference, which the aligner looks up in each cell, to slices indexed
+// by position.
+type handler struct {
+	s.ScoreHandler
+	s.CodonScoreTables
+	s.ScoreScaler
+	s.AlignmentSettings
+	s.ReferenceAnnotations
+	s.AmbiguousCodonMatcher
+	s.Translator
+	s.MatrixSelector
+	indelCodonScores    []indelCodonScores
+	expectedFrameShifts []int
+}
+
+// indelCodonScores holds the opening and extension bonuses of
+// insertions and deletions at a position of the reference.
+type indelCodonScores struct {
+	insOpening   int
+	insExtension int
+	delOpening   int
+	delExtension int
+}
+
+// newHandler returns the handler of alignments against a reference of
+// aSeqLen amino acids.
+func newHandler(scoreHandler s.ScoreHandler, aSeqLen int) *handler {
+	code := geneticCodeOf(scoreHandler)
+	result := &handler{
+		ScoreHandler:          scoreHandler,
+		CodonScoreTables:      noCodonScores{scoreHandler},
+		ScoreScaler:           unscaledScores{},
+		AlignmentSettings:     defaultSettings{},
+		ReferenceAnnotations:  noAnnotations{},
+		AmbiguousCodonMatcher: allMatched{code},
+		Translator:            fixedCode{code},
+		MatrixSelector:        fixedMatrix{d.Blosum62},
+	}
+	if tables, ok := scoreHandler.(s.CodonScoreTables); ok {
+		result.CodonScoreTables = tables
+	}
+	if scaler, ok := scoreHandler.(s.ScoreScaler); ok {
+		result.ScoreScaler = scaler
+	}
+	if settings, ok := scoreHandler.(s.AlignmentSettings); ok {
+		result.AlignmentSettings = settings
+	}
+	if annotations, ok := scoreHandler.(s.ReferenceAnnotations); ok {
+		result.ReferenceAnnotations = annotations
+		for pos := 1; pos <= aSeqLen; pos++ {
+			if shift := annotations.GetExpectedFrameShift(pos); shift != 0 {
+				if result.expectedFrameShifts == nil {
+					result.expectedFrameShifts = make([]int, aSeqLen+1)
+				}
+				result.expectedFrameShifts[pos] = shift
+			}
+		}
+	}
+	if matcher, ok := scoreHandler.(s.AmbiguousCodonMatcher); ok {
+		result.AmbiguousCodonMatcher = matcher
+	}
+	if selector, ok := scoreHandler.(s.MatrixSelector); ok {
+		result.MatrixSelector = selector
+	}
+	if scoreHandler.IsPositionalIndelScoreSupported() {
+		// an insertion may follow the last amino acid, and the backward
+		// pass looks up the one before the first
+		result.indelCodonScores = make([]indelCodonScores, aSeqLen+1)
+		for pos := range result.indelCodonScores {
+			scores := &result.indelCodonScores[pos]
+			scores.insOpening, scores.insExtension = scoreHandler.GetPositionalIndelCodonScore(pos, true)
+			scores.delOpening, scores.delExtension = scoreHandler.GetPositionalIndelCodonScore(pos, false)
+		}
+	}
+	return result
+}
+
+func (self *handler) GetPositionalIndelCodonScore(position int, isInsertion bool) (int, int) {
+	if idx := uint(position); idx < uint(len(self.indelCodonScores)) {
+		scores := &self.indelCodonScores[idx]
+		if isInsertion {
+			return scores.insOpening, scores.insExtension
+		}
+		return scores.delOpening, scores.delExtension
+	}
+	return self.ScoreHandler.GetPositionalIndelCodonScore(position, isInsertion)
+}
+
+// GetExpectedFrameShift only knows the positions of the reference
+func (self *handler) GetExpectedFrameShift(position int) int {
+	if idx := uint(position); idx < uint(len(self.expectedFrameShifts)) {
+		return self.expectedFrameShifts[idx]
+	}
+	return 0
+}
+
+// noCodonScores has no tables, so that each codon of the query is
+// scored by the handler.
+type noCodonScores struct {
+	scoreHandler s.ScoreHandler
+}
+
+func (self noCodonScores) GetCodonScores(position int, ref a.AminoAcid) *s.CodonScores {
+	return nil
+}
+
+func (self noCodonScores) GetSubstitutionScoreNoCache(
+	position int,
+	base1 n.NucleicAcid,
+	base2 n.NucleicAcid,
+	base3 n.NucleicAcid,
+	ref a.AminoAcid) int {
+	return self.scoreHandler.GetSubstitutionScore(position, base1, base2, base3, ref)
+}
+
+type unscaledScores struct{}
+
+func (self unscaledScores) GetScoreScale() int {
+	return 1
+}
+
+type defaultSettings struct{}
+
+func (self defaultSettings) GetAlignmentMode() ap.AlignmentMode {
+	return ap.FreeEnds
+}
+
+func (self defaultSettings) GetBandWidth() int {
+	return 0
+}
+
+func (self defaultSettings) GetMinUnsequencedCodons() int {
+	return ap.DefaultMinUnsequencedCodons
+}
+
+type noAnnotations struct{}
+
+func (self noAnnotations) GetRegions() []ap.Region {
+	return nil
+}
+
+func (self noAnnotations) GetReferenceCodon(position int) (c.Codon, bool) {
+	return c.Codon{}, false
+}
+
+func (self noAnnotations) GetExpectedFrameShift(position int) int {
+	return 0
+}
+
+// allMatched matches codons like m.AllMatched, in the genetic code of
+// the handler
+type allMatched struct {
+	code *c.GeneticCode
+}
+
+func (self allMatched) IsMatchingCodon(position int, codon c.Codon, ref a.AminoAcid) bool {
+	return m.AllMatchedIn(self.code)(position, codon, ref)
+}
+
+// geneticCodeOf returns the genetic code of scoreHandler, which is the
+// standard one unless it's a Translator.
+func geneticCodeOf(scoreHandler s.ScoreHandler) *c.GeneticCode {
+	if translator, ok := scoreHandler.(s.Translator); ok {
+		return translator.GetGeneticCode()
+	}
+	return c.StandardCode
+}
+
+type fixedCode struct {
+	code *c.GeneticCode
+}
+
+func (self fixedCode) GetGeneticCode() *c.GeneticCode {
+	return self.code
+}
+
+type fixedMatrix struct {
+	matrix *d.SubstitutionMatrix
+}
+
+func (self fixedMatrix) GetSubstitutionMatrix() *d.SubstitutionMatrix {
+	return self.matrix
+}
@@ -0,0 +1,94 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	s "github.com/hivdb/nucamino/scorehandler"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+// baseHandler only implements the methods of s.ScoreHandler, so that
+// the aligner falls back to the defaults of the optional interfaces.
+type baseHandler struct {
+	s.ScoreHandler
+}
+
+func TestBaseScoreHandler(t *testing.T) {
+	general := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	expected, _ := NewAlignment(NSEQ, ASEQ, general)
+	aligned, err := NewAlignment(NSEQ, ASEQ, baseHandler{general})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	report, expectedReport := aligned.GetReport(), expected.GetReport()
+	if !reflect.DeepEqual(report.Mutations, expectedReport.Mutations) {
+		t.Errorf(MSG_NOT_EQUAL, expectedReport.Mutations, report.Mutations)
+	}
+	if !reflect.DeepEqual(report.FrameShifts, expectedReport.FrameShifts) {
+		t.Errorf(MSG_NOT_EQUAL, expectedReport.FrameShifts, report.FrameShifts)
+	}
+	// the scores of the handler aren't divided by its scale
+	if expect := expectedReport.Score * 100; report.Score != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, report.Score)
+	}
+	assertSiteScores(t, report)
+}
+
+func TestCodonScoreTables(t *testing.T) {
+	// position 1 is scored by a PSSM and position 2 by the matrix
+	profile := pssmProfile(ASEQ, 1)
+	for _, policy := range []ap.AmbiguousCodonPolicy{
+		ap.AverageAmbiguousCodons, ap.BestAmbiguousCodons, ap.WorstAmbiguousCodons} {
+		profile.AmbiguousCodonPolicy = policy
+		handler := h.New(ap.Gene("A"), profile)
+		for position := 1; position <= 2; position++ {
+			for _, ref := range a.AminoAcids {
+				table := handler.GetCodonScores(position, ref)
+				for _, na1 := range n.NucleicAcids {
+					for _, na2 := range n.NucleicAcids {
+						for _, na3 := range n.NucleicAcids {
+							expect := handler.GetSubstitutionScoreNoCache(position, na1, na2, na3, ref)
+							if score := table[na1][na2][na3]; score != expect {
+								t.Errorf(MSG_NOT_EQUAL, expect, score)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPositionalIndelScoresBeyondReference(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneIndelScores = ap.GenePositionalIndelScores{
+			"A": ap.PositionalIndelScores{
+				0:   [2]int{3, 1},
+				500: [2]int{5, 1},
+			},
+		}
+	})
+	testCases := []struct {
+		position    int
+		isInsertion bool
+		expect      [2]int
+	}{
+		// zero stands for both insertions and deletions
+		{0, true, [2]int{300, 100}},
+		{0, false, [2]int{300, 100}},
+		{1, true, [2]int{0, 200}},
+		{500, true, [2]int{500, 100}},
+		{500, false, [2]int{0, 200}},
+		{501, true, [2]int{0, 200}},
+		{-1, true, [2]int{0, 200}},
+	}
+	for _, testCase := range testCases {
+		opening, extension := handler.GetPositionalIndelCodonScore(testCase.position, testCase.isInsertion)
+		if scores := [2]int{opening, extension}; scores != testCase.expect {
+			t.Errorf(MSG_NOT_EQUAL, testCase.expect, scores)
+		}
+	}
+}
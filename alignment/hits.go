@@ -0,0 +1,97 @@
+package alignment
+
+import (
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// A hitSegment is a part [start, end) of the query which doesn't
+// overlap any reported hit, along with its best alignment.
+type hitSegment struct {
+	start int
+	end   int
+	hit   *Alignment
+}
+
+// AlignHits reports up to maxHits non-overlapping alignments of the
+// same reference within nSeq, best first. After each hit, the region it
+// covers is masked by aligning the parts of the query left and right of
+// it separately. The search stops once the best remaining hit scores
+// lower than minScore, in the units of the alignment profile (like
+// AlignmentReport.Score), or doesn't score at all. The first hit is
+// always reported. Parts of the query which don't align are skipped,
+// but any other error of align, such as a *TimeoutError, is returned
+// rather than a list of hits cut short.
+func AlignHits(nSeq []n.NucleicAcid, align AlignFunc, maxHits int, minScore float64) ([]*Alignment, error) {
+	first, err := align(nSeq)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		hits     = []*Alignment{first}
+		segments []*hitSegment
+		last     = &hitSegment{start: 0, end: len(nSeq), hit: first}
+	)
+	for len(hits) < maxHits {
+		parts, err := alignAroundHit(nSeq, align, last)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, parts...)
+		bestIdx := -1
+		for idx, segment := range segments {
+			if bestIdx == -1 || segment.hit.maxScore > segments[bestIdx].hit.maxScore {
+				bestIdx = idx
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+		last = segments[bestIdx]
+		if last.hit.maxScore <= 0 || last.hit.report.Score < minScore {
+			break
+		}
+		segments = append(segments[:bestIdx], segments[bestIdx+1:]...)
+		hits = append(hits, last.hit)
+	}
+	return hits, nil
+}
+
+// alignAroundHit aligns the parts of the segment left and right of its
+// hit. Parts which don't align are dropped.
+func alignAroundHit(nSeq []n.NucleicAcid, align AlignFunc, segment *hitSegment) ([]*hitSegment, error) {
+	firstNA, lastNA := segment.hit.report.getNARange()
+	parts := [2]*hitSegment{
+		{start: segment.start, end: firstNA - 1},
+		{start: lastNA, end: segment.end},
+	}
+	result := make([]*hitSegment, 0, 2)
+	for _, part := range parts {
+		if part.end-part.start < 3 {
+			continue
+		}
+		hit, err := align(nSeq[part.start:part.end])
+		if isUnaligned(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		start := part.start
+		hit.report.remapNAPositions(func(pos int) int {
+			return pos + start
+		})
+		part.hit = hit
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+// getNARange returns the first and the last nucleotide positions of
+// the report in ascending order; they are swapped for reports of the
+// reverse complement.
+func (self *AlignmentReport) getNARange() (int, int) {
+	if self.FirstNA > self.LastNA {
+		return self.LastNA, self.FirstNA
+	}
+	return self.FirstNA, self.LastNA
+}
@@ -0,0 +1,139 @@
+package alignment
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAlignHits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aseq := randomAminoAcids(rnd, 120)
+	align := func(nseq []n.NucleicAcid) (*Alignment, error) {
+		return NewAlignment(nseq, aseq, handler)
+	}
+	contig := randomQuery(rnd, randomAminoAcids(rnd, 100))
+	firstStart := len(contig)
+	contig = append(contig, randomQuery(rnd, aseq)...)
+	firstEnd := len(contig)
+	contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 100))...)
+	secondStart := len(contig)
+	contig = append(contig, randomQuery(rnd, aseq)...)
+	secondEnd := len(contig)
+	contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 100))...)
+
+	hits, err := AlignHits(contig, align, 3, 50)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		t.FailNow()
+	}
+	if len(hits) != 2 {
+		t.Errorf(MSG_NOT_EQUAL, 2, len(hits))
+		t.FailNow()
+	}
+	inRange := func(report *AlignmentReport, start int, end int) bool {
+		return report.FirstNA > start && report.LastNA <= end
+	}
+	for _, hit := range hits {
+		report := hit.GetReport()
+		if !inRange(report, firstStart, firstEnd) && !inRange(report, secondStart, secondEnd) {
+			t.Errorf("Unexpected hit at %d-%d", report.FirstNA, report.LastNA)
+		}
+	}
+	firstNA0, lastNA0 := hits[0].GetReport().getNARange()
+	firstNA1, lastNA1 := hits[1].GetReport().getNARange()
+	if firstNA0 <= lastNA1 && firstNA1 <= lastNA0 {
+		t.Errorf("Expected hits not to overlap")
+	}
+
+	hits, _ = AlignHits(contig, align, 1, 50)
+	expected, _ := align(contig)
+	if len(hits) != 1 || !reflect.DeepEqual(hits[0].GetReport(), expected.GetReport()) {
+		t.Errorf(MSG_NOT_EQUAL, []*Alignment{expected}, hits)
+	}
+}
+
+func TestAlignHitsWeakerHit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aseq := randomAminoAcids(rnd, 300)
+	align := func(nseq []n.NucleicAcid) (*Alignment, error) {
+		return NewAlignment(nseq, aseq, handler)
+	}
+	// a complete copy of the reference and a short amplicon of it,
+	// which scores far lower than the copy
+	contig := randomQuery(rnd, aseq)
+	contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 100))...)
+	ampliconStart := len(contig)
+	contig = append(contig, randomQuery(rnd, aseq[100:140])...)
+	ampliconEnd := len(contig)
+	contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 100))...)
+
+	hits, _ := AlignHits(contig, align, 3, 50)
+	if len(hits) != 2 {
+		t.Fatalf(MSG_NOT_EQUAL, 2, len(hits))
+	}
+	first, second := hits[0].GetReport(), hits[1].GetReport()
+	if second.Score > first.Score/4 {
+		t.Errorf("Expected the amplicon to score far lower than the copy: %v, %v", second.Score, first.Score)
+	}
+	if second.FirstNA > ampliconStart || second.LastNA < ampliconEnd {
+		t.Errorf("Expected the second hit to cover the amplicon at %d-%d", ampliconStart+1, ampliconEnd)
+	}
+	if hits, _ = AlignHits(contig, align, 3, second.Score+1); len(hits) != 1 {
+		t.Errorf(MSG_NOT_EQUAL, 1, len(hits))
+	}
+}
+
+func TestAlignHitsInterrupted(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aseq := randomAminoAcids(rnd, 120)
+	contig := randomQuery(rnd, aseq)
+	contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 100))...)
+	contig = append(contig, randomQuery(rnd, aseq)...)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		ctx    context.Context
+		limits Limits
+		expect error
+	}{
+		{canceled, Limits{}, context.Canceled},
+		{context.Background(), Limits{Timeout: time.Nanosecond}, &TimeoutError{time.Nanosecond}},
+	}
+	for _, testCase := range testCases {
+		// only the first hit is found before the alignments are
+		// interrupted
+		calls := 0
+		align := func(nseq []n.NucleicAcid) (*Alignment, error) {
+			calls++
+			if calls == 1 {
+				return NewAlignment(nseq, aseq, handler)
+			}
+			return NewAlignmentContext(testCase.ctx, nseq, aseq, handler, testCase.limits)
+		}
+		hits, err := AlignHits(contig, align, 3, 50)
+		if hits != nil || !reflect.DeepEqual(err, testCase.expect) {
+			t.Errorf(MSG_NOT_EQUAL, testCase.expect, err)
+		}
+	}
+}
+
+func TestGetNARange(t *testing.T) {
+	firstNA, lastNA := (&AlignmentReport{FirstNA: 3, LastNA: 5}).getNARange()
+	if firstNA != 3 || lastNA != 5 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{3, 5}, [2]int{firstNA, lastNA})
+	}
+	// reverse complement reports have their NA positions swapped
+	firstNA, lastNA = (&AlignmentReport{FirstNA: 9, LastNA: 8}).getNARange()
+	if firstNA != 8 || lastNA != 9 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{8, 9}, [2]int{firstNA, lastNA})
+	}
+}
@@ -0,0 +1,107 @@
+package alignment
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxFullMatrixCells is the default of Limits.MaxFullMatrixCells
+const DefaultMaxFullMatrixCells = 4 << 20
+
+// Limits bound the resources spent on one alignment. A zero value means
+// no limit.
+type Limits struct {
+	// Maximum number of cells of the dynamic programming matrix, which
+	// has one row per reference amino acid and one column per query
+	// nucleotide or amino acid (plus one of each). Both the time and
+	// the memory an alignment takes grow with it.
+	MaxMatrixCells int
+	// Maximum wall time of an alignment
+	Timeout time.Duration
+	// Alignments whose traceback matrix would hold more cells than
+	// this are traced back in linear memory, which takes about twice as
+	// long (see BenchmarkAlignmentLinear1000 and
+	// BenchmarkAlignmentLinear3000). Zero means
+	// DefaultMaxFullMatrixCells rather than no limit. Protein
+	// alignments only keep one byte per cell of their traceback matrix,
+	// and are always traced back in full.
+	MaxFullMatrixCells int
+}
+
+// MatrixTooLargeError is returned when the matrix of an alignment
+// would have more cells than Limits.MaxMatrixCells. Nothing was
+// allocated for the alignment.
+type MatrixTooLargeError struct {
+	Cells    int
+	MaxCells int
+}
+
+func (self *MatrixTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"alignment matrix too large (%d cells > %d)",
+		self.Cells, self.MaxCells)
+}
+
+// TimeoutError is returned when an alignment takes longer than
+// Limits.Timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (self *TimeoutError) Error() string {
+	return fmt.Sprintf("alignment timed out after %v", self.Timeout)
+}
+
+func (self *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+func (self Limits) checkMatrixCells(nSeqLen int, aSeqLen int) error {
+	cells := (nSeqLen + 1) * (aSeqLen + 1)
+	if self.MaxMatrixCells > 0 && cells > self.MaxMatrixCells {
+		return &MatrixTooLargeError{cells, self.MaxMatrixCells}
+	}
+	return nil
+}
+
+// maxFullMatrixCells returns the number of cells above which the
+// traceback is done in linear memory.
+func (self Limits) maxFullMatrixCells() int {
+	if self.MaxFullMatrixCells > 0 {
+		return self.MaxFullMatrixCells
+	}
+	return DefaultMaxFullMatrixCells
+}
+
+// withTimeout returns ctx bounded by the timeout of the limits.
+func (self Limits) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if self.Timeout > 0 {
+		return context.WithTimeout(ctx, self.Timeout)
+	}
+	return ctx, func() {}
+}
+
+// contextError returns the error of an alignment whose context is
+// done: a TimeoutError if the limits ran out, or else the error of the
+// parent context.
+func (self Limits) contextError(parent context.Context, ctx context.Context) error {
+	if parent.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{self.Timeout}
+	}
+	return ctx.Err()
+}
+
+// isDone reports whether ctx is done. The passes of an alignment check
+// it once per row, and report false as soon as it is.
+func isDone(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,119 @@
+package alignment
+
+import (
+	"context"
+	"errors"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatrixTooLarge(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	limits := Limits{MaxMatrixCells: 57 * 20}
+	result, err := NewAlignmentContext(context.Background(), NSEQ, ASEQ, handler, limits)
+	expect := &MatrixTooLargeError{58 * 20, 57 * 20}
+	if result != nil || !reflect.DeepEqual(err, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, err)
+	}
+	limits.MaxMatrixCells = 58 * 20
+	if _, err = NewAlignmentContext(context.Background(), NSEQ, ASEQ, handler, limits); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	_, err = NewProteinAlignmentContext(
+		context.Background(), a.ReadString("TVLVGPTPVNIIGRNLLTQ"), ASEQ, handler, Limits{MaxMatrixCells: 10})
+	if _, ok := err.(*MatrixTooLargeError); !ok {
+		t.Errorf("Expected a MatrixTooLargeError but received %v", err)
+	}
+}
+
+func TestProteinMaxFullMatrixCells(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	query := a.ReadString("TVLVGPTPVNIIGRNLLTQ")
+	full, err := NewProteinAlignmentContext(context.Background(), query, ASEQ, handler, Limits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// protein alignments are always traced back in full
+	limited, err := NewProteinAlignmentContext(
+		context.Background(), query, ASEQ, handler, Limits{MaxFullMatrixCells: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(full.GetReport(), limited.GetReport()) {
+		t.Errorf(MSG_NOT_EQUAL, full.GetReport(), limited.GetReport())
+	}
+}
+
+func TestMaxFullMatrixCells(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	aseq := randomAminoAcids(rnd, 100)
+	nseq := randomQuery(rnd, aseq)
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	full, _ := NewAlignmentContext(context.Background(), nseq, aseq, handler, Limits{})
+	if len(full.moves) == 0 {
+		t.Errorf("Expected the full matrix to be traced back")
+	}
+	linear, _ := NewAlignmentContext(
+		context.Background(), nseq, aseq, handler, Limits{MaxFullMatrixCells: 1})
+	if len(linear.moves) != 0 {
+		t.Errorf("Expected linear traceback to be used")
+	}
+	if !reflect.DeepEqual(full.GetReport(), linear.GetReport()) {
+		t.Errorf(MSG_NOT_EQUAL, full.GetReport(), linear.GetReport())
+	}
+}
+
+func TestAlignmentTimeout(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	aseq := randomAminoAcids(rnd, 300)
+	nseq := randomQuery(rnd, aseq)
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	limits := Limits{Timeout: time.Nanosecond}
+	result, err := NewAlignmentContext(context.Background(), nseq, aseq, handler, limits)
+	expect := &TimeoutError{time.Nanosecond}
+	if result != nil || !reflect.DeepEqual(err, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the error to be a deadline exceeded")
+	}
+	_, err = NewProteinAlignmentContext(context.Background(), aseq, aseq, handler, limits)
+	if !reflect.DeepEqual(err, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, err)
+	}
+}
+
+func TestAlignmentCanceled(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limits := Limits{Timeout: time.Hour}
+	result, err := NewAlignmentContext(ctx, NSEQ, ASEQ, handler, limits)
+	if result != nil || err != context.Canceled {
+		t.Errorf(MSG_NOT_EQUAL, context.Canceled, err)
+	}
+}
+
+func TestAlignerAfterInterruption(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligner := NewAligner(ASEQ, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, seq := range linearTestSeqs {
+		nseq := n.ReadString(seq)
+		if _, err := aligner.AlignContext(ctx, nseq); err != context.Canceled {
+			t.Errorf(MSG_NOT_EQUAL, context.Canceled, err)
+		}
+		expect, _ := NewAlignment(nseq, ASEQ, handler)
+		report, _ := aligner.Align(nseq)
+		if !reflect.DeepEqual(expect.GetReport(), report) {
+			t.Errorf(MSG_NOT_EQUAL, expect.GetReport(), report)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package alignment
+
+// This file implements a divide-and-conquer (Hirschberg-style)
+// traceback which needs only O(nSeqLen + aSeqLen) memory instead of
+// the full traceback matrix.
+//
+// A window of the matrix is split at its middle row: the forward pass
+// scores the paths from the start of the window to each cell of the
+// middle row, and a backward pass scores the paths from each of those
+// cells to the end of the window. The cell where their sum is the
+// highest is where the optimal path leaves the middle row; the
+// sub-problems on both sides of it are then solved recursively until
+// they are small enough to be traced back directly.
+//
+// The report must be identical to the one produced from the full
+// matrix, whose traceback breaks ties between paths of the same score
+// by the order in which the forward pass compares its candidates. The
+// sums can't tell which of several optimal paths that is. So when more
+// than one cell of the middle row reaches the highest sum, or a path
+// starting below the middle row scores as high, the forward pass
+// instead carries the index of the cell where each path leaves the
+// middle row along with its score (see findMiddleCrossing), which
+// yields the exact cell. Since the forward choices are never changed by
+// restricting the DP to a sub-rectangle containing the optimal path,
+// the resulting report is identical to the one produced from the full
+// matrix.
+
+// Windows with at most this many cells are traced back directly
+var maxDirectWindowCells = 1 << 16
+
+// Scores of cells outside of a window or of the band, or which the
+// alignment mode makes unreachable. It's far lower than any real score
+// while leaving enough room to add penalties without overflow.
+const windowNegInf = negInf / 2
+
+type window struct {
+	startN, startA int
+	endN, endA     int
+	// matrix index of the cell that the path must start from, or -1
+	seed      int
+	seedScore int
+}
+
+func (self *window) cols() int {
+	return self.endN - self.startN + 1
+}
+
+func (self *window) rows() int {
+	return self.endA - self.startA + 1
+}
+
+// windowRows holds a row of a window for all score types, along with
+// the matrix index of each cell's predecessor.
+type windowRows struct {
+	scores  [scoreTypeCount][]int
+	prevIdx [scoreTypeCount][]int
+}
+
+// calcScoreWindowForward runs the forward pass restricted to the
+// window, calling onRow once every row was filled. It returns false if
+// the alignment was interrupted.
+func (self *Alignment) calcScoreWindowForward(w *window, onRow func(j int, rows *windowRows)) bool {
+	var (
+		cols = w.cols()
+		// the cells left of the window score windowNegInf
+		row  = forwardRow{base: w.startN - maxEdgeOffset, edges: &self.workspace.edges}
+		rows = &windowRows{}
+	)
+	for st := 0; st < scoreTypeCount; st++ {
+		row.scores[st] = make([]int, maxEdgeOffset+cols)
+		row.prevScores[st] = make([]int, maxEdgeOffset+cols)
+		row.moves[st] = make([]tMove, maxEdgeOffset+cols)
+		rows.prevIdx[st] = make([]int, cols)
+		for k := range row.scores[st] {
+			row.scores[st][k], row.prevScores[st][k] = windowNegInf, windowNegInf
+		}
+	}
+	row.codonScores = make([]int, maxEdgeOffset+cols)
+	for j := w.startA; j <= w.endA; j++ {
+		if isDone(self.ctx) {
+			return false
+		}
+		row.swap()
+		lo, hi := self.getBandRange(j)
+		if lo < w.startN {
+			lo = w.startN
+		}
+		if hi > w.endN {
+			hi = w.endN
+		}
+		if lo <= hi {
+			self.calcRowForward(&row, j, lo, hi, w.seed, w.seedScore)
+		}
+		for st := 0; st < scoreTypeCount; st++ {
+			rows.scores[st] = row.scores[st][maxEdgeOffset:]
+			for k := 0; k < cols; k++ {
+				i := w.startN + k
+				if i < lo || i > hi {
+					rows.scores[st][k] = windowNegInf
+					rows.prevIdx[st][k] = self.getMatrixIndex(tScoreType(st), i, j)
+				} else {
+					rows.prevIdx[st][k] = self.getMoveTarget(tScoreType(st), i, j, row.moves[st][k+maxEdgeOffset])
+				}
+			}
+		}
+		onRow(j, rows)
+	}
+	return true
+}
+
+// traceWindowDirectly traces back the path from endIdx to the start of
+// the window using a traceback matrix of the window's size.
+func (self *Alignment) traceWindowDirectly(w *window, endIdx int) ([]int, bool) {
+	var (
+		cols     = w.cols()
+		layerLen = cols * w.rows()
+		prevMt   = make([]int, scoreTypeCount*layerLen)
+		path     = make([]int, 0, w.rows()+cols)
+	)
+	ok := self.calcScoreWindowForward(w, func(j int, rows *windowRows) {
+		offset := (j - w.startA) * cols
+		for st := 0; st < scoreTypeCount; st++ {
+			copy(prevMt[st*layerLen+offset:], rows.prevIdx[st])
+		}
+	})
+	if !ok {
+		return nil, false
+	}
+	for mtIdx := endIdx; ; {
+		path = append(path, mtIdx)
+		scoreType, posN, posA := self.getTypedPos(mtIdx)
+		prevIdx := prevMt[int(scoreType)*layerLen+(posA-w.startA)*cols+posN-w.startN]
+		if mtIdx == w.seed || self.isPathStart(mtIdx, prevIdx) {
+			break
+		}
+		mtIdx = prevIdx
+	}
+	// reverse the path so it starts from the beginning of the window
+	for left, right := 0, len(path)-1; left < right; left, right = left+1, right-1 {
+		path[left], path[right] = path[right], path[left]
+	}
+	return path, true
+}
+
+// findMiddleCrossing returns the last cell in row midA of the optimal
+// path ending at endIdx, or -1 if the path starts after row midA.
+// The forward score of that cell is returned as well.
+func (self *Alignment) findMiddleCrossing(w *window, midA int, endIdx int) (int, int, bool) {
+	var (
+		cols       = w.cols()
+		crossings  [scoreTypeCount][]int
+		prevCross  [scoreTypeCount][]int
+		midScores  [scoreTypeCount][]int
+		crossIdx   = -1
+		crossScore = negInf
+	)
+	for st := 0; st < scoreTypeCount; st++ {
+		crossings[st] = make([]int, cols)
+		prevCross[st] = make([]int, cols)
+		midScores[st] = make([]int, cols)
+	}
+	ok := self.calcScoreWindowForward(w, func(j int, rows *windowRows) {
+		if j < midA {
+			return
+		}
+		for st := 0; st < scoreTypeCount; st++ {
+			crossings[st], prevCross[st] = prevCross[st], crossings[st]
+		}
+		if j == midA {
+			for st := 0; st < scoreTypeCount; st++ {
+				for k := 0; k < cols; k++ {
+					crossings[st][k] = self.getMatrixIndex(tScoreType(st), w.startN+k, j)
+				}
+				copy(midScores[st], rows.scores[st])
+			}
+			return
+		}
+		for k := 0; k < cols; k++ {
+			// same order as the forward pass: INS, DEL and then GENERAL,
+			// which may refer to INS or DEL of the same cell
+			for _, st := range [scoreTypeCount]tScoreType{INS, DEL, GENERAL} {
+				crossing := -1
+				mtIdx := self.getMatrixIndex(st, w.startN+k, j)
+				if !self.isPathStart(mtIdx, rows.prevIdx[st][k]) {
+					prevType, prevN, prevA := self.getTypedPos(rows.prevIdx[st][k])
+					prevK := prevN - w.startN
+					if prevK >= 0 && prevA == j {
+						crossing = crossings[prevType][prevK]
+					} else if prevK >= 0 && prevA == j-1 {
+						crossing = prevCross[prevType][prevK]
+					}
+				}
+				crossings[st][k] = crossing
+			}
+		}
+	})
+	if !ok {
+		return -1, negInf, false
+	}
+	scoreType, posN, _ := self.getTypedPos(endIdx)
+	crossIdx = crossings[scoreType][posN-w.startN]
+	if crossIdx > -1 {
+		crossType, crossN, _ := self.getTypedPos(crossIdx)
+		crossScore = midScores[crossType][crossN-w.startN]
+	}
+	return crossIdx, crossScore, true
+}
+
+// isWindowScore reports whether score is the score of a path rather
+// than of a cell out of reach.
+func isWindowScore(score int) bool {
+	return score > windowNegInf/2
+}
+
+// calcScoreWindowBackward runs the backward pass from endIdx up to row
+// midA of the window, following the edges of the forward pass
+// backward. It returns the best score from each cell of row midA to
+// endIdx of the paths which leave the row right away, and the best
+// score to endIdx of the paths starting after row midA.
+func (self *Alignment) calcScoreWindowBackward(w *window, midA int, endIdx int) ([scoreTypeCount][]int, int, bool) {
+	var (
+		cols       = w.cols()
+		scores     [scoreTypeCount][]int
+		prevScores [scoreTypeCount][]int
+		edges      = &self.workspace.edges
+		lowerScore = windowNegInf
+	)
+	for st := 0; st < scoreTypeCount; st++ {
+		scores[st] = make([]int, cols)
+		prevScores[st] = make([]int, cols)
+		for k := range scores[st] {
+			scores[st][k] = windowNegInf
+		}
+	}
+	endType, endN, _ := self.getTypedPos(endIdx)
+	scores[endType][endN-w.startN] = 0
+	for j := w.endA; j > midA; j-- {
+		if isDone(self.ctx) {
+			return scores, lowerScore, false
+		}
+		self.selectCodonScores(j)
+		self.setRowEdges(edges, j)
+		for st := 0; st < scoreTypeCount; st++ {
+			for k := range prevScores[st] {
+				prevScores[st][k] = windowNegInf
+			}
+		}
+		lo, hi := self.getBandRange(j)
+		// a cell only passes its score on to its predecessors once all
+		// of its successors did, which are in the rows below or right
+		// of it, and GENERAL precedes INS and DEL of the same cell
+		for k := cols - 1; k >= 0; k-- {
+			i := w.startN + k
+			if i < lo || i > hi {
+				continue
+			}
+			for _, st := range [scoreTypeCount]tScoreType{GENERAL, INS, DEL} {
+				score := scores[st][k]
+				if !isWindowScore(score) {
+					continue
+				}
+				switch {
+				case st == INS && i == 0, st == DEL && i == 0 && self.isFreeStart(i, j):
+					// out of reach, see calcRowForward
+					continue
+				case st == GENERAL && self.isFreeStart(i, j):
+					// the path starts from this cell
+					if score > lowerScore {
+						lowerScore = score
+					}
+					continue
+				case st == GENERAL && i == 0:
+					// reached through leading deletions
+					pushScore(scores[DEL], k, score)
+					continue
+				case st == GENERAL && self.isLocal && score > lowerScore:
+					// a new local alignment may start from this cell
+					lowerScore = score
+				}
+				cellEdges, _ := edges.get(st, i == self.nSeqLen)
+				for idx := range cellEdges {
+					edge := &cellEdges[idx]
+					if !edge.leadsTo(i) {
+						continue
+					}
+					edgeScore := edge.score
+					if edge.isCodon {
+						edgeScore += self.getCodonScore(i)
+					}
+					target := moveTargets[edge.move]
+					targetScores := scores
+					if target.aOffset == 1 {
+						targetScores = prevScores
+					}
+					pushScore(targetScores[target.scoreType], k-target.nOffset, score+edgeScore)
+				}
+			}
+		}
+		scores, prevScores = prevScores, scores
+	}
+	return scores, lowerScore, true
+}
+
+// pushScore offers score to the cell at column k of a row, if it lies
+// in the window.
+func pushScore(scores []int, k int, score int) {
+	if k >= 0 && score > scores[k] {
+		scores[k] = score
+	}
+}
+
+// splitWindow returns the last cell in row midA of the optimal path
+// ending at endIdx and its forward score like findMiddleCrossing does,
+// but from the sum of the forward and the backward scores of the cells
+// of row midA. It fails when the sums don't tell the optimal path apart
+// from another one of the same score. The last value is false if the
+// alignment was interrupted.
+func (self *Alignment) splitWindow(w *window, midA int, endIdx int) (int, int, bool, bool) {
+	var (
+		cols       = w.cols()
+		upper      = *w
+		midScores  [scoreTypeCount][]int
+		crossIdx   = -1
+		crossScore = negInf
+		bestScore  = windowNegInf
+		bestCount  = 0
+	)
+	upper.endA = midA
+	ok := self.calcScoreWindowForward(&upper, func(j int, rows *windowRows) {
+		if j == midA {
+			for st := 0; st < scoreTypeCount; st++ {
+				midScores[st] = append([]int(nil), rows.scores[st]...)
+			}
+		}
+	})
+	if !ok {
+		return -1, negInf, false, false
+	}
+	backScores, lowerScore, ok := self.calcScoreWindowBackward(w, midA, endIdx)
+	if !ok {
+		return -1, negInf, false, false
+	}
+	for k := 0; k < cols; k++ {
+		for _, st := range [...]tScoreType{GENERAL, DEL} {
+			fwdScore, backScore := midScores[st][k], backScores[st][k]
+			if !isWindowScore(fwdScore) || !isWindowScore(backScore) {
+				continue
+			}
+			if score := fwdScore + backScore; score > bestScore {
+				bestScore, bestCount = score, 1
+				crossIdx = self.getMatrixIndex(st, w.startN+k, midA)
+				crossScore = fwdScore
+			} else if score == bestScore {
+				bestCount++
+			}
+		}
+	}
+	if isWindowScore(lowerScore) && lowerScore > bestScore {
+		// the path starts below the middle row
+		return -1, negInf, true, true
+	}
+	if bestCount != 1 || (isWindowScore(lowerScore) && lowerScore == bestScore) {
+		return -1, negInf, false, true
+	}
+	return crossIdx, crossScore, true, true
+}
+
+func (self *Alignment) traceWindow(w *window, endIdx int, path []int) ([]int, bool) {
+	if w.rows() <= 2 || w.rows()*w.cols() <= maxDirectWindowCells {
+		windowPath, ok := self.traceWindowDirectly(w, endIdx)
+		return append(path, windowPath...), ok
+	}
+	midA := (w.startA + w.endA) / 2
+	crossIdx, crossScore, split, ok := self.splitWindow(w, midA, endIdx)
+	if ok && !split {
+		crossIdx, crossScore, ok = self.findMiddleCrossing(w, midA, endIdx)
+	}
+	if !ok {
+		return path, false
+	}
+	if crossIdx == -1 {
+		// the path starts below the middle row
+		lower := &window{
+			startN: w.startN, startA: midA + 1,
+			endN: w.endN, endA: w.endA,
+			seed: -1,
+		}
+		return self.traceWindow(lower, endIdx, path)
+	}
+	_, crossN, crossA := self.getTypedPos(crossIdx)
+	upper := &window{
+		startN: w.startN, startA: w.startA,
+		endN: crossN, endA: crossA,
+		seed: w.seed, seedScore: w.seedScore,
+	}
+	lower := &window{
+		startN: crossN, startA: crossA,
+		endN: w.endN, endA: w.endA,
+		seed: crossIdx, seedScore: crossScore,
+	}
+	if path, ok = self.traceWindow(upper, crossIdx, path); !ok {
+		return path, false
+	}
+	// the crossing cell is the last cell of upper and the first of lower
+	path = path[:len(path)-1]
+	return self.traceWindow(lower, endIdx, path)
+}
+
+// calcLinearTraceback returns the traceback of the optimal path which
+// ends at (endPosN, endPosA), as a map from the matrix index of each
+// cell on the path to the matrix index of its predecessor. It returns
+// false if the alignment was interrupted.
+func (self *Alignment) calcLinearTraceback() (map[int]int, bool) {
+	w := &window{
+		startN: 0, startA: 0,
+		endN: self.endPosN, endA: self.endPosA,
+		seed: -1,
+	}
+	endIdx := self.getMatrixIndex(GENERAL, self.endPosN, self.endPosA)
+	path, ok := self.traceWindow(w, endIdx, make([]int, 0, self.nSeqLen+self.aSeqLen))
+	if !ok {
+		return nil, false
+	}
+	traceback := make(map[int]int, len(path))
+	traceback[path[0]] = path[0]
+	for idx := 1; idx < len(path); idx++ {
+		traceback[path[idx]] = path[idx-1]
+	}
+	return traceback, true
+}
@@ -0,0 +1,149 @@
+package alignment
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+var linearTestSeqs = []string{
+	"ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+	"ACAGTRTTAGTAGGACCTTTTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+	"ACAGTRTTAGTAGGACCTTTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+	"AAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAGAAATCTGTTGACYCAG",
+	"ACAGTRTTAGTAGGACCTACACCT   AACATAATTGGAAGAAATCTGTTGACYCA",
+	"ACAGTRTTAGTAGGACCTACACCTAACATAATTGGAAGAAAAAATCTGTTGACYCA",
+	"ACAGTRTTAGTAGGACCTACACCTttttttGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+	"ACAGTRTTAGTAGGACCTACACCTGCCAACgggATAATTGGAAGAAATCTGTTGACYCAG",
+	"ACAGTRTTAGTAGGACCcccTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+}
+
+// randomQuery back-translates aSeq and then introduces random
+// substitutions, indels and frameshifts.
+func randomQuery(rnd *rand.Rand, aSeq []a.AminoAcid) []n.NucleicAcid {
+	codonsOf := map[a.AminoAcid][]c.Codon{}
+	for codon, aa := range c.CodonToAminoAcidTable {
+		codonsOf[aa] = append(codonsOf[aa], codon)
+	}
+	randomNAs := func(length int) []n.NucleicAcid {
+		nas := make([]n.NucleicAcid, length)
+		for idx := range nas {
+			nas[idx] = n.NucleicAcids[rnd.Intn(4)]
+		}
+		return nas
+	}
+	nSeq := randomNAs(rnd.Intn(30))
+	for _, aa := range aSeq {
+		switch rnd.Intn(60) {
+		case 0:
+			continue
+		case 1:
+			nSeq = append(nSeq, randomNAs(1+rnd.Intn(6))...)
+		case 2, 3, 4:
+			nSeq = append(nSeq, randomNAs(3)...)
+			continue
+		}
+		codons := codonsOf[aa]
+		codon := codons[rnd.Intn(len(codons))]
+		nSeq = append(nSeq, codon.Base1, codon.Base2, codon.Base3)
+	}
+	return append(nSeq, randomNAs(rnd.Intn(30))...)
+}
+
+// withLinearTraceback runs fn with limits which trace every alignment
+// back in linear memory, down to the smallest windows.
+func withLinearTraceback(fn func(limits Limits)) {
+	origMaxDirectWindowCells := maxDirectWindowCells
+	maxDirectWindowCells = 0
+	defer func() {
+		maxDirectWindowCells = origMaxDirectWindowCells
+	}()
+	fn(Limits{MaxFullMatrixCells: 1})
+}
+
+func assertSameReports(t *testing.T, nseq []n.NucleicAcid, aseq []a.AminoAcid, handler *h.GeneralScoreHandler) {
+	full, _ := NewAlignment(nseq, aseq, handler)
+	var linear *Alignment
+	withLinearTraceback(func(limits Limits) {
+		linear, _ = NewAlignmentContext(context.Background(), nseq, aseq, handler, limits)
+	})
+	if full == nil || linear == nil {
+		if full != linear {
+			t.Errorf(MSG_NOT_EQUAL, full, linear)
+		}
+		return
+	}
+	if !linear.isSimpleAlignment && linear.linearTraceback == nil {
+		t.Errorf("Expected linear traceback to be used for %s", n.WriteString(nseq))
+	}
+	if !reflect.DeepEqual(full.GetReport(), linear.GetReport()) {
+		t.Errorf(MSG_NOT_EQUAL, full.GetReport(), linear.GetReport())
+	}
+}
+
+func TestLinearTraceback(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for _, seq := range linearTestSeqs {
+		assertSameReports(t, n.ReadString(seq), ASEQ, handler)
+	}
+}
+
+func TestLinearTracebackRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for round := 0; round < 20; round++ {
+		aseq := make([]a.AminoAcid, 50+rnd.Intn(150))
+		for idx := range aseq {
+			aseq[idx] = a.AminoAcids[rnd.Intn(a.NumAminoAcids)]
+		}
+		assertSameReports(t, randomQuery(rnd, aseq), aseq, handler)
+	}
+}
+
+func TestSplitWindow(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handlers := []*h.GeneralScoreHandler{handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneFrameShifts = map[ap.Gene][]ap.ExpectedFrameShift{"A": {{10, -1}, {20, -2}, {30, 1}, {40, 2}}}
+	})}
+	for _, mode := range allAlignmentModes {
+		handlers = append(handlers, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }))
+	}
+	splits, ties := 0, 0
+	for _, handler := range handlers {
+		for round := 0; round < 3; round++ {
+			aseq := randomAminoAcids(rnd, 50+rnd.Intn(50))
+			var aligned *Alignment
+			withLinearTraceback(func(limits Limits) {
+				aligned, _ = NewAlignmentContext(context.Background(), randomQuery(rnd, aseq), aseq, handler, limits)
+			})
+			if aligned == nil {
+				continue
+			}
+			// the buffers were released once aligned
+			aligned.workspace = &workspace{}
+			w := &window{endN: aligned.endPosN, endA: aligned.endPosA, seed: -1}
+			endIdx := aligned.getMatrixIndex(GENERAL, w.endN, w.endA)
+			for midA := rnd.Intn(5); midA < w.endA; midA += 5 {
+				crossIdx, crossScore, ok, _ := aligned.splitWindow(w, midA, endIdx)
+				if !ok {
+					ties++
+					continue
+				}
+				splits++
+				expectIdx, expectScore, _ := aligned.findMiddleCrossing(w, midA, endIdx)
+				if crossIdx != expectIdx || (crossIdx > -1 && crossScore != expectScore) {
+					t.Errorf(MSG_NOT_EQUAL, [2]int{expectIdx, expectScore}, [2]int{crossIdx, crossScore})
+				}
+			}
+		}
+	}
+	if ties > splits/10 {
+		t.Errorf("Expected few ties, got %d ties for %d splits", ties, splits)
+	}
+}
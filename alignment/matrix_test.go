@@ -0,0 +1,76 @@
+package alignment
+
+import (
+	"fmt"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"strings"
+	"testing"
+)
+
+// identityMatrix scores identical amino acids 9 and others -1
+func identityMatrix() *d.SubstitutionMatrix {
+	letters := "ARNDCQEGHILKMFPSTWYV"
+	text := "   " + strings.Join(strings.Split(letters, ""), "  ") + "\n"
+	for _, row := range letters {
+		text += string(row)
+		for _, col := range letters {
+			score := -1
+			if row == col {
+				score = 9
+			}
+			text += fmt.Sprintf(" %2d", score)
+		}
+		text += "\n"
+	}
+	matrix, err := d.ReadSubstitutionMatrix("identity", strings.NewReader(text))
+	if err != nil {
+		panic(err)
+	}
+	return matrix
+}
+
+func TestSubstitutionMatrix(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.SubstitutionMatrix = identityMatrix()
+	})
+	// codon 5 (GAA) is E instead of G
+	nseq := n.ReadString("ACAGTATTAGTAGAACCTACACCTGTAAACATAATTGGAAGAAATCTGTTGACTCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	for idx, site := range report.AlignedSites {
+		expect := 9.0
+		if idx == 4 {
+			expect = -1
+		}
+		if site.SubstitutionScore != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, site.SubstitutionScore)
+		}
+	}
+}
+
+func TestSimilarityOfSubstitutionMatrix(t *testing.T) {
+	// codon 14 (AAA) is K instead of R, which BLOSUM62 scores positively
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTAAACATAATTGGAAAAAATCTGTTGACTCAG")
+	query := a.ReadString("TVLVGPTPVNIIGKNLLTQ")
+	for _, matrix := range []*d.SubstitutionMatrix{nil, identityMatrix()} {
+		handler := handlerWith(func(profile *ap.AlignmentProfile) {
+			profile.SubstitutionMatrix = matrix
+		})
+		expect := 100.0
+		if matrix != nil {
+			expect = 100 * 18.0 / 19
+		}
+		aligned, _ := NewAlignment(nseq, ASEQ, handler)
+		if similarity := aligned.GetReport().Similarity; similarity != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, similarity)
+		}
+		protein, _ := NewProteinAlignment(query, ASEQ, handler)
+		if similarity := protein.GetReport().Similarity; similarity != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, similarity)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package alignment
+
+import (
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// codonMetrics accumulates the identity and similarity of the aligned
+// codons of an alignment. Similar amino acids are the ones scoring
+// positively in the substitution matrix of the alignment.
+type codonMetrics struct {
+	alignedCodons int
+	identical     float64
+	similar       float64
+}
+
+// addCodon adds the codon made of the first three of nas, which are
+// the nucleotides aligned to ref and followed by inserted ones if any.
+// Ambiguous codons are credited with the fraction of their unambiguous
+// codons that are identical or similar to ref, whatever the ambiguous
+// codon policy of the profile. Codons missing nucleotides are never
+// identical or similar. The codons are translated with the genetic code.
+func (self *codonMetrics) addCodon(
+	nas []n.NucleicAcid, ref a.AminoAcid,
+	code *c.GeneticCode, matrix *d.SubstitutionMatrix) {
+	self.alignedCodons++
+	if len(nas) < 3 {
+		return
+	}
+	var (
+		codon               = c.Codon{Base1: nas[0], Base2: nas[1], Base3: nas[2]}
+		ucodons             = codon.GetUnambiguousCodons()
+		identical, positive int
+	)
+	for _, ucodon := range ucodons {
+		if code.IsStopCodon(ucodon) {
+			continue
+		}
+		aa := code.ToAminoAcidUnsafe(ucodon)
+		if aa == ref {
+			identical++
+		}
+		if matrix.Lookup(aa, ref) > 0 {
+			positive++
+		}
+	}
+	self.identical += float64(identical) / float64(len(ucodons))
+	self.similar += float64(positive) / float64(len(ucodons))
+}
+
+// addAminoAcid adds the first of aas, which are the amino acids of a
+// query protein aligned to ref and followed by inserted ones if any.
+func (self *codonMetrics) addAminoAcid(aas []a.AminoAcid, ref a.AminoAcid, matrix *d.SubstitutionMatrix) {
+	self.alignedCodons++
+	if len(aas) == 0 {
+		return
+	}
+	if aas[0] == ref {
+		self.identical++
+	}
+	if matrix.Lookup(aas[0], ref) > 0 {
+		self.similar++
+	}
+}
+
+// add adds the codons of other, such as the ones of another site.
+func (self *codonMetrics) add(other codonMetrics) {
+	self.alignedCodons += other.alignedCodons
+	self.identical += other.identical
+	self.similar += other.similar
+}
+
+// percentages returns the identity and similarity of the aligned
+// codons in percent.
+func (self *codonMetrics) percentages() (float64, float64) {
+	if self.alignedCodons == 0 {
+		return 0, 0
+	}
+	total := float64(self.alignedCodons)
+	return 100 * self.identical / total, 100 * self.similar / total
+}
@@ -0,0 +1,38 @@
+package alignment
+
+import (
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"testing"
+)
+
+func TestCodonMetrics(t *testing.T) {
+	var metrics codonMetrics
+	// ACA (T) and ATA (I): I is not similar to T
+	metrics.addCodon(n.ReadString("AYA"), a.T, c.StandardCode, d.Blosum62)
+	// AAA (K) and AGA (R): K is similar to R
+	metrics.addCodon(n.ReadString("ARA"), a.R, c.StandardCode, d.Blosum62)
+	// TAA and TGA are both stop codons
+	metrics.addCodon(n.ReadString("TRA"), a.W, c.StandardCode, d.Blosum62)
+	// inserted codons are ignored
+	metrics.addCodon(n.ReadString("TGGTAA"), a.W, c.StandardCode, d.Blosum62)
+	// partial codons are never identical
+	metrics.addCodon(n.ReadString("TG"), a.W, c.StandardCode, d.Blosum62)
+	identity, similarity := metrics.percentages()
+	if identity != 100*2.0/5 {
+		t.Errorf(MSG_NOT_EQUAL, 100*2.0/5, identity)
+	}
+	if similarity != 100*2.5/5 {
+		t.Errorf(MSG_NOT_EQUAL, 100*2.5/5, similarity)
+	}
+}
+
+func TestCodonMetricsEmpty(t *testing.T) {
+	var metrics codonMetrics
+	identity, similarity := metrics.percentages()
+	if identity != 0 || similarity != 0 {
+		t.Errorf(MSG_NOT_EQUAL, [2]float64{0, 0}, [2]float64{identity, similarity})
+	}
+}
@@ -0,0 +1,65 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+)
+
+// This file decides where a path may start and end, according to the
+// alignment mode of the score handler.
+//
+// A path starts for free from cell (0, 0), from the first column when
+// the query ends are free, and from the first row when the reference
+// ends are free. Other cells of the first row and column are reached
+// through leading insertions or deletions, which are penalized like
+// any other gap. Likewise, a path may end in the last row or column
+// only if the corresponding ends are free. In local mode a path may
+// start and end at any cell, since the score of a cell never drops
+// below zero.
+
+// alignmentModeEnds returns which ends of the sequences are free in
+// the alignment mode.
+func alignmentModeEnds(mode ap.AlignmentMode) (freeQueryEnds, freeReferenceEnds, isLocal bool) {
+	freeQueryEnds = mode == ap.FreeEnds || mode == ap.FreeQueryEnds
+	freeReferenceEnds = mode == ap.FreeEnds || mode == ap.FreeReferenceEnds
+	isLocal = mode == ap.Local
+	return
+}
+
+func (self *Alignment) setAlignmentMode(mode ap.AlignmentMode) {
+	self.freeQueryEnds, self.freeReferenceEnds, self.isLocal = alignmentModeEnds(mode)
+}
+
+// isFreeStart reports whether a path may start from (posN, posA)
+// without any penalty.
+func (self *Alignment) isFreeStart(posN int, posA int) bool {
+	if posA == 0 && (posN == 0 || self.freeQueryEnds || self.isLocal) {
+		return true
+	}
+	return posN == 0 && (self.freeReferenceEnds || self.isLocal)
+}
+
+// isFreeEnd reports whether a path may end at (posN, posA).
+func (self *Alignment) isFreeEnd(posN int, posA int) bool {
+	if self.isLocal {
+		return true
+	}
+	if posA == self.aSeqLen && (posN == self.nSeqLen || self.freeQueryEnds) {
+		return true
+	}
+	return posN == self.nSeqLen && self.freeReferenceEnds
+}
+
+// hasFreeEndGaps reports whether gaps at the ends of the alignment are
+// free and left out of the report, which is only the case when both
+// ends are free. In the other modes, the choice of the first and the
+// last cell of the path already frees the ends.
+func (self *Alignment) hasFreeEndGaps() bool {
+	return self.freeQueryEnds && self.freeReferenceEnds
+}
+
+// isPathStart reports whether the path traced back to mtIdx, whose
+// predecessor is prevIdx, starts there.
+func (self *Alignment) isPathStart(mtIdx int, prevIdx int) bool {
+	_, posN, posA := self.getTypedPos(mtIdx)
+	return prevIdx == mtIdx || self.isFreeStart(posN, posA)
+}
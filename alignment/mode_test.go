@@ -0,0 +1,150 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"testing"
+)
+
+var allAlignmentModes = []ap.AlignmentMode{
+	ap.FreeEnds, ap.FreeQueryEnds, ap.FreeReferenceEnds, ap.Global, ap.Local,
+}
+
+func alignWithMode(t *testing.T, nseq []n.NucleicAcid, mode ap.AlignmentMode) *AlignmentReport {
+	result, err := NewAlignment(nseq, ASEQ, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }))
+	if err != nil {
+		t.Errorf("Unexpected error in mode %v: %v", mode, err)
+		t.FailNow()
+	}
+	return result.GetReport()
+}
+
+func TestAlignmentModeOfGene(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.AlignmentMode = ap.Global
+	profile.GeneAlignmentModes = map[ap.Gene]ap.AlignmentMode{ap.Gene("B"): ap.Local}
+	if mode := h.New(ap.Gene("A"), profile).GetAlignmentMode(); mode != ap.Global {
+		t.Errorf(MSG_NOT_EQUAL, ap.Global, mode)
+	}
+	if mode := h.New(ap.Gene("B"), profile).GetAlignmentMode(); mode != ap.Local {
+		t.Errorf(MSG_NOT_EQUAL, ap.Local, mode)
+	}
+}
+
+func TestFreeQueryEndsMode(t *testing.T) {
+	// the query misses the first two and the last codons of the reference
+	nseq := NSEQ[6:54]
+	report := alignWithMode(t, nseq, ap.FreeEnds)
+	if report.FirstAA != 3 || report.LastAA != 18 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{3, 18}, [2]int{report.FirstAA, report.LastAA})
+	}
+	report = alignWithMode(t, nseq, ap.FreeQueryEnds)
+	if report.FirstAA != 1 || report.LastAA != 19 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, 19}, [2]int{report.FirstAA, report.LastAA})
+	}
+	for _, pos := range []int{1, 2, 19} {
+		deleted := false
+		for _, mutation := range report.Mutations {
+			if mutation.Position == pos && mutation.IsDeletion {
+				deleted = true
+			}
+		}
+		if !deleted {
+			t.Errorf("Expected a deletion at %d in %v", pos, report.Mutations)
+		}
+	}
+}
+
+func TestFreeReferenceEndsMode(t *testing.T) {
+	// the query covers only a part of the reference and ends with two
+	// extra nucleotides
+	nseq := n.ReadString(n.WriteString(NSEQ[6:54]) + "GG")
+	report := alignWithMode(t, nseq, ap.FreeEnds)
+	if report.LastNA == len(nseq) {
+		t.Errorf("Expected the end of the query to be left out")
+	}
+	report = alignWithMode(t, nseq, ap.FreeReferenceEnds)
+	if report.FirstNA != 1 || report.LastNA != len(nseq) {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, len(nseq)}, [2]int{report.FirstNA, report.LastNA})
+	}
+	if report.FirstAA != 3 {
+		t.Errorf(MSG_NOT_EQUAL, 3, report.FirstAA)
+	}
+}
+
+func TestGlobalMode(t *testing.T) {
+	nseq := n.ReadString("GGG" + n.WriteString(NSEQ[6:54]) + "GG")
+	report := alignWithMode(t, nseq, ap.Global)
+	if report.FirstNA != 1 || report.LastNA != len(nseq) {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, len(nseq)}, [2]int{report.FirstNA, report.LastNA})
+	}
+	if report.FirstAA != 1 || report.LastAA != 19 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, 19}, [2]int{report.FirstAA, report.LastAA})
+	}
+	// a query identical to the reference has no end gaps to set free
+	expected := alignWithMode(t, NSEQ, ap.FreeEnds)
+	if report := alignWithMode(t, NSEQ, ap.Global); report.Score != expected.Score {
+		t.Errorf(MSG_NOT_EQUAL, expected.Score, report.Score)
+	}
+}
+
+func TestLocalMode(t *testing.T) {
+	// the second half of the query is unrelated to the reference
+	nseq := n.ReadString(n.WriteString(NSEQ[:30]) + "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC")
+	freeEnds := alignWithMode(t, nseq, ap.FreeEnds)
+	local := alignWithMode(t, nseq, ap.Local)
+	if local.FirstNA != 1 || local.LastNA > 30 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, 30}, [2]int{local.FirstNA, local.LastNA})
+	}
+	if local.Score < freeEnds.Score {
+		t.Errorf("Expected local score %v to be at least %v", local.Score, freeEnds.Score)
+	}
+}
+
+func TestAlignmentModeScores(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		aseq := randomAminoAcids(rnd, 50+rnd.Intn(150))
+		nseq := randomQuery(rnd, aseq)
+		scores := map[ap.AlignmentMode]int{}
+		for _, mode := range allAlignmentModes {
+			result, _ := NewAlignment(nseq, aseq, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }))
+			scores[mode] = result.maxScore
+		}
+		// freeing the ends of an alignment can only raise its score
+		if scores[ap.Global] > scores[ap.FreeQueryEnds] ||
+			scores[ap.Global] > scores[ap.FreeReferenceEnds] ||
+			scores[ap.FreeQueryEnds] > scores[ap.FreeEnds] ||
+			scores[ap.FreeReferenceEnds] > scores[ap.FreeEnds] ||
+			scores[ap.FreeEnds] > scores[ap.Local] {
+			t.Errorf("Unexpected order of scores %v", scores)
+		}
+	}
+}
+
+func TestAlignmentModesLinearAndBanded(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	for round := 0; round < 10; round++ {
+		aseq := randomAminoAcids(rnd, 50+rnd.Intn(150))
+		nseq := randomQuery(rnd, aseq)
+		for _, mode := range allAlignmentModes {
+			handler := handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode })
+			assertSameReports(t, nseq, aseq, handler)
+
+			full, _ := NewAlignment(nseq, aseq, handler)
+			banded, _ := NewAlignment(nseq, aseq, handlerWith(func(profile *ap.AlignmentProfile) {
+				profile.AlignmentMode = mode
+				profile.BandWidth = 6
+			}))
+			report := *banded.GetReport()
+			report.IsBandedAlignment = false
+			if full.GetReport().Score != report.Score ||
+				full.GetReport().FirstAA != report.FirstAA ||
+				full.GetReport().LastNA != report.LastNA {
+				t.Errorf("Banded alignment in mode %v differs: %v != %v", mode, *full.GetReport(), report)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package alignment
+
+// This file encodes the traceback of the forward pass. The predecessor
+// of a cell is always one of a few cells around it (see the edges in
+// edges.go), so each cell of the traceback matrix records one
+// byte telling which one, and the matrix index of the predecessor is
+// rebuilt from it during the traceback. The moves are named after the
+// score variables of the forward pass: moveG21 leads to the GENERAL
+// cell (posN-2, posA-1), which holds gScore21.
+
+type tMove byte
+
+const (
+	// to the GENERAL cell (0, 0); being the zero value, it's also the
+	// move of cells which have no predecessor
+	moveOrigin tMove = iota
+	// to the cell itself, which starts the path
+	moveSelf
+	moveI00
+	moveD00
+	moveG10
+	moveG20
+	moveG30
+	moveI30
+	moveG01
+	moveD01
+	moveG11
+	moveD11
+	moveG21
+	moveD21
+	moveG31
+	moveCount
+)
+
+// moveTargets holds the score type of the predecessor of each move, and
+// how many nucleotides and amino acids lie between the predecessor and
+// the cell.
+var moveTargets = [moveCount]struct {
+	scoreType tScoreType
+	nOffset   int
+	aOffset   int
+}{
+	moveI00: {INS, 0, 0},
+	moveD00: {DEL, 0, 0},
+	moveG10: {GENERAL, 1, 0},
+	moveG20: {GENERAL, 2, 0},
+	moveG30: {GENERAL, 3, 0},
+	moveI30: {INS, 3, 0},
+	moveG01: {GENERAL, 0, 1},
+	moveD01: {DEL, 0, 1},
+	moveG11: {GENERAL, 1, 1},
+	moveD11: {DEL, 1, 1},
+	moveG21: {GENERAL, 2, 1},
+	moveD21: {DEL, 2, 1},
+	moveG31: {GENERAL, 3, 1},
+}
+
+// getMoveTarget returns the matrix index of the predecessor which the
+// move of the cell leads to.
+func (self *Alignment) getMoveTarget(scoreType tScoreType, posN int, posA int, move tMove) int {
+	switch move {
+	case moveOrigin:
+		return self.getMatrixIndex(GENERAL, 0, 0)
+	case moveSelf:
+		return self.getMatrixIndex(scoreType, posN, posA)
+	}
+	target := moveTargets[move]
+	return self.getMatrixIndex(target.scoreType, posN-target.nOffset, posA-target.aOffset)
+}
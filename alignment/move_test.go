@@ -0,0 +1,26 @@
+package alignment
+
+import (
+	"testing"
+)
+
+func TestGetMoveTarget(t *testing.T) {
+	aln := &Alignment{nSeqLen: 20, aSeqLen: 10}
+	cases := []struct {
+		move   tMove
+		expect int
+	}{
+		{moveOrigin, aln.getMatrixIndex(GENERAL, 0, 0)},
+		{moveSelf, aln.getMatrixIndex(DEL, 9, 5)},
+		{moveI00, aln.getMatrixIndex(INS, 9, 5)},
+		{moveI30, aln.getMatrixIndex(INS, 6, 5)},
+		{moveD01, aln.getMatrixIndex(DEL, 9, 4)},
+		{moveG31, aln.getMatrixIndex(GENERAL, 6, 4)},
+		{moveD21, aln.getMatrixIndex(DEL, 7, 4)},
+	}
+	for _, c := range cases {
+		if result := aln.getMoveTarget(DEL, 9, 5, c.move); result != c.expect {
+			t.Errorf(MSG_NOT_EQUAL, c.expect, result)
+		}
+	}
+}
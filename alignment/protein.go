@@ -0,0 +1,342 @@
+package alignment
+
+import (
+	"context"
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	f "github.com/hivdb/nucamino/types/frameshift"
+	m "github.com/hivdb/nucamino/types/mutation"
+	sortutil "github.com/pmylund/sortutil"
+	"strings"
+)
+
+// A ProteinAlignment aligns a query protein to a reference with affine
+// gaps. It uses the gap penalties, indel codon bonuses and alignment
+// mode of the score handler, and scores each gapped residue like a
+// gapped codon, so that its scores are comparable to the ones of an
+// Alignment of the coding sequence.
+//
+// Its report is an AlignmentReport in which the NA positions (FirstNA,
+// LastNA, the NAPosition of mutations and the PosNA and LengthNA of
+// aligned sites) count the amino acids of the query protein, and the
+// NucleicAcidsLine holds the query protein.
+type ProteinAlignment struct {
+	query             []a.AminoAcid
+	ref               []a.AminoAcid
+	queryLen          int
+	refLen            int
+	scoreHandler      *handler
+	aminoAcidScores   s.AminoAcidScoreHandler
+	q                 int
+	r                 int
+	freeQueryEnds     bool
+	freeReferenceEnds bool
+	isLocal           bool
+	moves             []byte
+	endPosQ           int
+	endPosR           int
+	maxScore          int
+	report            *AlignmentReport
+}
+
+// The moves of the traceback matrix: the lower two bits hold the
+// predecessor of the GENERAL cell, the other bits tell if the INS or DEL
+// cell extends a gap rather than opening one.
+const (
+	moveStart byte = iota
+	moveMatch
+	moveFromIns
+	moveFromDel
+	moveGeneralMask byte = 3
+	moveInsExtend   byte = 4
+	moveDelExtend   byte = 8
+)
+
+func NewProteinAlignment(query []a.AminoAcid, ref []a.AminoAcid, scoreHandler s.AminoAcidScoreHandler) (*ProteinAlignment, error) {
+	return NewProteinAlignmentContext(context.Background(), query, ref, scoreHandler, Limits{})
+}
+
+// NewProteinAlignmentContext works like NewProteinAlignment, but gives
+// up once ctx is done or the alignment exceeds the limits, like
+// NewAlignmentContext.
+func NewProteinAlignmentContext(parent context.Context, query []a.AminoAcid, ref []a.AminoAcid, scoreHandler s.AminoAcidScoreHandler, limits Limits) (*ProteinAlignment, error) {
+	if err := limits.checkMatrixCells(len(query), len(ref)); err != nil {
+		return nil, err
+	}
+	ctx, cancel := limits.withTimeout(parent)
+	defer cancel()
+	result := &ProteinAlignment{
+		query:           query,
+		ref:             ref,
+		queryLen:        len(query),
+		refLen:          len(ref),
+		scoreHandler:    newHandler(scoreHandler, len(ref)),
+		aminoAcidScores: scoreHandler,
+		q:               scoreHandler.GetGapOpeningScore(),
+		r:               scoreHandler.GetGapExtensionScore(),
+	}
+	result.freeQueryEnds, result.freeReferenceEnds, result.isLocal =
+		alignmentModeEnds(result.scoreHandler.GetAlignmentMode())
+	if !result.calcScores(ctx) {
+		return nil, limits.contextError(parent, ctx)
+	}
+	if !result.generateReport() {
+		return nil, ErrMisaligned
+	}
+	return result, nil
+}
+
+func (self *ProteinAlignment) GetReport() *AlignmentReport {
+	return self.report
+}
+
+func (self *ProteinAlignment) getIndex(posQ int, posR int) int {
+	return posQ*(self.refLen+1) + posR
+}
+
+// isFreeStart and isFreeEnd follow the ones of Alignment, with query
+// amino acids in place of nucleotides.
+func (self *ProteinAlignment) isFreeStart(posQ int, posR int) bool {
+	if posR == 0 && (posQ == 0 || self.freeQueryEnds || self.isLocal) {
+		return true
+	}
+	return posQ == 0 && (self.freeReferenceEnds || self.isLocal)
+}
+
+func (self *ProteinAlignment) isFreeEnd(posQ int, posR int) bool {
+	if self.isLocal {
+		return true
+	}
+	if posR == self.refLen && (posQ == self.queryLen || self.freeQueryEnds) {
+		return true
+	}
+	return posQ == self.queryLen && self.freeReferenceEnds
+}
+
+// gapScore returns the score of a gapped residue after posR (an
+// insertion) or at posR (a deletion), opening the gap or extending it.
+func (self *ProteinAlignment) gapScore(posR int, isInsertion bool, isExtension bool) stepScore {
+	opening, extension := self.scoreHandler.GetPositionalIndelCodonScore(posR, isInsertion)
+	if isExtension {
+		return stepScore{gap: 3 * self.r, bonus: extension}
+	}
+	return stepScore{gap: self.q + 3*self.r, bonus: opening + extension}
+}
+
+func (self *ProteinAlignment) substitutionScore(posQ int, posR int) stepScore {
+	return stepScore{substitution: self.aminoAcidScores.GetAminoAcidSubstitutionScore(
+		posR, self.query[posQ-1], self.ref[posR-1])}
+}
+
+// calcScores fills the traceback matrix, keeping the scores of two
+// rows only, and finds the end of the best path. It returns false if ctx
+// is done first.
+func (self *ProteinAlignment) calcScores(ctx context.Context) bool {
+	var (
+		cols       = self.refLen + 1
+		gScores    = make([]int, cols)
+		iScores    = make([]int, cols)
+		gScoresCur = make([]int, cols)
+		iScoresCur = make([]int, cols)
+	)
+	self.moves = make([]byte, (self.queryLen+1)*cols)
+	self.maxScore = negInf
+	for posQ := 0; posQ <= self.queryLen; posQ++ {
+		if isDone(ctx) {
+			return false
+		}
+		dScore := windowNegInf
+		for posR := 0; posR <= self.refLen; posR++ {
+			var (
+				move   byte
+				iScore = windowNegInf
+				score  = windowNegInf
+			)
+			if posQ > 0 {
+				iScore = gScores[posR] + self.gapScore(posR, true, false).total()
+				if extScore := iScores[posR] + self.gapScore(posR, true, true).total(); extScore > iScore {
+					iScore = extScore
+					move |= moveInsExtend
+				}
+			}
+			if posR > 0 {
+				openScore := gScoresCur[posR-1] + self.gapScore(posR, false, false).total()
+				if extScore := dScore + self.gapScore(posR, false, true).total(); extScore > openScore {
+					openScore = extScore
+					move |= moveDelExtend
+				}
+				dScore = openScore
+			}
+			generalMove := moveStart
+			if posQ > 0 && posR > 0 {
+				score = gScores[posR-1] + self.substitutionScore(posQ, posR).total()
+				generalMove = moveMatch
+			}
+			if iScore > score {
+				score, generalMove = iScore, moveFromIns
+			}
+			if dScore > score {
+				score, generalMove = dScore, moveFromDel
+			}
+			if self.isFreeStart(posQ, posR) || (self.isLocal && score < 0) {
+				score, generalMove = 0, moveStart
+			}
+			gScoresCur[posR], iScoresCur[posR] = score, iScore
+			self.moves[self.getIndex(posQ, posR)] = move | generalMove
+			if score > self.maxScore && self.isFreeEnd(posQ, posR) {
+				self.maxScore = score
+				self.endPosQ, self.endPosR = posQ, posR
+			}
+		}
+		gScores, gScoresCur = gScoresCur, gScores
+		iScores, iScoresCur = iScoresCur, iScores
+	}
+	return true
+}
+
+// A proteinStep is a step of the traced back path, which consumes a
+// query amino acid, a reference amino acid or both.
+type proteinStep struct {
+	scoreType tScoreType
+	score     stepScore
+}
+
+// traceBack returns the steps of the best path in order, and the cell
+// the path starts from.
+func (self *ProteinAlignment) traceBack() (steps []proteinStep, posQ int, posR int) {
+	scoreType := GENERAL
+	posQ, posR = self.endPosQ, self.endPosR
+	for {
+		move := self.moves[self.getIndex(posQ, posR)]
+		switch scoreType {
+		case GENERAL:
+			switch move & moveGeneralMask {
+			case moveStart:
+				sortutil.Reverse(steps)
+				return
+			case moveMatch:
+				steps = append(steps, proteinStep{GENERAL, self.substitutionScore(posQ, posR)})
+				posQ--
+				posR--
+			case moveFromIns:
+				scoreType = INS
+			case moveFromDel:
+				scoreType = DEL
+			}
+		case INS:
+			isExtension := move&moveInsExtend != 0
+			steps = append(steps, proteinStep{INS, self.gapScore(posR, true, isExtension)})
+			posQ--
+			if !isExtension {
+				scoreType = GENERAL
+			}
+		case DEL:
+			isExtension := move&moveDelExtend != 0
+			steps = append(steps, proteinStep{DEL, self.gapScore(posR, false, isExtension)})
+			posR--
+			if !isExtension {
+				scoreType = GENERAL
+			}
+		}
+	}
+}
+
+func (self *ProteinAlignment) generateReport() bool {
+	var (
+		steps, startQ, startR = self.traceBack()
+		posQ, posR            = startQ, startR
+		lineList              []siteLines
+		metricsList           []codonMetrics
+		mutList               = make([]m.Mutation, 0, 10)
+		siteList              = make([]AlignedSite, 0, 50)
+		siteScores            = make([]stepScore, 0, 50)
+		siteAAs               []a.AminoAcid
+		leadingScore          stepScore
+		metrics               codonMetrics
+	)
+	// closeSite reports the last site with the query amino acids aligned
+	// to it, which are known once the path moves to the next site
+	closeSite := func() {
+		if len(siteList) == 0 {
+			return
+		}
+		site := &siteList[len(siteList)-1]
+		site.LengthNA = len(siteAAs)
+		var (
+			ref         = self.ref[site.PosAA-1]
+			mutation    = m.MakeAminoAcidMutation(site.PosAA, site.PosNA, siteAAs, ref)
+			lines       = siteLines{controls: ":"}
+			siteMetrics codonMetrics
+		)
+		if len(siteAAs) > 0 {
+			siteMetrics.addAminoAcid(siteAAs, ref, self.scoreHandler.GetSubstitutionMatrix())
+			metrics.add(siteMetrics)
+		}
+		if mutation != nil {
+			mutList = append(mutList, *mutation)
+			lines.controls = mutation.Control
+		}
+		if len(siteAAs) > 0 {
+			lines.nas = a.WriteString(siteAAs)
+			lines.aas = a.ToString(ref) + strings.Repeat(" ", len(siteAAs)-1)
+		} else {
+			lines.nas = " "
+			lines.aas = a.ToString(ref)
+		}
+		lineList = append(lineList, lines)
+		metricsList = append(metricsList, siteMetrics)
+	}
+	for _, step := range steps {
+		if step.scoreType == INS {
+			posQ++
+			if len(siteList) == 0 {
+				// leading insertions belong to the first site
+				leadingScore.add(step.score)
+				continue
+			}
+			siteAAs = append(siteAAs, self.query[posQ-1])
+			siteScores[len(siteScores)-1].add(step.score)
+			continue
+		}
+		closeSite()
+		siteList = append(siteList, AlignedSite{PosAA: posR + 1, PosNA: posQ + 1})
+		siteScores = append(siteScores, step.score)
+		siteAAs = nil
+		posR++
+		if step.scoreType == GENERAL {
+			posQ++
+			siteAAs = append(siteAAs, self.query[posQ-1])
+		}
+	}
+	closeSite()
+	if len(siteList) == 0 {
+		return false
+	}
+	siteScores[0].add(leadingScore)
+	setSiteScores(siteList, siteScores, self.scoreHandler.GetScoreScale())
+	var (
+		score                = float64(self.maxScore) / float64(self.scoreHandler.GetScoreScale())
+		identity, similarity = metrics.percentages()
+		qLine, aLine, cLine  = joinSiteLines(lineList)
+	)
+	self.report = &AlignmentReport{
+		FirstAA:          startR + 1,
+		FirstNA:          startQ + 1,
+		LastAA:           self.endPosR,
+		LastNA:           self.endPosQ,
+		Mutations:        mutList,
+		FrameShifts:      make([]f.FrameShift, 0),
+		Coverage:         getCoverage(siteList, nil),
+		AlignedSites:     siteList,
+		AminoAcidsLine:   aLine,
+		ControlLine:      cLine,
+		NucleicAcidsLine: qLine,
+		Score:            score,
+		NormalizedScore:  score / float64(self.endPosR-startR),
+		Identity:         identity,
+		Similarity:       similarity,
+	}
+	self.report.Regions = splitRegions(
+		self.report, self.scoreHandler.GetRegions(), lineList, metricsList)
+	return true
+}
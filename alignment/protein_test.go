@@ -0,0 +1,125 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func alignProtein(t *testing.T, query string, handler *h.GeneralScoreHandler) *AlignmentReport {
+	result, err := NewProteinAlignment(a.ReadString(query), ASEQ, handler)
+	if err != nil {
+		t.Errorf("Unexpected error for %v: %v", query, err)
+		t.FailNow()
+	}
+	return result.GetReport()
+}
+
+func mutationTexts(report *AlignmentReport) []string {
+	texts := []string{}
+	for _, mutation := range report.Mutations {
+		texts = append(texts, mutation.ToString())
+	}
+	return texts
+}
+
+func TestProteinAlignment(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	report := alignProtein(t, "TVLVGPTPVNIIGRNLLTQ", handler)
+	if len(report.Mutations) != 0 || report.Identity != 100 {
+		t.Errorf("Expected no mutation but received %v", mutationTexts(report))
+	}
+	report = alignProtein(t, "TVLVGPTPKNWWIIGRNLLTQ", handler)
+	expect := []string{"V9K", "N10N_WW"}
+	if texts := mutationTexts(report); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+	if report.FirstAA != 1 || report.LastAA != 19 || report.FirstNA != 1 || report.LastNA != 21 {
+		t.Errorf(MSG_NOT_EQUAL, [4]int{1, 19, 1, 21},
+			[4]int{report.FirstAA, report.LastAA, report.FirstNA, report.LastNA})
+	}
+	if report.ControlLine != "::::::::.:++:::::::::" {
+		t.Errorf(MSG_NOT_EQUAL, "::::::::.:++:::::::::", report.ControlLine)
+	}
+	if report.NucleicAcidsLine != "TVLVGPTPKNWWIIGRNLLTQ" {
+		t.Errorf(MSG_NOT_EQUAL, "TVLVGPTPKNWWIIGRNLLTQ", report.NucleicAcidsLine)
+	}
+	assertSiteScores(t, report)
+
+	report = alignProtein(t, "TVLVGPTPVNIIGLLTQ", handler)
+	expect = []string{"R14-", "N15-"}
+	if texts := mutationTexts(report); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+	expectSite := AlignedSite{15, 14, 0, 0, -6, 2, 0}
+	site := report.AlignedSites[14]
+	site.CumulativeScore = 0
+	if site != expectSite {
+		t.Errorf(MSG_NOT_EQUAL, expectSite, site)
+	}
+	assertSiteScores(t, report)
+}
+
+func TestProteinAlignmentMatchesCodons(t *testing.T) {
+	// the translation of the query scores the same as the query itself
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTAAAAACTGGTGGATAATTGGAAGACTGTTGACCCAG")
+	codons, _ := NewAlignment(nseq, ASEQ, handler)
+	report := alignProtein(t, "TVLVGPTPKNWWIIGRLLTQ", handler)
+	if report.Score != codons.GetReport().Score {
+		t.Errorf(MSG_NOT_EQUAL, codons.GetReport().Score, report.Score)
+	}
+	expect := []string{"V9K", "N10N_WW", "N15-"}
+	if texts := mutationTexts(codons.GetReport()); texts[2] != expect[2] {
+		t.Errorf(MSG_NOT_EQUAL, expect[2], texts[2])
+	}
+	if texts := mutationTexts(report); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+}
+
+func TestProteinAlignmentModes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for round := 0; round < 10; round++ {
+		aseq := randomAminoAcids(rnd, 50+rnd.Intn(100))
+		query := append(randomAminoAcids(rnd, rnd.Intn(10)), aseq[rnd.Intn(20):]...)
+		for idx := range query {
+			if rnd.Intn(10) == 0 {
+				query[idx] = a.AminoAcids[rnd.Intn(a.NumAminoAcids)]
+			}
+		}
+		scores := map[ap.AlignmentMode]float64{}
+		for _, mode := range allAlignmentModes {
+			result, err := NewProteinAlignment(query, aseq, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }))
+			if err != nil {
+				t.Errorf("Unexpected error in mode %v: %v", mode, err)
+				continue
+			}
+			report := result.GetReport()
+			assertSiteScores(t, report)
+			scores[mode] = report.Score
+			if mode == ap.Global && (report.FirstAA != 1 || report.LastAA != len(aseq) ||
+				report.FirstNA != 1 || report.LastNA != len(query)) {
+				t.Errorf("Expected a global alignment but received %v", *report)
+			}
+		}
+		if scores[ap.Global] > scores[ap.FreeQueryEnds] ||
+			scores[ap.FreeQueryEnds] > scores[ap.FreeEnds] ||
+			scores[ap.FreeReferenceEnds] > scores[ap.FreeEnds] ||
+			scores[ap.FreeEnds] > scores[ap.Local] {
+			t.Errorf("Unexpected order of scores %v", scores)
+		}
+	}
+}
+
+func TestProteinAlignmentMisaligned(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	result, err := NewProteinAlignment([]a.AminoAcid{}, ASEQ, handler)
+	if result != nil || err == nil {
+		t.Errorf("Expected an empty query to be misaligned")
+	}
+}
@@ -0,0 +1,34 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"testing"
+)
+
+func TestPSSM(t *testing.T) {
+	// codon 5 (GAA) is E instead of G
+	nseq := n.ReadString("ACAGTATTAGTAGAACCTACACCTGTAAACATAATTGGAAGAAATCTGTTGACTCAG")
+	var scores [a.NumAminoAcids]int
+	scores[a.E] = 7
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GenePSSMs = map[ap.Gene]ap.PSSM{ap.Gene("A"): {5: scores}}
+	})
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	plain, _ := NewAlignment(nseq, ASEQ, h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE))
+	plainReport := plain.GetReport()
+	for idx, site := range report.AlignedSites {
+		// BLOSUM62 still scores the other positions
+		expect := plainReport.AlignedSites[idx].SubstitutionScore
+		if idx == 4 {
+			expect = 7
+		}
+		if site.SubstitutionScore != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, site.SubstitutionScore)
+		}
+	}
+}
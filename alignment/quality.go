@@ -0,0 +1,34 @@
+package alignment
+
+// FlagLowQuality flags the mutations of the report, and of its regions,
+// whose aligned nucleotides include low-quality ones. lowQuality tells
+// which nucleotides of the query are of a low quality; it is indexed by
+// the nucleotide position minus one.
+func (self *AlignmentReport) FlagLowQuality(lowQuality []bool) {
+	if lowQuality == nil {
+		return
+	}
+	lowQualitySites := make(map[int]bool)
+	for _, site := range self.AlignedSites {
+		firstNA := site.PosNA
+		if self.IsReverseComplement {
+			// the positions of the forward strand decrease along the
+			// codon
+			firstNA -= site.LengthNA - 1
+		}
+		for pos := firstNA; pos < firstNA+site.LengthNA; pos++ {
+			if pos > 0 && pos <= len(lowQuality) && lowQuality[pos-1] {
+				lowQualitySites[site.PosAA] = true
+				break
+			}
+		}
+	}
+	for idx := range self.Mutations {
+		if lowQualitySites[self.Mutations[idx].Position] {
+			self.Mutations[idx].IsLowQuality = true
+		}
+	}
+	for idx := range self.Regions {
+		self.Regions[idx].FlagLowQuality(lowQuality)
+	}
+}
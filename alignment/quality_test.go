@@ -0,0 +1,65 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+// lowQualityFlags returns the IsLowQuality flags of the mutations of
+// the report.
+func lowQualityFlags(report *AlignmentReport) []bool {
+	result := make([]bool, len(report.Mutations))
+	for idx, mutation := range report.Mutations {
+		result[idx] = mutation.IsLowQuality
+	}
+	return result
+}
+
+func TestFlagLowQuality(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	// G5EG:GRA and N15K:AAA
+	nseq := n.ReadString("ACAGTATTAGTAGRACCTACACCTGTAAACATAATTGGAAGAAAACTGTTGACTCAG")
+	lowQuality := make([]bool, len(nseq))
+	lowQuality[14] = true // the third nucleotide of codon 5
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	report.FlagLowQuality(lowQuality)
+	expect := []bool{true, false}
+	if flags := lowQualityFlags(report); !reflect.DeepEqual(flags, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, flags)
+	}
+	regionFlags := [][]bool{
+		lowQualityFlags(&report.Regions[0].AlignmentReport),
+		lowQualityFlags(&report.Regions[1].AlignmentReport),
+	}
+	expectRegions := [][]bool{{true}, {false}}
+	if !reflect.DeepEqual(regionFlags, expectRegions) {
+		t.Errorf(MSG_NOT_EQUAL, expectRegions, regionFlags)
+	}
+
+	// the positions of the reverse complement are the ones of the query
+	lowQuality = make([]bool, len(nseq))
+	lowQuality[len(nseq)-13] = true // the first nucleotide of codon 5
+	aligned, _ = NewAlignmentBothStrands(n.ReverseComplement(nseq), ASEQ, handler)
+	report = aligned.GetReport()
+	report.FlagLowQuality(lowQuality)
+	if flags := lowQualityFlags(report); !reflect.DeepEqual(flags, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, flags)
+	}
+}
+
+func TestFlagLowQualityNone(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligned, _ := NewAlignment(AMBIGUOUS_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	report.FlagLowQuality(nil)
+	expect := []bool{false}
+	if flags := lowQualityFlags(report); !reflect.DeepEqual(flags, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, flags)
+	}
+}
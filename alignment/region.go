@@ -0,0 +1,150 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	f "github.com/hivdb/nucamino/types/frameshift"
+	m "github.com/hivdb/nucamino/types/mutation"
+	"strings"
+)
+
+// A RegionReport is the part of an alignment which covers one region
+// of the gene, such as a mature protein of a polyprotein. The amino acid
+// positions of the report (FirstAA, LastAA and the positions of its
+// mutations, frameshifts, codon differences, unsequenced regions,
+// coverage and aligned sites) count from the start of the region; its
+// nucleotide positions are the ones of the query. Its scores only add
+// up the aligned sites of the region.
+type RegionReport struct {
+	Name string
+	AlignmentReport
+}
+
+// siteLines holds the parts of the three lines of an alignment which
+// show one aligned site.
+type siteLines struct {
+	nas      string
+	aas      string
+	controls string
+}
+
+// joinSiteLines joins the lines of the sites into the three lines of an
+// alignment.
+func joinSiteLines(lines []siteLines) (string, string, string) {
+	var nas, aas, controls strings.Builder
+	for _, line := range lines {
+		nas.WriteString(line.nas)
+		aas.WriteString(line.aas)
+		controls.WriteString(line.controls)
+	}
+	return nas.String(), aas.String(), controls.String()
+}
+
+// splitRegions returns the reports of the regions covered by at least
+// one aligned site of the report. The lines and the codon metrics of
+// each site are given in the order of the sites.
+func splitRegions(
+	report *AlignmentReport, regions []ap.Region,
+	lines []siteLines, metrics []codonMetrics) []RegionReport {
+	var result []RegionReport
+	for _, region := range regions {
+		var (
+			offset      = region.Start - 1
+			inRegion    = func(pos int) bool { return pos >= region.Start && pos <= region.End }
+			regionLines []siteLines
+			regionStats codonMetrics
+			sites       []AlignedSite
+			score       float64
+			lastNA      int
+		)
+		for idx, site := range report.AlignedSites {
+			if !inRegion(site.PosAA) {
+				continue
+			}
+			score += site.SubstitutionScore + site.GapScore + site.IndelBonus
+			site.PosAA -= offset
+			site.CumulativeScore = score
+			sites = append(sites, site)
+			if site.PosNA+site.LengthNA-1 > lastNA {
+				lastNA = site.PosNA + site.LengthNA - 1
+			}
+			regionLines = append(regionLines, lines[idx])
+			regionStats.add(metrics[idx])
+		}
+		if len(sites) == 0 {
+			continue
+		}
+		var (
+			mutations           = make([]m.Mutation, 0)
+			frameShifts         = make([]f.FrameShift, 0)
+			expectedFrameShifts []f.FrameShift
+			codonDiffs          []m.CodonDifference
+		)
+		for _, mutation := range report.Mutations {
+			if inRegion(mutation.Position) {
+				mutation.Position -= offset
+				mutations = append(mutations, mutation)
+			}
+		}
+		for _, frameShift := range report.FrameShifts {
+			if inRegion(frameShift.Position) {
+				frameShift.Position -= offset
+				frameShifts = append(frameShifts, frameShift)
+			}
+		}
+		for _, frameShift := range report.ExpectedFrameShifts {
+			if inRegion(frameShift.Position) {
+				frameShift.Position -= offset
+				expectedFrameShifts = append(expectedFrameShifts, frameShift)
+			}
+		}
+		for _, codonDiff := range report.CodonDifferences {
+			if inRegion(codonDiff.Position) {
+				codonDiff.Position -= offset
+				codonDiffs = append(codonDiffs, codonDiff)
+			}
+		}
+		var unsequenced []SequenceRange
+		for _, unsequencedRange := range report.UnsequencedRegions {
+			var unsequencedSites []AlignedSite
+			for _, site := range sites {
+				if unsequencedRange.containsAA(site.PosAA + offset) {
+					unsequencedSites = append(unsequencedSites, site)
+				}
+			}
+			if len(unsequencedSites) > 0 {
+				unsequenced = append(unsequenced, getSitesRange(unsequencedSites))
+			}
+		}
+		var (
+			firstAA              = sites[0].PosAA
+			lastAA               = sites[len(sites)-1].PosAA
+			identity, similarity = regionStats.percentages()
+			nas, aas, controls   = joinSiteLines(regionLines)
+		)
+		result = append(result, RegionReport{region.Name, AlignmentReport{
+			FirstAA:             firstAA,
+			FirstNA:             sites[0].PosNA,
+			LastAA:              lastAA,
+			LastNA:              lastNA,
+			Mutations:           mutations,
+			FrameShifts:         frameShifts,
+			ExpectedFrameShifts: expectedFrameShifts,
+			CodonDifferences:    codonDiffs,
+			UnsequencedRegions:  unsequenced,
+			Coverage:            getCoverage(sites, unsequenced),
+			AlignedSites:        sites,
+			AminoAcidsLine:      aas,
+			ControlLine:         controls,
+			NucleicAcidsLine:    nas,
+			IsSimpleAlignment:   report.IsSimpleAlignment,
+			IsReverseComplement: report.IsReverseComplement,
+			IsBandedAlignment:   report.IsBandedAlignment,
+			Score:               score,
+			NormalizedScore:     score / float64(lastAA-firstAA+1),
+			Identity:            identity,
+			Similarity:          similarity,
+			EValue:              report.EValue,
+		}})
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var EXAMPLE_REGIONS = []ap.Region{{"R1", 1, 8}, {"R2", 9, 19}}
+
+// assertRegions checks that the regions of the report, which cover the
+// whole reference, add up to the report.
+func assertRegions(t *testing.T, report *AlignmentReport) {
+	var (
+		mutations                      = 0
+		score                          float64
+		nasLine, aasLine, controlsLine string
+	)
+	for idx, region := range report.Regions {
+		assertSiteScores(t, &region.AlignmentReport)
+		offset := EXAMPLE_REGIONS[idx].Start - 1
+		for _, mutation := range region.Mutations {
+			expect := report.Mutations[mutations]
+			expect.Position -= offset
+			if !reflect.DeepEqual(mutation, expect) {
+				t.Errorf(MSG_NOT_EQUAL, expect, mutation)
+			}
+			mutations++
+		}
+		if region.FirstAA+offset < report.FirstAA || region.LastAA+offset > report.LastAA {
+			t.Errorf("Region %v (%d-%d) exceeds the report (%d-%d)", region.Name,
+				region.FirstAA+offset, region.LastAA+offset, report.FirstAA, report.LastAA)
+		}
+		score += region.Score
+		nasLine += region.NucleicAcidsLine
+		aasLine += region.AminoAcidsLine
+		controlsLine += region.ControlLine
+	}
+	if mutations != len(report.Mutations) {
+		t.Errorf(MSG_NOT_EQUAL, len(report.Mutations), mutations)
+	}
+	if math.Abs(score-report.Score) > 1e-9 {
+		t.Errorf("Expected the regions to add up to %v but received %v", report.Score, score)
+	}
+	lines := [3]string{nasLine, aasLine, controlsLine}
+	expect := [3]string{report.NucleicAcidsLine, report.AminoAcidsLine, report.ControlLine}
+	if lines != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, lines)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	for _, seq := range linearTestSeqs {
+		aligned, _ := NewAlignment(n.ReadString(seq), ASEQ, handler)
+		report := aligned.GetReport()
+		if len(report.Regions) != 2 {
+			t.Errorf("Expected two regions but received %v", report.Regions)
+			continue
+		}
+		assertRegions(t, report)
+	}
+}
+
+func TestRegionReport(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	// V9K in the second region, and a deletion of codon 15
+	nseq := n.ReadString("ACAGTATTAGTAGGACCTACACCTAAAAACATAATTGGAAGACTGTTGACCCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	region := aligned.GetReport().Regions[1]
+	if region.Name != "R2" || region.FirstAA != 1 || region.LastAA != 11 ||
+		region.FirstNA != 25 || region.LastNA != 54 {
+		t.Errorf(MSG_NOT_EQUAL, []interface{}{"R2", 1, 11, 25, 54},
+			[]interface{}{region.Name, region.FirstAA, region.LastAA, region.FirstNA, region.LastNA})
+	}
+	expect := []string{"V1K:AAA", "N7-"}
+	if texts := mutationTexts(&region.AlignmentReport); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+	if region.AlignedSites[0].PosAA != 1 || region.AlignedSites[0].PosNA != 25 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{1, 25},
+			[2]int{region.AlignedSites[0].PosAA, region.AlignedSites[0].PosNA})
+	}
+}
+
+func TestRegionsNotCovered(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	// only codons 1 to 8
+	aligned, _ := NewAlignment(n.ReadString("ACAGTRTTAGTAGGACCTACACCT"), ASEQ, handler)
+	regions := aligned.GetReport().Regions
+	if len(regions) != 1 || regions[0].Name != "R1" {
+		t.Errorf("Expected region R1 only but received %v", regions)
+	}
+}
+
+func TestRegionsReverseComplement(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	aligned, _ := NewAlignmentBothStrands(n.ReverseComplement(NSEQ), ASEQ, handler)
+	report := aligned.GetReport()
+	for _, region := range report.Regions {
+		if !region.IsReverseComplement {
+			t.Errorf("Expected region %v to be reverse complemented", region.Name)
+		}
+	}
+	if report.Regions[0].FirstNA != report.FirstNA || report.Regions[1].LastNA != report.LastNA {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{report.FirstNA, report.LastNA},
+			[2]int{report.Regions[0].FirstNA, report.Regions[1].LastNA})
+	}
+}
+
+func TestProteinRegions(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	report := alignProtein(t, "TVLVGPTPKNWWIIGRNLLTQ", handler)
+	assertRegions(t, report)
+	expect := []string{"V1K", "N2N_WW"}
+	if texts := mutationTexts(&report.Regions[1].AlignmentReport); !reflect.DeepEqual(texts, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, texts)
+	}
+}
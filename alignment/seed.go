@@ -0,0 +1,268 @@
+package alignment
+
+import (
+	"errors"
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"sort"
+)
+
+// This file implements a seeding stage which locates the reference gene
+// inside a long query (e.g. an assembled near-full-length genome)
+// before running the quadratic DP.
+//
+// The query is translated in all three frames and every amino acid
+// k-mer is looked up in an index of the reference. The hits are chained
+// along the reference, allowing the diagonal to drift a little between
+// two hits so that indels and frameshifts don't break the chain. Only
+// the window of the query covered by the best chain, extended to both
+// ends of the reference and flanked, is then aligned.
+//
+// A few chance hits are found in any query of realistic length, but
+// they hardly ever chain: the best chain of a random query of 9,000
+// nucleotides against a random reference of 1,000 amino acids holds at
+// most four hits. The best chain must therefore cover a minimum of the
+// reference for the gene to be considered present.
+
+// ErrGeneNotPresent is returned by NewSeededAlignment when the seeds of
+// the reference gene found in the query don't chain into enough of it.
+var ErrGeneNotPresent = errors.New("gene not present")
+
+// Length of the amino acid k-mers
+const seedKmerSize = 4
+
+// Maximum drift (in nucleotides) of the diagonal between two chained hits
+const seedMaxDiagonalDrift = 90
+
+// Nucleotides added to both sides of the window
+const seedWindowFlank = 120
+
+// Minimum score of the best chain, in reference positions; references
+// shorter than twice this need to be half covered instead
+const seedMinChainScore = 6 * seedKmerSize
+
+// A seedHit is an amino acid k-mer that starts at posN of the query and
+// posA of the reference (both zero-based).
+type seedHit struct {
+	posN int
+	posA int
+}
+
+func (self seedHit) diagonal() int {
+	return self.posN - 3*self.posA
+}
+
+type seedHitsByPos []seedHit
+
+func (self seedHitsByPos) Len() int {
+	return len(self)
+}
+
+func (self seedHitsByPos) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self seedHitsByPos) Less(i, j int) bool {
+	if self[i].posA != self[j].posA {
+		return self[i].posA < self[j].posA
+	}
+	return self[i].posN < self[j].posN
+}
+
+// kmerKey encodes k amino acids as an integer; it returns false if the
+// k-mer is interrupted by an untranslatable codon.
+func kmerKey(aas []a.AminoAcid) (int, bool) {
+	key := 0
+	for _, aa := range aas {
+		if aa < 0 {
+			return 0, false
+		}
+		key = key*a.NumAminoAcids + int(aa)
+	}
+	return key, true
+}
+
+// translateFrame translates nSeq starting from the given frame with the
+// genetic code. Stop codons and ambiguous codons are translated to -1.
+func translateFrame(nSeq []n.NucleicAcid, frame int, code *c.GeneticCode) []a.AminoAcid {
+	aas := make([]a.AminoAcid, 0, (len(nSeq)-frame)/3)
+	for pos := frame; pos+3 <= len(nSeq); pos += 3 {
+		codon := c.Codon{Base1: nSeq[pos], Base2: nSeq[pos+1], Base3: nSeq[pos+2]}
+		if codon.IsAmbiguous() || code.IsStopCodon(codon) {
+			aas = append(aas, -1)
+		} else {
+			aas = append(aas, code.ToAminoAcidUnsafe(codon))
+		}
+	}
+	return aas
+}
+
+// findSeedHits returns all the k-mer hits of aSeq in the three
+// translated frames of nSeq, sorted by their positions.
+func findSeedHits(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, code *c.GeneticCode) []seedHit {
+	index := make(map[int][]int)
+	for posA := 0; posA+seedKmerSize <= len(aSeq); posA++ {
+		if key, ok := kmerKey(aSeq[posA : posA+seedKmerSize]); ok {
+			index[key] = append(index[key], posA)
+		}
+	}
+	hits := make([]seedHit, 0)
+	for frame := 0; frame < 3; frame++ {
+		aas := translateFrame(nSeq, frame, code)
+		for pos := 0; pos+seedKmerSize <= len(aas); pos++ {
+			key, ok := kmerKey(aas[pos : pos+seedKmerSize])
+			if !ok {
+				continue
+			}
+			for _, posA := range index[key] {
+				hits = append(hits, seedHit{posN: frame + 3*pos, posA: posA})
+			}
+		}
+	}
+	sort.Sort(seedHitsByPos(hits))
+	return hits
+}
+
+// Width of the diagonal bands which chainSeedHits groups the hits by;
+// the hits a hit can be chained to lie in its own band and the two
+// neighbouring ones
+const seedDiagonalBandWidth = seedMaxDiagonalDrift + 1
+
+// diagonalBand returns the band of the diagonal, rounding down.
+func diagonalBand(diagonal int) int {
+	if diagonal < 0 {
+		return (diagonal+1)/seedDiagonalBandWidth - 1
+	}
+	return diagonal / seedDiagonalBandWidth
+}
+
+// chainSeedHits returns the best co-linear chain of hits and its score.
+// Each hit scores the number of reference positions it adds to the
+// chain, minus the drift of the diagonal in codons.
+//
+// Only the hits of the neighbouring diagonal bands are considered as
+// the previous hit of a chain, so that the hits of unrelated parts of
+// a long query are never compared.
+func chainSeedHits(hits []seedHit) ([]seedHit, int) {
+	if len(hits) == 0 {
+		return nil, 0
+	}
+	var (
+		scores  = make([]int, len(hits))
+		prevs   = make([]int, len(hits))
+		bands   = make(map[int][]int)
+		bestIdx = 0
+	)
+	for cur, hit := range hits {
+		scores[cur], prevs[cur] = seedKmerSize, -1
+		band := diagonalBand(hit.diagonal())
+		for _, prevBand := range [3]int{band - 1, band, band + 1} {
+			for _, prev := range bands[prevBand] {
+				prevHit := hits[prev]
+				if prevHit.posA >= hit.posA || prevHit.posN >= hit.posN {
+					continue
+				}
+				drift := hit.diagonal() - prevHit.diagonal()
+				if drift < 0 {
+					drift = -drift
+				}
+				if drift > seedMaxDiagonalDrift {
+					continue
+				}
+				gain := hit.posA - prevHit.posA
+				if gain > seedKmerSize {
+					gain = seedKmerSize
+				}
+				// of the best previous hits, the last one is taken
+				score := scores[prev] + gain - drift/3
+				if score > scores[cur] ||
+					(score == scores[cur] && prevs[cur] > -1 && prev > prevs[cur]) {
+					scores[cur], prevs[cur] = score, prev
+				}
+			}
+		}
+		bands[band] = append(bands[band], cur)
+		if scores[cur] > scores[bestIdx] {
+			bestIdx = cur
+		}
+	}
+	chain := make([]seedHit, 0)
+	for idx := bestIdx; idx > -1; idx = prevs[idx] {
+		chain = append(chain, hits[idx])
+	}
+	// reverse the chain so it's ordered by position
+	for left, right := 0, len(chain)-1; left < right; left, right = left+1, right-1 {
+		chain[left], chain[right] = chain[right], chain[left]
+	}
+	return chain, scores[bestIdx]
+}
+
+// minSeedChainScore returns the score the best chain of hits against a
+// reference of aSeqLen amino acids needs for the gene to be present.
+func minSeedChainScore(aSeqLen int) int {
+	minScore := seedMinChainScore
+	if half := aSeqLen / 2; half < minScore {
+		minScore = half
+	}
+	if minScore < seedKmerSize {
+		minScore = seedKmerSize
+	}
+	return minScore
+}
+
+// findSeedWindow returns the range [start, end) of nSeq which is
+// expected to contain aSeq, or false if the seeds don't chain into
+// enough of aSeq.
+func findSeedWindow(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, code *c.GeneticCode) (int, int, bool) {
+	chain, score := chainSeedHits(findSeedHits(nSeq, aSeq, code))
+	if len(chain) == 0 || score < minSeedChainScore(len(aSeq)) {
+		return 0, 0, false
+	}
+	first, last := chain[0], chain[len(chain)-1]
+	start := first.posN - 3*first.posA - seedWindowFlank
+	end := last.posN + 3*(len(aSeq)-last.posA) + seedWindowFlank
+	if start < 0 {
+		start = 0
+	}
+	if end > len(nSeq) {
+		end = len(nSeq)
+	}
+	return start, end, true
+}
+
+// NewSeededAlignment locates aSeq inside nSeq with translated k-mer
+// seeds and aligns only the window around them. The nucleotide
+// positions of the report are relative to the full nSeq. It returns
+// ErrGeneNotPresent without running the DP if the seeds don't chain
+// into enough of aSeq.
+func NewSeededAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler s.ScoreHandler) (*Alignment, error) {
+	return alignSeeded(nSeq, aSeq, geneticCodeOf(scoreHandler), func(nas []n.NucleicAcid) (*Alignment, error) {
+		return NewAlignment(nas, aSeq, scoreHandler)
+	})
+}
+
+// SeededAlignFunc returns an AlignFunc which works like
+// NewSeededAlignment, aligning the window with align. The codons of the
+// query are translated with code.
+func SeededAlignFunc(aSeq []a.AminoAcid, code *c.GeneticCode, align AlignFunc) AlignFunc {
+	return func(nSeq []n.NucleicAcid) (*Alignment, error) {
+		return alignSeeded(nSeq, aSeq, code, align)
+	}
+}
+
+func alignSeeded(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, code *c.GeneticCode, align AlignFunc) (*Alignment, error) {
+	start, end, found := findSeedWindow(nSeq, aSeq, code)
+	if !found {
+		return nil, ErrGeneNotPresent
+	}
+	result, err := align(nSeq[start:end])
+	if err != nil {
+		return nil, err
+	}
+	result.report.remapNAPositions(func(pos int) int {
+		return pos + start
+	})
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindSeedWindow(t *testing.T) {
+	nseq := n.ReadString(strings.Repeat("A", 600) + n.WriteString(NSEQ) + strings.Repeat("A", 600))
+	start, end, found := findSeedWindow(nseq, ASEQ, c.StandardCode)
+	if !found {
+		t.Errorf(MSG_NOT_EQUAL, true, found)
+	}
+	if start != 600-seedWindowFlank {
+		t.Errorf(MSG_NOT_EQUAL, 600-seedWindowFlank, start)
+	}
+	if end != 600+len(NSEQ)+seedWindowFlank {
+		t.Errorf(MSG_NOT_EQUAL, 600+len(NSEQ)+seedWindowFlank, end)
+	}
+}
+
+func TestNewSeededAlignment(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for round := 0; round < 5; round++ {
+		aseq := randomAminoAcids(rnd, 100+rnd.Intn(200))
+		contig := randomQuery(rnd, randomAminoAcids(rnd, 300+rnd.Intn(700)))
+		contig = append(contig, randomQuery(rnd, aseq)...)
+		contig = append(contig, randomQuery(rnd, randomAminoAcids(rnd, 300+rnd.Intn(700)))...)
+
+		full, _ := NewAlignment(contig, aseq, handler)
+		seeded, err := NewSeededAlignment(contig, aseq, handler)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(full.GetReport(), seeded.GetReport()) {
+			t.Errorf(MSG_NOT_EQUAL, full.GetReport(), seeded.GetReport())
+		}
+	}
+}
+
+func TestSeededAlignFuncBothStrands(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aseq := randomAminoAcids(rnd, 200)
+	contig := randomQuery(rnd, randomAminoAcids(rnd, 500))
+	contig = append(contig, randomQuery(rnd, aseq)...)
+	contig = n.ReverseComplement(append(contig, randomQuery(rnd, randomAminoAcids(rnd, 500))...))
+
+	full, _ := NewAlignmentBothStrands(contig, aseq, handler)
+	aligner := NewAligner(aseq, handler)
+	align := BothStrandsAlignFunc(SeededAlignFunc(aseq, c.StandardCode, aligner.NewAlignment))
+	seeded, err := align(contig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !seeded.GetReport().IsReverseComplement {
+		t.Errorf("Expected the reverse complement to be aligned")
+	}
+	if !reflect.DeepEqual(full.GetReport(), seeded.GetReport()) {
+		t.Errorf(MSG_NOT_EQUAL, full.GetReport(), seeded.GetReport())
+	}
+}
+
+func TestNewSeededAlignmentUnrelatedQuery(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for round := 0; round < 5; round++ {
+		// a reference and a query as long as POL, which chance hits
+		// are expected between
+		aseq := randomAminoAcids(rnd, 1000)
+		nseq := make([]n.NucleicAcid, 3000)
+		for idx := range nseq {
+			nseq[idx] = n.NucleicAcids[rnd.Intn(4)]
+		}
+		if hits := findSeedHits(nseq, aseq, c.StandardCode); len(hits) == 0 {
+			t.Errorf("Expected chance hits in round %d", round)
+		}
+		result, err := NewSeededAlignment(nseq, aseq, handler)
+		if result != nil || err != ErrGeneNotPresent {
+			t.Errorf(MSG_NOT_EQUAL, ErrGeneNotPresent, err)
+		}
+	}
+}
+
+func TestNewSeededAlignmentGeneNotPresent(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	nseq := n.ReadString(strings.Repeat("A", 3000))
+	result, err := NewSeededAlignment(nseq, ASEQ, handler)
+	if result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+	if err != ErrGeneNotPresent {
+		t.Errorf(MSG_NOT_EQUAL, ErrGeneNotPresent, err)
+	}
+}
+
+// chainSeedHitsQuadratic chains the hits like chainSeedHits, comparing
+// each hit to all the previous ones.
+func chainSeedHitsQuadratic(hits []seedHit) ([]seedHit, int) {
+	if len(hits) == 0 {
+		return nil, 0
+	}
+	scores, prevs, bestIdx := make([]int, len(hits)), make([]int, len(hits)), 0
+	for cur, hit := range hits {
+		scores[cur], prevs[cur] = seedKmerSize, -1
+		for prev := cur - 1; prev >= 0; prev-- {
+			prevHit := hits[prev]
+			drift := hit.diagonal() - prevHit.diagonal()
+			if drift < 0 {
+				drift = -drift
+			}
+			if prevHit.posA >= hit.posA || prevHit.posN >= hit.posN || drift > seedMaxDiagonalDrift {
+				continue
+			}
+			gain := hit.posA - prevHit.posA
+			if gain > seedKmerSize {
+				gain = seedKmerSize
+			}
+			if score := scores[prev] + gain - drift/3; score > scores[cur] {
+				scores[cur], prevs[cur] = score, prev
+			}
+		}
+		if scores[cur] > scores[bestIdx] {
+			bestIdx = cur
+		}
+	}
+	chain := []seedHit{}
+	for idx := bestIdx; idx > -1; idx = prevs[idx] {
+		chain = append([]seedHit{hits[idx]}, chain...)
+	}
+	return chain, scores[bestIdx]
+}
+
+func TestChainSeedHits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		aseq := randomAminoAcids(rnd, 20+rnd.Intn(200))
+		nseq := randomQuery(rnd, randomAminoAcids(rnd, rnd.Intn(500)))
+		for copies := rnd.Intn(3); copies >= 0; copies-- {
+			// diverged copies of the gene, so that the hits of the
+			// chain drift
+			nseq = append(nseq, randomQuery(rnd, aseq)...)
+			nseq = append(nseq, randomQuery(rnd, randomAminoAcids(rnd, rnd.Intn(300)))...)
+		}
+		hits := findSeedHits(nseq, aseq, c.StandardCode)
+		chain, score := chainSeedHits(hits)
+		expectChain, expectScore := chainSeedHitsQuadratic(hits)
+		if score != expectScore || !reflect.DeepEqual(chain, expectChain) {
+			t.Errorf(MSG_NOT_EQUAL, expectChain, chain)
+		}
+	}
+}
+
+func TestDiagonalBand(t *testing.T) {
+	for diagonal := -3 * seedDiagonalBandWidth; diagonal < 3*seedDiagonalBandWidth; diagonal++ {
+		band := diagonalBand(diagonal)
+		if diagonal < band*seedDiagonalBandWidth || diagonal >= (band+1)*seedDiagonalBandWidth {
+			t.Errorf("Diagonal %d is not in band %d", diagonal, band)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package alignment
+
+import (
+	"errors"
+	"fmt"
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math"
+	"math/rand"
+)
+
+// This file estimates how significant an alignment is.
+//
+// The maximum score of aligning a random query against a reference
+// roughly follows a Gumbel (extreme value) distribution. Its parameters
+// are fitted by the method of moments to the scores of random
+// nucleotide sequences, each as long as the coding region of the
+// reference but at most maxScoreDistributionSampleLen nucleotides long.
+// For a query of queryLen nucleotides, the E-value of score
+// S is the expected number of random hits scoring at least S:
+//
+//     E = queryLen / sampleLen * exp(-lambda * (S - mu))
+
+// Number of random sequences aligned by EstimateScoreDistribution, and
+// how many of them must be aligned successfully
+const scoreDistributionSamples = 100
+const minScoreDistributionSamples = 10
+
+// Longer random sequences make the estimation slower without making it
+// more precise
+const maxScoreDistributionSampleLen = 600
+
+// Euler-Mascheroni constant
+const eulerGamma = 0.5772156649015329
+
+// A ScoreDistribution holds the Gumbel parameters of the scores of
+// random queries aligned against a reference.
+type ScoreDistribution struct {
+	Lambda    float64
+	Mu        float64
+	SampleLen int
+}
+
+// NoSignificantAlignmentError is returned by ScoreDistribution.Assess
+// when the E-value of an alignment exceeds the cutoff.
+type NoSignificantAlignmentError struct {
+	EValue    float64
+	MaxEValue float64
+}
+
+func (self *NoSignificantAlignmentError) Error() string {
+	return fmt.Sprintf(
+		"no significant alignment (E-value %.3g > %.3g)",
+		self.EValue, self.MaxEValue)
+}
+
+// EstimateScoreDistribution aligns random nucleotide sequences against
+// aSeq and fits the distribution of their scores. The random sequences
+// are generated from seed, so the estimation is reproducible.
+func EstimateScoreDistribution(aSeq []a.AminoAcid, scoreHandler s.ScoreHandler, seed int64) (*ScoreDistribution, error) {
+	sampleLen := 3 * len(aSeq)
+	if sampleLen > maxScoreDistributionSampleLen {
+		sampleLen = maxScoreDistributionSampleLen
+	}
+	var (
+		rnd    = rand.New(rand.NewSource(seed))
+		scores = make([]float64, 0, scoreDistributionSamples)
+		nSeq   = make([]n.NucleicAcid, sampleLen)
+	)
+	for sample := 0; sample < scoreDistributionSamples; sample++ {
+		for idx := range nSeq {
+			nSeq[idx] = n.NucleicAcids[rnd.Intn(4)]
+		}
+		aligned, err := NewAlignment(nSeq, aSeq, scoreHandler)
+		if err == nil {
+			scores = append(scores, aligned.report.Score)
+		}
+	}
+	if len(scores) < minScoreDistributionSamples {
+		return nil, errors.New("too few random sequences could be aligned")
+	}
+	var mean, variance float64
+	for _, score := range scores {
+		mean += score
+	}
+	mean /= float64(len(scores))
+	for _, score := range scores {
+		variance += (score - mean) * (score - mean)
+	}
+	variance /= float64(len(scores) - 1)
+	if variance == 0 {
+		return nil, errors.New("scores of random sequences don't vary")
+	}
+	lambda := math.Pi / math.Sqrt(6*variance)
+	return &ScoreDistribution{
+		Lambda:    lambda,
+		Mu:        mean - eulerGamma/lambda,
+		SampleLen: sampleLen,
+	}, nil
+}
+
+// EValue returns the expected number of hits scoring at least score in
+// a random query of queryLen nucleotides.
+func (self *ScoreDistribution) EValue(score float64, queryLen int) float64 {
+	return float64(queryLen) / float64(self.SampleLen) *
+		math.Exp(-self.Lambda*(score-self.Mu))
+}
+
+// Assess sets the E-value of the alignment's report, where queryLen is
+// the number of nucleotides that were searched (twice the query length
+// when both strands were aligned). If maxEValue is positive and the
+// E-value exceeds it, a *NoSignificantAlignmentError is returned.
+func (self *ScoreDistribution) Assess(aligned *Alignment, queryLen int, maxEValue float64) error {
+	evalue := self.EValue(aligned.report.Score, queryLen)
+	aligned.report.EValue = evalue
+	for idx := range aligned.report.Regions {
+		aligned.report.Regions[idx].EValue = evalue
+	}
+	if maxEValue > 0 && evalue > maxEValue {
+		return &NoSignificantAlignmentError{EValue: evalue, MaxEValue: maxEValue}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEstimateScoreDistribution(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	dist, err := EstimateScoreDistribution(ASEQ, handler, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		t.FailNow()
+	}
+	if dist.Lambda <= 0 {
+		t.Errorf("Expected positive lambda but received %v", dist.Lambda)
+	}
+	if dist.SampleLen != 3*len(ASEQ) {
+		t.Errorf(MSG_NOT_EQUAL, 3*len(ASEQ), dist.SampleLen)
+	}
+	again, _ := EstimateScoreDistribution(ASEQ, handler, 1)
+	if !reflect.DeepEqual(dist, again) {
+		t.Errorf(MSG_NOT_EQUAL, dist, again)
+	}
+}
+
+func TestEValue(t *testing.T) {
+	dist := &ScoreDistribution{Lambda: 0.5, Mu: 10, SampleLen: 300}
+	if evalue := dist.EValue(10, 300); evalue != 1 {
+		t.Errorf(MSG_NOT_EQUAL, 1.0, evalue)
+	}
+	expected := 2 * math.Exp(-0.5*4)
+	if evalue := dist.EValue(14, 600); math.Abs(evalue-expected) > 1e-12 {
+		t.Errorf(MSG_NOT_EQUAL, expected, evalue)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	dist, _ := EstimateScoreDistribution(ASEQ, handler, 1)
+
+	aligned, _ := NewAlignment(NSEQ, ASEQ, handler)
+	if err := dist.Assess(aligned, len(NSEQ), 0.01); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if aligned.GetReport().EValue <= 0 || aligned.GetReport().EValue > 0.01 {
+		t.Errorf("Unexpected E-value %v", aligned.GetReport().EValue)
+	}
+
+	unrelated := n.ReadString("GGGCCCAAATTTGGGCCCAAATTTGGGCCCAAATTTGGGCCCAAATTTGGGCCCAAA")
+	aligned, _ = NewAlignment(unrelated, ASEQ, handler)
+	err := dist.Assess(aligned, len(unrelated), 0.01)
+	if _, ok := err.(*NoSignificantAlignmentError); !ok {
+		t.Errorf("Expected a NoSignificantAlignmentError but received %v", err)
+	}
+	// no cutoff
+	if err := dist.Assess(aligned, len(unrelated), 0); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package alignment
+
+// This file breaks the score of an alignment down to its aligned
+// sites. Every step of the traced back path is scored again by the
+// edge of the forward pass that made it (see edges.go); the steps are
+// then added up for the site they belong to.
+
+// A stepScore holds the parts of the score gained by one or more steps
+// of the path: the codon substitution score, the gap opening and
+// extension penalties, and the indel codon bonuses.
+type stepScore struct {
+	substitution int
+	gap          int
+	bonus        int
+}
+
+func (self *stepScore) add(other stepScore) {
+	self.substitution += other.substitution
+	self.gap += other.gap
+	self.bonus += other.bonus
+}
+
+func (self stepScore) total() int {
+	return self.substitution + self.gap + self.bonus
+}
+
+// scoreStep returns the score gained by the path moving from prevIdx to
+// mtIdx.
+func (self *Alignment) scoreStep(prevIdx int, mtIdx int) (step stepScore) {
+	if self.isPathStart(mtIdx, prevIdx) {
+		return
+	}
+	var (
+		scoreType, posN, posA  = self.getTypedPos(mtIdx)
+		prevType, prevN, prevA = self.getTypedPos(prevIdx)
+		edgesBuf               [maxCellEdges]tEdge
+		found                  bool
+	)
+	switch {
+	case scoreType == INS && posN == 0,
+		scoreType == DEL && posA == 0,
+		scoreType == GENERAL && (posN == 0 || posA == 0):
+		// the first row and column
+		return
+	}
+	// among the edges of the same move, the forward pass took the one
+	// of the highest score
+	edges := self.appendEdges(edgesBuf[:0], scoreType, posA, posN == self.nSeqLen)
+	for idx := range edges {
+		edge := &edges[idx]
+		target := moveTargets[edge.move]
+		if !edge.leadsTo(posN) || target.scoreType != prevType ||
+			target.nOffset != posN-prevN || target.aOffset != posA-prevA {
+			continue
+		}
+		edgeStep := edge.step
+		if edge.isCodon {
+			edgeStep.substitution = self.scoreHandler.GetSubstitutionScore(
+				posA+self.aSeqOffset,
+				self.getNA(posN-2), self.getNA(posN-1), self.getNA(posN),
+				self.getAA(posA))
+		}
+		if !found || edgeStep.total() > step.total() {
+			step, found = edgeStep, true
+		}
+	}
+	return
+}
+
+// getIndelCodonScore returns the opening and extension bonuses of indel
+// codons at posA, as used by the forward pass.
+func (self *Alignment) getIndelCodonScore(posA int, isInsertion bool) (int, int) {
+	if self.supportPositionalIndel {
+		return self.scoreHandler.GetPositionalIndelCodonScore(posA+self.aSeqOffset, isInsertion)
+	}
+	return self.constIndelCodonOpeningScore, self.constIndelCodonExtensionScore
+}
+
+// setSiteScores sets the score breakdown of each site from its scaled
+// score.
+func setSiteScores(sites []AlignedSite, scores []stepScore, scoreScale int) {
+	var (
+		scale      = float64(scoreScale)
+		cumulative int
+	)
+	for idx, score := range scores {
+		cumulative += score.total()
+		sites[idx].SubstitutionScore = float64(score.substitution) / scale
+		sites[idx].GapScore = float64(score.gap) / scale
+		sites[idx].IndelBonus = float64(score.bonus) / scale
+		sites[idx].CumulativeScore = float64(cumulative) / scale
+	}
+}
@@ -0,0 +1,88 @@
+package alignment
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// assertSiteScores checks that the score breakdown of the sites adds up
+// to the score of the alignment.
+func assertSiteScores(t *testing.T, report *AlignmentReport) {
+	var cumulative float64
+	for _, site := range report.AlignedSites {
+		cumulative += site.SubstitutionScore + site.GapScore + site.IndelBonus
+		if math.Abs(cumulative-site.CumulativeScore) > 1e-9 {
+			t.Errorf(MSG_NOT_EQUAL, cumulative, site.CumulativeScore)
+			return
+		}
+	}
+	if math.Abs(cumulative-report.Score) > 1e-9 {
+		t.Errorf("Expected the sites to add up to %v but received %v", report.Score, cumulative)
+	}
+}
+
+func TestSiteScores(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for _, seq := range linearTestSeqs {
+		aligned, _ := NewAlignment(n.ReadString(seq), ASEQ, handler)
+		assertSiteScores(t, aligned.GetReport())
+	}
+}
+
+func TestSiteScoresPositionalIndel(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneIndelScores = ap.GenePositionalIndelScores{
+			"A": ap.PositionalIndelScores{
+				8: [2]int{5, 1},
+			},
+		}
+	})
+	nseq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTttttttGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	// two codons are inserted after codon 8: one opening bonus and two
+	// extension bonuses, for a gap of one opening and six extensions
+	expect := AlignedSite{8, 22, 9, 7, -22, 7, 27}
+	if site := report.AlignedSites[7]; site != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, site)
+	}
+}
+
+func TestSiteScoresIndelDefaults(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneIndelDefaults = map[ap.Gene]ap.IndelScoreDefaults{
+			"A": {Ins: &[2]int{5, 1}},
+		}
+	})
+	nseq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTttttttGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
+	aligned, _ := NewAlignment(nseq, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	// scored like the positional indel scores of codon 8 above
+	expect := AlignedSite{8, 22, 9, 7, -22, 7, 27}
+	if site := report.AlignedSites[7]; site != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, site)
+	}
+}
+
+func TestSiteScoresRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for round := 0; round < 10; round++ {
+		aseq := randomAminoAcids(rnd, 50+rnd.Intn(150))
+		nseq := randomQuery(rnd, aseq)
+		for _, mode := range allAlignmentModes {
+			aligned, _ := NewAlignment(nseq, aseq, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }))
+			assertSiteScores(t, aligned.GetReport())
+			withLinearTraceback(func(limits Limits) {
+				aligned, _ = NewAlignmentContext(context.Background(), nseq, aseq, handlerWith(func(profile *ap.AlignmentProfile) { profile.AlignmentMode = mode }), limits)
+			})
+			assertSiteScores(t, aligned.GetReport())
+		}
+	}
+}
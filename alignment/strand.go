@@ -0,0 +1,87 @@
+package alignment
+
+import (
+	s "github.com/hivdb/nucamino/scorehandler"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// An AlignFunc aligns nSeq against a reference that it already knows.
+type AlignFunc func(nSeq []n.NucleicAcid) (*Alignment, error)
+
+// NewAlignmentBothStrands aligns both nSeq and its reverse complement
+// against aSeq and keeps the better-scoring alignment. When the
+// reverse complement wins, the report is flagged with
+// IsReverseComplement and all of its nucleotide positions are
+// expressed in the coordinates of the original (unreversed) input.
+func NewAlignmentBothStrands(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler s.ScoreHandler) (*Alignment, error) {
+	return alignBothStrands(nSeq, func(nas []n.NucleicAcid) (*Alignment, error) {
+		return NewAlignment(nas, aSeq, scoreHandler)
+	})
+}
+
+// BothStrandsAlignFunc returns an AlignFunc which works like
+// NewAlignmentBothStrands, aligning each strand with align.
+func BothStrandsAlignFunc(align AlignFunc) AlignFunc {
+	return func(nSeq []n.NucleicAcid) (*Alignment, error) {
+		return alignBothStrands(nSeq, align)
+	}
+}
+
+func alignBothStrands(nSeq []n.NucleicAcid, align AlignFunc) (*Alignment, error) {
+	fwd, fwdErr := align(nSeq)
+	if fwdErr != nil && !isUnaligned(fwdErr) {
+		// the forward strand was cut short, so the strands can't be
+		// compared
+		return nil, fwdErr
+	}
+	rev, revErr := align(n.ReverseComplement(nSeq))
+	if fwdErr != nil && revErr != nil && !isUnaligned(revErr) {
+		// the reverse strand was cut short rather than misaligned
+		return nil, revErr
+	}
+	if revErr != nil || (fwdErr == nil && fwd.maxScore >= rev.maxScore) {
+		// ties are resolved in favor of the forward strand
+		return fwd, fwdErr
+	}
+	nSeqLen := len(nSeq)
+	rev.report.remapNAPositions(func(pos int) int {
+		return nSeqLen - pos + 1
+	})
+	rev.report.IsReverseComplement = true
+	for idx := range rev.report.Regions {
+		rev.report.Regions[idx].IsReverseComplement = true
+	}
+	return rev, nil
+}
+
+// remapNAPositions rewrites every nucleotide position held by the
+// report using the given function.
+func (self *AlignmentReport) remapNAPositions(remap func(int) int) {
+	self.FirstNA = remap(self.FirstNA)
+	self.LastNA = remap(self.LastNA)
+	for idx := range self.Mutations {
+		self.Mutations[idx].NAPosition = remap(self.Mutations[idx].NAPosition)
+	}
+	for idx := range self.FrameShifts {
+		self.FrameShifts[idx].NAPosition = remap(self.FrameShifts[idx].NAPosition)
+	}
+	for idx := range self.ExpectedFrameShifts {
+		self.ExpectedFrameShifts[idx].NAPosition = remap(self.ExpectedFrameShifts[idx].NAPosition)
+	}
+	for idx := range self.CodonDifferences {
+		self.CodonDifferences[idx].NAPosition = remap(self.CodonDifferences[idx].NAPosition)
+	}
+	for _, ranges := range [][]SequenceRange{self.UnsequencedRegions, self.Coverage} {
+		for idx := range ranges {
+			ranges[idx].FirstNA = remap(ranges[idx].FirstNA)
+			ranges[idx].LastNA = remap(ranges[idx].LastNA)
+		}
+	}
+	for idx := range self.AlignedSites {
+		self.AlignedSites[idx].PosNA = remap(self.AlignedSites[idx].PosNA)
+	}
+	for idx := range self.Regions {
+		self.Regions[idx].remapNAPositions(remap)
+	}
+}
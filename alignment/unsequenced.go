@@ -0,0 +1,150 @@
+package alignment
+
+import (
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// A SequenceRange is a range of an alignment: FirstAA and LastAA are
+// positions of the reference, FirstNA and LastNA positions of the
+// query.
+type SequenceRange struct {
+	FirstAA int
+	LastAA  int
+	FirstNA int
+	LastNA  int
+}
+
+func (self SequenceRange) containsAA(pos int) bool {
+	return pos >= self.FirstAA && pos <= self.LastAA
+}
+
+// isUnsequenced tells if nas, the nucleotides aligned to a codon and
+// inserted after it, are all N or mixtures of three nucleotides (B, D,
+// H or V). Mixtures of two nucleotides, such as R or Y, are sequenced
+// polymorphisms whose mutations are still reported.
+func isUnsequenced(nas []n.NucleicAcid) bool {
+	for _, na := range nas {
+		switch na {
+		case n.B, n.D, n.H, n.V, n.N:
+		default:
+			return false
+		}
+	}
+	return len(nas) > 0
+}
+
+// getSitesRange returns the range covered by sites, which must not be
+// empty.
+func getSitesRange(sites []AlignedSite) SequenceRange {
+	result := SequenceRange{
+		sites[0].PosAA, sites[len(sites)-1].PosAA,
+		sites[0].PosNA, sites[0].PosNA - 1,
+	}
+	for _, site := range sites {
+		if lastNA := site.PosNA + site.LengthNA - 1; lastNA > result.LastNA {
+			result.LastNA = lastNA
+		}
+	}
+	return result
+}
+
+// findUnsequencedRegions returns the runs of at least minCodons sites
+// whose nucleotides are unsequenced, as told by unsequenced. Deleted
+// codons between them belong to the run.
+func findUnsequencedRegions(sites []AlignedSite, unsequenced []bool, minCodons int) []SequenceRange {
+	var (
+		result    []SequenceRange
+		start     = -1
+		last      int
+		runLength int
+	)
+	endRun := func() {
+		if start > -1 && runLength >= minCodons {
+			result = append(result, getSitesRange(sites[start:last+1]))
+		}
+		start = -1
+	}
+	for idx, site := range sites {
+		switch {
+		case unsequenced[idx]:
+			if start < 0 {
+				start, runLength = idx, 0
+			}
+			last = idx
+			runLength++
+		case site.LengthNA == 0:
+			// a deletion doesn't end the run
+		default:
+			endRun()
+		}
+	}
+	endRun()
+	return result
+}
+
+// getCoverage returns the ranges of the sites outside of the
+// unsequenced regions.
+func getCoverage(sites []AlignedSite, unsequenced []SequenceRange) []SequenceRange {
+	var (
+		result = make([]SequenceRange, 0, len(unsequenced)+1)
+		start  = -1
+	)
+	for idx, site := range sites {
+		isCovered := true
+		for _, region := range unsequenced {
+			if region.containsAA(site.PosAA) {
+				isCovered = false
+				break
+			}
+		}
+		if isCovered && start < 0 {
+			start = idx
+		} else if !isCovered && start > -1 {
+			result = append(result, getSitesRange(sites[start:idx]))
+			start = -1
+		}
+	}
+	if start > -1 {
+		result = append(result, getSitesRange(sites[start:]))
+	}
+	return result
+}
+
+// dropUnsequencedMutations returns the mutations outside of the
+// unsequenced regions.
+func dropUnsequencedMutations(mutations []m.Mutation, unsequenced []SequenceRange) []m.Mutation {
+	if len(unsequenced) == 0 {
+		return mutations
+	}
+	result := make([]m.Mutation, 0, len(mutations))
+MutationsLoop:
+	for _, mutation := range mutations {
+		for _, region := range unsequenced {
+			if region.containsAA(mutation.Position) {
+				continue MutationsLoop
+			}
+		}
+		result = append(result, mutation)
+	}
+	return result
+}
+
+// dropUnsequencedCodonDifferences returns the codon differences outside
+// of the unsequenced regions.
+func dropUnsequencedCodonDifferences(codonDiffs []m.CodonDifference, unsequenced []SequenceRange) []m.CodonDifference {
+	if len(unsequenced) == 0 {
+		return codonDiffs
+	}
+	var result []m.CodonDifference
+CodonDifferencesLoop:
+	for _, codonDiff := range codonDiffs {
+		for _, region := range unsequenced {
+			if region.containsAA(codonDiff.Position) {
+				continue CodonDifferencesLoop
+			}
+		}
+		result = append(result, codonDiff)
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+// codons 8 to 11 are N; codon 15 (NNN) is too short a run to be an
+// unsequenced region, and codon 16 (RYN) has two-fold mixtures
+var UNSEQUENCED_NSEQ = n.ReadString("ACAGTATTAGTAGGACCTACANNNNNNNNNNNNATTGGAAGANNNRYNTTGACTCAG")
+
+func TestUnsequencedRegions(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligned, _ := NewAlignment(UNSEQUENCED_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	assertSiteScores(t, report)
+	expectUnsequenced := []SequenceRange{{8, 11, 22, 33}}
+	if !reflect.DeepEqual(report.UnsequencedRegions, expectUnsequenced) {
+		t.Errorf(MSG_NOT_EQUAL, expectUnsequenced, report.UnsequencedRegions)
+	}
+	expectCoverage := []SequenceRange{{1, 7, 1, 21}, {12, 19, 34, 57}}
+	if !reflect.DeepEqual(report.Coverage, expectCoverage) {
+		t.Errorf(MSG_NOT_EQUAL, expectCoverage, report.Coverage)
+	}
+	expectMutations := []string{"N15KNTRSIMQHPLEDAGVYCWF*:NNN", "L16TIMAV:RYN"}
+	if texts := mutationTexts(report); !reflect.DeepEqual(texts, expectMutations) {
+		t.Errorf(MSG_NOT_EQUAL, expectMutations, texts)
+	}
+}
+
+func TestUnsequencedRegionsSplit(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.GeneRegions = map[ap.Gene][]ap.Region{"A": EXAMPLE_REGIONS}
+	})
+	aligned, _ := NewAlignment(UNSEQUENCED_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	assertRegions(t, report)
+	expect := [][2][]SequenceRange{
+		{{{8, 8, 22, 24}}, {{1, 7, 1, 21}}},
+		{{{1, 3, 25, 33}}, {{4, 11, 34, 57}}},
+	}
+	for idx, region := range report.Regions {
+		result := [2][]SequenceRange{region.UnsequencedRegions, region.Coverage}
+		if !reflect.DeepEqual(result, expect[idx]) {
+			t.Errorf(MSG_NOT_EQUAL, expect[idx], result)
+		}
+	}
+}
+
+func TestUnsequencedRegionsNone(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligned, _ := NewAlignment(AMBIGUOUS_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	if report.UnsequencedRegions != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, report.UnsequencedRegions)
+	}
+	expect := []SequenceRange{{report.FirstAA, report.LastAA, report.FirstNA, report.LastNA}}
+	if !reflect.DeepEqual(report.Coverage, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, report.Coverage)
+	}
+}
+
+// codons 8 to 11 are mixtures of two nucleotides, as in a mixed
+// infection, rather than unsequenced
+var MIXTURE_NSEQ = n.ReadString("ACAGTATTAGTAGGACCTACAMRYRWKYSRKMSATTGGAAGAAATCTGTTGACTCAG")
+
+func TestUnsequencedRegionsMixtures(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aligned, _ := NewAlignment(MIXTURE_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	if report.UnsequencedRegions != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, report.UnsequencedRegions)
+	}
+	var positions []int
+	for _, mutation := range report.Mutations {
+		positions = append(positions, mutation.Position)
+	}
+	expect := []int{8, 9, 10, 11}
+	if !reflect.DeepEqual(positions, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, positions)
+	}
+}
+
+func TestUnsequencedRegionsMinCodons(t *testing.T) {
+	handler := handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.MinUnsequencedCodons = 5
+	})
+	aligned, _ := NewAlignment(UNSEQUENCED_NSEQ, ASEQ, handler)
+	report := aligned.GetReport()
+	if report.UnsequencedRegions != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, report.UnsequencedRegions)
+	}
+	handler = handlerWith(func(profile *ap.AlignmentProfile) {
+		profile.MinUnsequencedCodons = 4
+	})
+	aligned, _ = NewAlignment(UNSEQUENCED_NSEQ, ASEQ, handler)
+	report = aligned.GetReport()
+	expect := []SequenceRange{{8, 11, 22, 33}}
+	if !reflect.DeepEqual(report.UnsequencedRegions, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, report.UnsequencedRegions)
+	}
+}
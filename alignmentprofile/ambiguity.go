@@ -0,0 +1,130 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	"sort"
+	"strconv"
+)
+
+// An AmbiguousCodonPolicy decides how a codon with ambiguous
+// nucleotides (IUPAC codes) is scored from the substitution scores of
+// the unambiguous codons it stands for, and whether it matches the
+// reference.
+type AmbiguousCodonPolicy string
+
+const (
+	// The average of the scores; the codon only matches if all of its
+	// unambiguous codons do. This is the default policy.
+	AverageAmbiguousCodons AmbiguousCodonPolicy = "average"
+	// The best of the scores; the codon matches if any of its
+	// unambiguous codons does, as with a mixture of the reference and
+	// a variant.
+	BestAmbiguousCodons AmbiguousCodonPolicy = "best"
+	// The worst of the scores; the codon only matches if all of its
+	// unambiguous codons do.
+	WorstAmbiguousCodons AmbiguousCodonPolicy = "worst"
+	// The average of the scores weighted by the prevalence of the
+	// amino acids at the position (see AminoAcidPrevalences); the codon
+	// matches if all of its prevalent unambiguous codons do. Positions
+	// without prevalent amino acids are scored like with
+	// AverageAmbiguousCodons.
+	WeightedAmbiguousCodons AmbiguousCodonPolicy = "weighted"
+)
+
+var ambiguousCodonPolicies = []AmbiguousCodonPolicy{
+	AverageAmbiguousCodons, BestAmbiguousCodons,
+	WorstAmbiguousCodons, WeightedAmbiguousCodons,
+}
+
+// Check that the policy is one of the known ambiguous codon policies
+func (policy AmbiguousCodonPolicy) validate() error {
+	for _, known := range ambiguousCodonPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"Unknown ambiguous codon policy '%v' (expecting one of %v)",
+		policy, ambiguousCodonPolicies)
+}
+
+// ParseAmbiguousCodonPolicy returns the policy named src, or an error
+// if there is none.
+func ParseAmbiguousCodonPolicy(src string) (AmbiguousCodonPolicy, error) {
+	policy := AmbiguousCodonPolicy(src)
+	return policy, policy.validate()
+}
+
+// AminoAcidPrevalences maps the positions of the reference of a gene
+// to the prevalence (in percent) of amino acids at them, such as among
+// the sequences of a population.
+type AminoAcidPrevalences map[int]map[a.AminoAcid]float64
+
+// This structure is a de-serialization target that the YAML package
+// uses to parse the AminoAcidPrevalences in a serialized profile,
+// which are written as [position, amino acid, percent].
+type rawPrevalence struct {
+	Position  int
+	AminoAcid string
+	Percent   float64
+}
+
+func (t *rawPrevalence) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// the fields are read as text, since YAML would read the amino
+	// acids N and Y as booleans
+	var bucket []string
+	if err := unmarshal(&bucket); err == nil && len(bucket) == 3 {
+		position, positionErr := strconv.Atoi(bucket[0])
+		percent, percentErr := strconv.ParseFloat(bucket[2], 64)
+		if positionErr == nil && percentErr == nil {
+			t.Position, t.AminoAcid, t.Percent = position, bucket[1], percent
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"Expecting an amino acid prevalence as [position, amino acid, percent] but got %v", bucket)
+}
+
+// Retrieve the amino acid prevalences of a Gene. Genes without
+// prevalences return nil.
+func (profile *AlignmentProfile) PrevalencesFor(g Gene) AminoAcidPrevalences {
+	return profile.GenePrevalences[g]
+}
+
+// asRaw returns the prevalences as [position, amino acid, percent],
+// sorted by position and amino acid
+func (prevalences AminoAcidPrevalences) asRaw() []rawPrevalence {
+	result := make([]rawPrevalence, 0, len(prevalences))
+	for position, percents := range prevalences {
+		for aa, percent := range percents {
+			result = append(result, rawPrevalence{position, a.ToString(aa), percent})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Position != result[j].Position {
+			return result[i].Position < result[j].Position
+		}
+		return result[i].AminoAcid < result[j].AminoAcid
+	})
+	return result
+}
+
+// Check that the prevalences lie within the reference of refLen amino
+// acids and are percentages
+func validatePrevalences(prevalences AminoAcidPrevalences, refLen int) error {
+	for position, percents := range prevalences {
+		if position < 1 || position > refLen {
+			return fmt.Errorf(
+				"prevalence at %d is not within the reference (1-%d)", position, refLen)
+		}
+		for aa, percent := range percents {
+			if percent < 0 || percent > 100 {
+				return fmt.Errorf(
+					"prevalence of %v at %d is not a percentage (got %v)",
+					a.ToString(aa), position, percent)
+			}
+		}
+	}
+	return nil
+}
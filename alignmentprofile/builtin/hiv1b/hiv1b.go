@@ -215,6 +215,15 @@ var HIV1BRefLookup = ap.ReferenceSeqs{
 	"GP41": HIV1BSEQ_GP41,
 }
 
+// Mature proteins of POL, after the 56 amino acids preceding PR
+var HIV1BGeneRegions = map[ap.Gene][]ap.Region{
+	"POL": {
+		{Name: "PR", Start: 57, End: 155},
+		{Name: "RT", Start: 156, End: 715},
+		{Name: "IN", Start: 716, End: 1003},
+	},
+}
+
 var Profile = ap.AlignmentProfile{
 	StopCodonPenalty:         4,
 	GapOpeningPenalty:        10,
@@ -223,4 +232,5 @@ var Profile = ap.AlignmentProfile{
 	IndelCodonExtensionBonus: 2,
 	GeneIndelScores:          hiv1bPositionalIndelScores,
 	ReferenceSequences:       HIV1BRefLookup,
+	GeneRegions:              HIV1BGeneRegions,
 }
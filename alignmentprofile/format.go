@@ -15,14 +15,63 @@ GapOpeningPenalty: {{.GapOpeningPenalty}}
 GapExtensionPenalty: {{.GapExtensionPenalty}}
 IndelCodonOpeningBonus: {{.IndelCodonOpeningBonus}}
 IndelCodonExtensionBonus: {{.IndelCodonExtensionBonus}}
+{{ if .BandWidth -}} BandWidth: {{.BandWidth}}
+{{ end -}}
+{{ if .MinUnsequencedCodons -}} MinUnsequencedCodons: {{.MinUnsequencedCodons}}
+{{ end -}}
+{{ if .AlignmentMode -}} AlignmentMode: {{.AlignmentMode}}
+{{ end -}}
+{{ if .GeneAlignmentModes -}} GeneAlignmentModes:
+{{ range $gene, $mode := .GeneAlignmentModes }}  {{$gene}}: {{$mode}}
+{{ end -}}
+{{ end -}}
+{{ if .AmbiguousCodonPolicy -}} AmbiguousCodonPolicy: {{.AmbiguousCodonPolicy}}
+{{ end -}}
+{{ if .SubstitutionMatrix -}} SubstitutionMatrix: {{.SubstitutionMatrix}}
+{{ end -}}
+{{ if .GeneticCode -}} GeneticCode: {{.GeneticCode}}
+{{ end -}}
 ReferenceSequences:
 {{ range $gene, $seq := .ReferenceSequences }}  {{$gene}}:
     {{$seq}}
 {{end -}}
+{{ if .ReferenceCDS -}} ReferenceCDS:
+{{ range $gene, $seq := .ReferenceCDS }}  {{$gene}}:
+    {{$seq}}
+{{end -}}
+{{ end -}}
+{{ if .RawRegions -}} GeneRegions:
+{{ range $gene, $regions := .RawRegions }}  {{$gene}}:
+{{- range $regions}}
+    - [ {{.Name}}, {{.Start}}, {{.End}} ]
+{{- end}}
+{{end -}}
+{{ end -}}
+{{ if .RawFrameShifts -}} ExpectedFrameShifts:
+{{ range $gene, $frameShifts := .RawFrameShifts }}  {{$gene}}:
+{{- range $frameShifts}}
+    - [ {{.Position}}, {{.Shift}} ]
+{{- end}}
+{{end -}}
+{{ end -}}
+{{ if .RawPrevalences -}} AminoAcidPrevalences:
+{{ range $gene, $prevalences := .RawPrevalences }}  {{$gene}}:
+{{- range $prevalences}}
+    - [ {{.Position}}, {{.AminoAcid}}, {{.Percent}} ]
+{{- end}}
+{{end -}}
+{{ end -}}
+{{ if .RawPSSMs -}} PositionSpecificScores:
+{{ range $gene, $rows := .RawPSSMs }}  {{$gene}}:
+{{- range $rows}}
+    - [ {{.Position}}{{range .Scores}}, {{.}}{{end}} ]
+{{- end}}
+{{end -}}
+{{ end -}}
 {{ if .RawIndelScores -}} PositionalIndelScores: {{- end }}
 {{range $gene, $rawIndels := .RawIndelScores}}  {{$gene}}:
 {{- range $rawIndels}}
-    - [ {{.Kind}}, {{.Position}}, {{.Open}}, {{.Extend}} ]
+    - [ {{.Kind}}, {{.Positions}}, {{.Open}}, {{.Extend}} ]
 {{- end}}
 {{end}}`
 
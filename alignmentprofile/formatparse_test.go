@@ -1,7 +1,10 @@
 package alignmentprofile
 
 import (
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +51,378 @@ func TestParseFormatRoundTrip(t *testing.T) {
 		t.Errorf("%v != %v", formatted, exampleProfileYAML)
 	}
 }
+
+var bandedProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+BandWidth: 30
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+
+`
+
+func TestBandWidthRoundTrip(t *testing.T) {
+	parsed, err := Parse(bandedProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if parsed.BandWidth != 30 {
+		t.Errorf("%v != %v", parsed.BandWidth, 30)
+	}
+	formatted := Format(*parsed)
+	if formatted != bandedProfileYAML {
+		t.Errorf("%q != %q", formatted, bandedProfileYAML)
+	}
+}
+
+var unsequencedProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+MinUnsequencedCodons: 5
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+
+`
+
+func TestMinUnsequencedCodonsRoundTrip(t *testing.T) {
+	parsed, err := Parse(unsequencedProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if parsed.MinUnsequencedCodonsOrDefault() != 5 {
+		t.Errorf("%v != %v", parsed.MinUnsequencedCodonsOrDefault(), 5)
+	}
+	formatted := Format(*parsed)
+	if formatted != unsequencedProfileYAML {
+		t.Errorf("%q != %q", formatted, unsequencedProfileYAML)
+	}
+	parsed, _ = Parse(bandedProfileYAML)
+	if parsed.MinUnsequencedCodonsOrDefault() != DefaultMinUnsequencedCodons {
+		t.Errorf("%v != %v", parsed.MinUnsequencedCodonsOrDefault(), DefaultMinUnsequencedCodons)
+	}
+}
+
+var modesProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+AlignmentMode: free-query-ends
+GeneAlignmentModes:
+  B: local
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+  B:
+    PIVEHSDEKT
+
+`
+
+func TestAlignmentModesRoundTrip(t *testing.T) {
+	parsed, err := Parse(modesProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if mode := parsed.AlignmentModeFor(Gene("A")); mode != FreeQueryEnds {
+		t.Errorf("%v != %v", mode, FreeQueryEnds)
+	}
+	if mode := parsed.AlignmentModeFor(Gene("B")); mode != Local {
+		t.Errorf("%v != %v", mode, Local)
+	}
+	formatted := Format(*parsed)
+	if formatted != modesProfileYAML {
+		t.Errorf("%q != %q", formatted, modesProfileYAML)
+	}
+}
+
+var cdsProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+  B:
+    TTALIEPPVY
+ReferenceCDS:
+  A:
+    CCTATAGTAGAACATAGTGATGAGAAAACATAA
+
+`
+
+func TestReferenceCDSRoundTrip(t *testing.T) {
+	parsed, err := Parse(cdsProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if codon, ok := parsed.ReferenceCodonFor(Gene("A"), 10); !ok || codon.ToString() != "ACA" {
+		t.Errorf("%v != %v", codon.ToString(), "ACA")
+	}
+	if _, ok := parsed.ReferenceCodonFor(Gene("A"), 11); ok {
+		t.Errorf("Expected no codon after the reference")
+	}
+	if _, ok := parsed.ReferenceCodonFor(Gene("B"), 1); ok {
+		t.Errorf("Expected no codon for a gene without CDS")
+	}
+	formatted := Format(*parsed)
+	if formatted != cdsProfileYAML {
+		t.Errorf("%q != %q", formatted, cdsProfileYAML)
+	}
+}
+
+var regionsProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+  B:
+    TTALIEPPVY
+GeneRegions:
+  A:
+    - [ A1, 1, 4 ]
+    - [ A2, 6, 10 ]
+
+`
+
+func TestGeneRegionsRoundTrip(t *testing.T) {
+	parsed, err := Parse(regionsProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	expected := []Region{{"A1", 1, 4}, {"A2", 6, 10}}
+	if regions := parsed.RegionsFor(Gene("A")); !reflect.DeepEqual(regions, expected) {
+		t.Errorf("%v != %v", regions, expected)
+	}
+	if regions := parsed.RegionsFor(Gene("B")); regions != nil {
+		t.Errorf("Expected no regions for gene B but got %v", regions)
+	}
+	formatted := Format(*parsed)
+	if formatted != regionsProfileYAML {
+		t.Errorf("%q != %q", formatted, regionsProfileYAML)
+	}
+}
+
+var frameShiftsProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+ExpectedFrameShifts:
+  A:
+    - [ 3, -1 ]
+    - [ 7, 2 ]
+
+`
+
+func TestExpectedFrameShiftsRoundTrip(t *testing.T) {
+	parsed, err := Parse(frameShiftsProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	expected := []ExpectedFrameShift{{3, -1}, {7, 2}}
+	if frameShifts := parsed.ExpectedFrameShiftsFor(Gene("A")); !reflect.DeepEqual(frameShifts, expected) {
+		t.Errorf("%v != %v", frameShifts, expected)
+	}
+	formatted := Format(*parsed)
+	if formatted != frameShiftsProfileYAML {
+		t.Errorf("%q != %q", formatted, frameShiftsProfileYAML)
+	}
+}
+
+var ambiguityProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+AmbiguousCodonPolicy: weighted
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+AminoAcidPrevalences:
+  A:
+    - [ 2, I, 97.5 ]
+    - [ 2, V, 2.5 ]
+    - [ 9, K, 100 ]
+    - [ 10, N, 80 ]
+    - [ 10, Y, 20 ]
+
+`
+
+func TestAmbiguousCodonPolicyRoundTrip(t *testing.T) {
+	parsed, err := Parse(ambiguityProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if parsed.AmbiguousCodonPolicy != WeightedAmbiguousCodons {
+		t.Errorf("%v != %v", parsed.AmbiguousCodonPolicy, WeightedAmbiguousCodons)
+	}
+	expected := AminoAcidPrevalences{
+		2: {a.I: 97.5, a.V: 2.5}, 9: {a.K: 100}, 10: {a.N: 80, a.Y: 20}}
+	if prevalences := parsed.PrevalencesFor(Gene("A")); !reflect.DeepEqual(prevalences, expected) {
+		t.Errorf("%v != %v", prevalences, expected)
+	}
+	formatted := Format(*parsed)
+	if formatted != ambiguityProfileYAML {
+		t.Errorf("%q != %q", formatted, ambiguityProfileYAML)
+	}
+}
+
+var matrixProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+SubstitutionMatrix: BLOSUM62
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+
+`
+
+func TestSubstitutionMatrixRoundTrip(t *testing.T) {
+	parsed, err := Parse(strings.Replace(matrixProfileYAML, "BLOSUM62", "blosum62", 1))
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if parsed.SubstitutionMatrix != d.Blosum62 {
+		t.Errorf("%v != %v", parsed.SubstitutionMatrix, d.Blosum62)
+	}
+	formatted := Format(*parsed)
+	if formatted != matrixProfileYAML {
+		t.Errorf("%q != %q", formatted, matrixProfileYAML)
+	}
+}
+
+var pssmProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+PositionSpecificScores:
+  A:
+    - [ 3, 0, -3, -3, -2, -1, -3, -3, 3, -2, 1, 1, -3, -2, -2, -3, -2, 0, 4, -3, -1 ]
+    - [ 5, -2, -3, -1, 0, -1, -2, 8, -3, -1, -3, -2, 1, -2, 0, 0, -1, -2, -3, -2, 2 ]
+
+`
+
+func TestPSSMRoundTrip(t *testing.T) {
+	parsed, err := Parse(pssmProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	expected := PSSM{
+		3: {0, -3, -3, -2, -1, -3, -3, 3, -2, 1, 1, -3, -2, -2, -3, -2, 0, 4, -3, -1},
+		5: {-2, -3, -1, 0, -1, -2, 8, -3, -1, -3, -2, 1, -2, 0, 0, -1, -2, -3, -2, 2},
+	}
+	if pssm := parsed.PSSMFor(Gene("A")); !reflect.DeepEqual(pssm, expected) {
+		t.Errorf("%v != %v", pssm, expected)
+	}
+	if pssm := parsed.PSSMFor(Gene("B")); pssm != nil {
+		t.Errorf("%v != nil", pssm)
+	}
+	formatted := Format(*parsed)
+	if formatted != pssmProfileYAML {
+		t.Errorf("%q != %q", formatted, pssmProfileYAML)
+	}
+}
+
+var indelRangesProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    PIVEHSDEKT
+PositionalIndelScores:
+  A:
+    - [ ins, '*', -3, -1 ]
+    - [ del, '*', -2, -2 ]
+    - [ ins, 2, 6, 0 ]
+    - [ del, 3-8, -6, 0 ]
+    - [ ins, 4-5, -6, 0 ]
+`
+
+func TestIndelRangesRoundTrip(t *testing.T) {
+	parsed, err := Parse(indelRangesProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	expected := IndelScoreDefaults{Ins: &[2]int{-3, -1}, Del: &[2]int{-2, -2}}
+	if defaults, _ := parsed.IndelScoreDefaultsFor(Gene("A")); !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("%v != %v", defaults, expected)
+	}
+	if scores, _ := parsed.PositionalIndelScoresFor(Gene("A")); len(scores) != 9 {
+		t.Errorf("Expected 9 positional indel scores but got %v", scores)
+	}
+	formatted := Format(*parsed)
+	if formatted != indelRangesProfileYAML {
+		t.Errorf("%q != %q", formatted, indelRangesProfileYAML)
+	}
+	// indel scores apply to both insertions and deletions
+	parsed, err = Parse(strings.Replace(
+		indelRangesProfileYAML, "[ ins, 4-5, -6, 0 ]", "[ indel, 9, -6, 0 ]", 1))
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if scores, _ := parsed.PositionalIndelScoresFor(Gene("A")); scores[9] != scores[-9] {
+		t.Errorf("%v != %v", scores[9], scores[-9])
+	}
+}
+
+var geneticCodeProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+GeneticCode: 2
+ReferenceSequences:
+  A:
+    PWVEHSDEKT
+ReferenceCDS:
+  A:
+    CCTTGAGTAGAACATAGTGATGAGAAAACAAGA
+
+`
+
+func TestGeneticCodeRoundTrip(t *testing.T) {
+	parsed, err := Parse(geneticCodeProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if code := parsed.GeneticCodeOrDefault(); code.ID != 2 {
+		t.Errorf("%v != %v", code.ID, 2)
+	}
+	formatted := Format(*parsed)
+	if formatted != geneticCodeProfileYAML {
+		t.Errorf("%q != %q", formatted, geneticCodeProfileYAML)
+	}
+}
@@ -0,0 +1,65 @@
+package alignmentprofile
+
+import "fmt"
+
+// An ExpectedFrameShift is a frameshift which the sequences of a gene
+// are expected to have, such as a programmed ribosomal frameshift. The
+// reading frame moves by Shift nucleotides at the codon of the
+// reference at Position: a shift of -1 or -2 reads the last one or two
+// nucleotides of the previous codon again as the first ones of this
+// codon, while a shift of 1 or 2 skips the nucleotides after it.
+type ExpectedFrameShift struct {
+	Position int
+	Shift    int
+}
+
+// This structure is a de-serialization target that the YAML package
+// uses to parse the ExpectedFrameShifts in a serialized profile, which
+// are written as [position, shift].
+type rawFrameShift struct {
+	Position int
+	Shift    int
+}
+
+func (t *rawFrameShift) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var bucket []int
+	if err := unmarshal(&bucket); err != nil || len(bucket) != 2 {
+		return fmt.Errorf("Expecting an expected frameshift as [position, shift]")
+	}
+	t.Position, t.Shift = bucket[0], bucket[1]
+	return nil
+}
+
+// Retrieve the expected frameshifts of a Gene, in the order of the
+// reference. Genes without expected frameshifts return nil.
+func (profile *AlignmentProfile) ExpectedFrameShiftsFor(g Gene) []ExpectedFrameShift {
+	return profile.GeneFrameShifts[g]
+}
+
+// Check that the frameshifts lie within the reference of refLen amino
+// acids, in order and one per position, and that they shift the reading
+// frame by one or two nucleotides
+func validateFrameShifts(frameShifts []ExpectedFrameShift, refLen int) error {
+	prevPosition := 0
+	for _, frameShift := range frameShifts {
+		switch {
+		case frameShift.Position < 1 || frameShift.Position > refLen:
+			return fmt.Errorf(
+				"frameshift at %d is not within the reference (1-%d)",
+				frameShift.Position, refLen)
+		case frameShift.Position <= prevPosition:
+			return fmt.Errorf(
+				"frameshift at %d duplicates the previous one or is out of order",
+				frameShift.Position)
+		case frameShift.Shift == 0 || frameShift.Shift < -2 || frameShift.Shift > 2:
+			return fmt.Errorf(
+				"frameshift at %d must shift by -2, -1, 1 or 2 nucleotides (got %d)",
+				frameShift.Position, frameShift.Shift)
+		case frameShift.Shift < 0 && frameShift.Position == 1:
+			return fmt.Errorf(
+				"frameshift at 1 can't read the nucleotides of a previous codon")
+		}
+		prevPosition = frameShift.Position
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package alignmentprofile
+
+import "fmt"
+
+// An AlignmentMode decides which leading and trailing gaps of an
+// alignment are free of penalty.
+type AlignmentMode string
+
+const (
+	// Gaps at either end of both sequences are free. This is the
+	// default mode.
+	FreeEnds AlignmentMode = "free-ends"
+	// Unaligned nucleotides at either end of the query are free, but
+	// the whole reference must be aligned; for queries known to cover
+	// the whole gene.
+	FreeQueryEnds AlignmentMode = "free-query-ends"
+	// Unaligned amino acids at either end of the reference are free,
+	// but the whole query must be aligned; for queries known to lie
+	// within the gene.
+	FreeReferenceEnds AlignmentMode = "free-reference-ends"
+	// Both sequences must be aligned end to end.
+	Global AlignmentMode = "global"
+	// Only the best scoring parts of the sequences are aligned.
+	Local AlignmentMode = "local"
+)
+
+var alignmentModes = []AlignmentMode{
+	FreeEnds, FreeQueryEnds, FreeReferenceEnds, Global, Local,
+}
+
+// Check that the mode is one of the known alignment modes
+func (mode AlignmentMode) validate() error {
+	for _, known := range alignmentModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"Unknown alignment mode '%v' (expecting one of %v)", mode, alignmentModes)
+}
+
+// Retrieve the alignment mode for a Gene: its own mode if it has one,
+// otherwise the mode of the profile, otherwise FreeEnds.
+func (profile *AlignmentProfile) AlignmentModeFor(g Gene) AlignmentMode {
+	if mode, found := profile.GeneAlignmentModes[g]; found {
+		return mode
+	}
+	if profile.AlignmentMode != "" {
+		return profile.AlignmentMode
+	}
+	return FreeEnds
+}
@@ -2,6 +2,8 @@ package alignmentprofile
 
 import (
 	yaml "gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
 )
 
 func (p *AlignmentProfile) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -20,8 +22,29 @@ func (p *AlignmentProfile) UnmarshalYAML(unmarshal func(interface{}) error) erro
 
 // Parse an AlignmentProfile from YAML
 func Parse(src string) (*AlignmentProfile, error) {
-	var profile AlignmentProfile
-	err := yaml.Unmarshal([]byte(src), &profile)
+	return ParseIn("", src)
+}
+
+// Parse an AlignmentProfile from a YAML file. The relative paths in
+// the profile, such as the one of SubstitutionMatrix, are relative to
+// the directory of the file.
+func ParseFile(path string) (*AlignmentProfile, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIn(filepath.Dir(path), string(src))
+}
+
+// Parse an AlignmentProfile from YAML, resolving its relative paths
+// against dir rather than the working directory.
+func ParseIn(dir string, src string) (*AlignmentProfile, error) {
+	raw := rawAlignmentProfile{dir: dir}
+	err := yaml.Unmarshal([]byte(src), &raw)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := raw.asProfile()
 	if err != nil {
 		return nil, err
 	}
@@ -29,5 +52,5 @@ func Parse(src string) (*AlignmentProfile, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &profile, nil
+	return profile, nil
 }
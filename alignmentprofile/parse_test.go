@@ -1,6 +1,13 @@
 package alignmentprofile
 
-import "testing"
+import (
+	a "github.com/hivdb/nucamino/types/amino"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestUnmarshalEmpty(t *testing.T) {
 	src := ""
@@ -21,3 +28,339 @@ IndelCodonExtensionBonus: 0`
 		t.Errorf("Expected error when missing ReferenceSequences")
 	}
 }
+
+func TestNegativeBandWidth(t *testing.T) {
+	src := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+BandWidth: -3
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH`
+	_, err := Parse(src)
+	if err == nil {
+		t.Errorf("Expected error on negative BandWidth")
+	}
+}
+
+func TestNegativeMinUnsequencedCodons(t *testing.T) {
+	src := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+MinUnsequencedCodons: -1
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH`
+	_, err := Parse(src)
+	if err == nil {
+		t.Errorf("Expected error on negative MinUnsequencedCodons")
+	}
+}
+
+func TestUnknownAlignmentMode(t *testing.T) {
+	src := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+GeneAlignmentModes:
+  A: semi-local
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH`
+	_, err := Parse(src)
+	if err == nil {
+		t.Errorf("Expected error on unknown alignment mode")
+	}
+}
+
+func TestDefaultAlignmentMode(t *testing.T) {
+	var profile AlignmentProfile
+	if mode := profile.AlignmentModeFor(Gene("A")); mode != FreeEnds {
+		t.Errorf("%v != %v", mode, FreeEnds)
+	}
+}
+
+func TestInvalidReferenceCDS(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+ReferenceCDS:
+  A:
+    `
+	errCases := []string{
+		// too short
+		"CCTATAGTAGAA",
+		// S instead of E at 4
+		"CCTATAGTAAGTCAT",
+		// ambiguous codon
+		"CCTATAGTAGARCAT",
+		// not followed by a stop codon
+		"CCTATAGTAGAACATCAT",
+	}
+	for _, cds := range errCases {
+		if _, err := Parse(header + cds); err == nil {
+			t.Errorf("Expected error on invalid ReferenceCDS %v", cds)
+		}
+	}
+	if _, err := Parse(header + "CCTATAGTAGAACATTGA"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInvalidGeneRegions(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+  B:
+    PIVEH
+GeneRegions:
+  A:
+`
+	errCases := []string{
+		// beyond the reference
+		"    - [ A1, 1, 6 ]",
+		// ends before it starts
+		"    - [ A1, 3, 2 ]",
+		// overlapping
+		"    - [ A1, 1, 3 ]\n    - [ A2, 3, 5 ]",
+		// duplicate names
+		"    - [ A1, 1, 2 ]\n    - [ A1, 3, 5 ]",
+		// missing end
+		"    - [ A1, 1 ]",
+		// named after a gene
+		"    - [ B, 1, 2 ]",
+		// also a region of another gene
+		"    - [ A1, 1, 2 ]\n  B:\n    - [ A1, 1, 2 ]",
+	}
+	for _, regions := range errCases {
+		if _, err := Parse(header + regions); err == nil {
+			t.Errorf("Expected error on invalid GeneRegions %v", regions)
+		}
+	}
+	if _, err := Parse(header + "    - [ A1, 1, 2 ]\n    - [ A2, 4, 5 ]"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInvalidExpectedFrameShifts(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+ExpectedFrameShifts:
+  A:
+`
+	errCases := []string{
+		// beyond the reference
+		"    - [ 6, -1 ]",
+		// no previous codon to read again
+		"    - [ 1, -1 ]",
+		// not a frameshift
+		"    - [ 2, 3 ]",
+		"    - [ 2, 0 ]",
+		// out of order
+		"    - [ 3, 1 ]\n    - [ 2, 1 ]",
+		// missing shift
+		"    - [ 2 ]",
+	}
+	for _, frameShifts := range errCases {
+		if _, err := Parse(header + frameShifts); err == nil {
+			t.Errorf("Expected error on invalid ExpectedFrameShifts %v", frameShifts)
+		}
+	}
+	if _, err := Parse(header + "    - [ 1, 2 ]\n    - [ 4, -2 ]"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInvalidAmbiguousCodonPolicy(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+`
+	errCases := []string{
+		// unknown policy
+		"AmbiguousCodonPolicy: median",
+		// beyond the reference
+		"AminoAcidPrevalences:\n  A:\n    - [ 6, I, 50 ]",
+		// unknown amino acid
+		"AminoAcidPrevalences:\n  A:\n    - [ 2, X, 50 ]",
+		// not a percentage
+		"AminoAcidPrevalences:\n  A:\n    - [ 2, I, 150 ]",
+		// missing percentage
+		"AminoAcidPrevalences:\n  A:\n    - [ 2, I ]",
+	}
+	for _, src := range errCases {
+		if _, err := Parse(header + src); err == nil {
+			t.Errorf("Expected error on %v", src)
+		}
+	}
+	if _, err := Parse(header + "AmbiguousCodonPolicy: best"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInvalidSubstitutionMatrix(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+`
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a matrix which only scores A
+	path := filepath.Join(dir, "matrix.txt")
+	if err := ioutil.WriteFile(path, []byte("   A\nA  4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errCases := []string{
+		// neither a built-in matrix nor a file
+		"SubstitutionMatrix: " + filepath.Join(dir, "BLOSUM30"),
+		// not all amino acids
+		"SubstitutionMatrix: " + path,
+	}
+	for _, src := range errCases {
+		if _, err := Parse(header + src); err == nil {
+			t.Errorf("Expected error on %v", src)
+		}
+	}
+}
+
+func TestParseFileRelativeSubstitutionMatrix(t *testing.T) {
+	src := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+SubstitutionMatrix: matrix.txt
+ReferenceSequences:
+  A:
+    PIVEH
+`
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a matrix which scores every substitution 1
+	letters := "ARNDCQEGHILKMFPSTWYV"
+	matrixText := " " + strings.Join(strings.Split(letters, ""), " ") + "\n"
+	for _, letter := range letters {
+		matrixText += string(letter) + strings.Repeat(" 1", len(letters)) + "\n"
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "matrix.txt"), []byte(matrixText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "profile.yaml")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if score := profile.SubstitutionMatrix.Lookup(a.W, a.C); score != 1 {
+		t.Errorf("%v != %v", score, 1)
+	}
+	// the matrix keeps the path of the profile, relative to its file
+	if profile.SubstitutionMatrix.Name != "matrix.txt" {
+		t.Errorf("%v != %v", profile.SubstitutionMatrix.Name, "matrix.txt")
+	}
+	// the working directory has no such matrix
+	if _, err := Parse(src); err == nil {
+		t.Errorf("Expected error on a matrix relative to the working directory")
+	}
+}
+
+func TestInvalidPSSM(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PIVEH
+PositionSpecificScores:
+  A:
+`
+	scores := ", 4, 0, -2, -1, -2, 0, -2, -1, -1, -1, -1, -2, -1, -1, -1, 1, 0, 0, -3, -2 ]"
+	errCases := []string{
+		// beyond the reference
+		"    - [ 6" + scores,
+		"    - [ 0" + scores,
+		// missing scores
+		"    - [ 2, 4, 0, -2 ]",
+		// duplicated position
+		"    - [ 2" + scores + "\n    - [ 2" + scores,
+	}
+	for _, pssm := range errCases {
+		if _, err := Parse(header + pssm); err == nil {
+			t.Errorf("Expected error on invalid PositionSpecificScores %v", pssm)
+		}
+	}
+	if _, err := Parse(header + "    - [ 5" + scores + "\n    - [ 1" + scores); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInvalidGeneticCode(t *testing.T) {
+	header := `StopCodonPenalty: 0
+GapOpeningPenalty: 0
+GapExtensionPenalty: 0
+IndelCodonOpeningBonus: 0
+IndelCodonExtensionBonus: 0
+ReferenceSequences:
+  A:
+    PWVEH
+ReferenceCDS:
+  A:
+    CCTTGAGTAGAACATAGA
+`
+	errCases := []string{
+		// not an NCBI translation table
+		"GeneticCode: 7",
+		// TGA and AGA don't code W and stop in the standard code
+		"GeneticCode: 1",
+		"",
+	}
+	for _, src := range errCases {
+		if _, err := Parse(header + src); err == nil {
+			t.Errorf("Expected error on %q", src)
+		}
+	}
+	if _, err := Parse(header + "GeneticCode: 2"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
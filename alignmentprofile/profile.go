@@ -5,14 +5,31 @@ package alignmentprofile
 
 import (
 	"fmt"
+	d "github.com/hivdb/nucamino/data"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
 	"sort"
 )
 
+// DefaultMinUnsequencedCodons is the MinUnsequencedCodons of profiles
+// which don't set it.
+const DefaultMinUnsequencedCodons = 3
+
 type Gene string
 type PositionalIndelScores map[int]([2]int)
 type GenePositionalIndelScores map[Gene]PositionalIndelScores
 type ReferenceSeqs map[Gene][]a.AminoAcid
+type ReferenceNASeqs map[Gene][]n.NucleicAcid
+
+// IndelScoreDefaults holds the opening and extension bonuses of the
+// insertions and deletions at the positions of a gene without
+// positional indel scores. Ins or Del is nil to keep the constant
+// bonuses of the profile.
+type IndelScoreDefaults struct {
+	Ins *[2]int
+	Del *[2]int
+}
 
 // This stores the all the information needed to align a sequence to a
 // reference: reference sequences, alignment parameters, and
@@ -23,8 +40,46 @@ type AlignmentProfile struct {
 	GapExtensionPenalty      int
 	IndelCodonOpeningBonus   int
 	IndelCodonExtensionBonus int
-	GeneIndelScores          GenePositionalIndelScores
-	ReferenceSequences       ReferenceSeqs
+	// Width (in nucleotides) of the band around the diagonal that the
+	// aligner fills; zero disables banding.
+	BandWidth int
+	// Least number of consecutive unsequenced codons reported as an
+	// unsequenced region; zero means DefaultMinUnsequencedCodons.
+	MinUnsequencedCodons int
+	// Decides which end gaps are free.
+	AlignmentMode AlignmentMode
+	// Overrides AlignmentMode for some genes.
+	GeneAlignmentModes map[Gene]AlignmentMode
+	// Decides how codons with ambiguous nucleotides are scored.
+	AmbiguousCodonPolicy AmbiguousCodonPolicy
+	// Scores the substitutions of amino acids; BLOSUM62 is used when
+	// it's nil.
+	SubstitutionMatrix *d.SubstitutionMatrix
+	// Translates the codons of the genes, such as the vertebrate
+	// mitochondrial code; the standard code is used when it's nil.
+	GeneticCode     *c.GeneticCode
+	GeneIndelScores GenePositionalIndelScores
+	// Indel scores of the positions of a gene without positional indel
+	// scores.
+	GeneIndelDefaults  map[Gene]IndelScoreDefaults
+	ReferenceSequences ReferenceSeqs
+	// Optional coding sequence of the reference of each gene, which
+	// lets the alignment report synonymous codon differences.
+	ReferenceCDS ReferenceNASeqs
+	// Optionally splits the reference of a gene into named regions,
+	// such as the mature proteins of a polyprotein, which can be
+	// reported separately.
+	GeneRegions map[Gene][]Region
+	// Frameshifts which the sequences of a gene are expected to have,
+	// such as the programmed ribosomal frameshift of HIV-1 gag-pol;
+	// the aligner doesn't penalize them.
+	GeneFrameShifts map[Gene][]ExpectedFrameShift
+	// Amino acid prevalences which AmbiguousCodonPolicy may weigh
+	// ambiguous codons by.
+	GenePrevalences map[Gene]AminoAcidPrevalences
+	// Position-specific scores which replace SubstitutionMatrix at
+	// some positions of a gene.
+	GenePSSMs map[Gene]PSSM
 }
 
 // An array of all the genes supported by this alignment profile.
@@ -62,7 +117,42 @@ func (profile AlignmentProfile) rawIndelScores() map[string][]rawIndelScore {
 			rawIndelScores = append(rawIndelScores, rawScore)
 		}
 		sort.Sort(byPositionAndKind(rawIndelScores))
-		result[string(gene)] = rawIndelScores
+		result[string(gene)] = compressIndelScores(rawIndelScores)
+	}
+	for gene, defaults := range profile.GeneIndelDefaults {
+		var rawDefaults []rawIndelScore
+		if defaults.Ins != nil {
+			rawDefaults = append(rawDefaults, rawIndelScore{
+				Kind: "ins", IsDefault: true, Open: defaults.Ins[0], Extend: defaults.Ins[1]})
+		}
+		if defaults.Del != nil {
+			rawDefaults = append(rawDefaults, rawIndelScore{
+				Kind: "del", IsDefault: true, Open: defaults.Del[0], Extend: defaults.Del[1]})
+		}
+		result[string(gene)] = append(rawDefaults, result[string(gene)]...)
+	}
+	return result
+}
+
+// compressIndelScores joins the sorted scores of consecutive positions
+// of the same kind into ranges when they are the same.
+func compressIndelScores(sorted []rawIndelScore) []rawIndelScore {
+	var (
+		result = make([]rawIndelScore, 0, len(sorted))
+		// the index in result of the last scores of each kind
+		lastIdx = make(map[string]int)
+	)
+	for _, indelScore := range sorted {
+		if idx, found := lastIdx[indelScore.Kind]; found {
+			last := &result[idx]
+			if last.lastPosition()+1 == indelScore.Position &&
+				last.Open == indelScore.Open && last.Extend == indelScore.Extend {
+				last.End = indelScore.lastPosition()
+				continue
+			}
+		}
+		lastIdx[indelScore.Kind] = len(result)
+		result = append(result, indelScore)
 	}
 	return result
 }
@@ -74,13 +164,71 @@ func (profile AlignmentProfile) asRaw() rawAlignmentProfile {
 	raw.GapExtensionPenalty = profile.GapExtensionPenalty
 	raw.IndelCodonOpeningBonus = profile.IndelCodonOpeningBonus
 	raw.IndelCodonExtensionBonus = profile.IndelCodonExtensionBonus
+	raw.BandWidth = profile.BandWidth
+	raw.MinUnsequencedCodons = profile.MinUnsequencedCodons
+	raw.AlignmentMode = string(profile.AlignmentMode)
+	raw.AmbiguousCodonPolicy = string(profile.AmbiguousCodonPolicy)
+	if profile.SubstitutionMatrix != nil {
+		raw.SubstitutionMatrix = profile.SubstitutionMatrix.Name
+	}
+	if profile.GeneticCode != nil {
+		raw.GeneticCode = profile.GeneticCode.ID
+	}
+
+	if len(profile.GeneAlignmentModes) > 0 {
+		raw.GeneAlignmentModes = make(map[string]string)
+		for gene, mode := range profile.GeneAlignmentModes {
+			raw.GeneAlignmentModes[string(gene)] = string(mode)
+		}
+	}
 
 	raw.ReferenceSequences = make(map[string]string)
 	for gene, aaSeq := range profile.ReferenceSequences {
 		raw.ReferenceSequences[string(gene)] = a.WriteString(aaSeq)
 	}
 
-	if profile.GeneIndelScores != nil {
+	if len(profile.ReferenceCDS) > 0 {
+		raw.ReferenceCDS = make(map[string]string)
+		for gene, naSeq := range profile.ReferenceCDS {
+			raw.ReferenceCDS[string(gene)] = n.WriteString(naSeq)
+		}
+	}
+
+	if len(profile.GeneRegions) > 0 {
+		raw.RawRegions = make(map[string][]rawRegion)
+		for gene, regions := range profile.GeneRegions {
+			for _, region := range regions {
+				raw.RawRegions[string(gene)] = append(
+					raw.RawRegions[string(gene)], rawRegion(region))
+			}
+		}
+	}
+
+	if len(profile.GeneFrameShifts) > 0 {
+		raw.RawFrameShifts = make(map[string][]rawFrameShift)
+		for gene, frameShifts := range profile.GeneFrameShifts {
+			for _, frameShift := range frameShifts {
+				raw.RawFrameShifts[string(gene)] = append(
+					raw.RawFrameShifts[string(gene)], rawFrameShift(frameShift))
+			}
+		}
+	}
+
+	if len(profile.GenePrevalences) > 0 {
+		raw.RawPrevalences = make(map[string][]rawPrevalence)
+		for gene, prevalences := range profile.GenePrevalences {
+			raw.RawPrevalences[string(gene)] = prevalences.asRaw()
+		}
+	}
+
+	if len(profile.GenePSSMs) > 0 {
+		raw.RawPSSMs = make(map[string][]rawPSSMRow)
+		for gene, pssm := range profile.GenePSSMs {
+			raw.RawPSSMs[string(gene)] = pssm.asRaw()
+		}
+	}
+
+	if profile.GeneIndelScores != nil || profile.GeneIndelDefaults != nil {
 		raw.RawIndelScores = profile.rawIndelScores()
 	}
 	return raw
@@ -92,10 +240,107 @@ func (profile *AlignmentProfile) PositionalIndelScoresFor(g Gene) (PositionalInd
 	return scores, found
 }
 
-// Check that the profile isn't empty
+// Retrieve the genetic code of the profile, which is the standard code
+// unless the profile declares another one.
+func (profile *AlignmentProfile) GeneticCodeOrDefault() *c.GeneticCode {
+	if profile.GeneticCode == nil {
+		return c.StandardCode
+	}
+	return profile.GeneticCode
+}
+
+// Retrieve the least number of consecutive unsequenced codons reported
+// as an unsequenced region.
+func (profile *AlignmentProfile) MinUnsequencedCodonsOrDefault() int {
+	if profile.MinUnsequencedCodons == 0 {
+		return DefaultMinUnsequencedCodons
+	}
+	return profile.MinUnsequencedCodons
+}
+
+// Retrieve the default indel scores of a Gene, if it has some.
+func (profile *AlignmentProfile) IndelScoreDefaultsFor(g Gene) (IndelScoreDefaults, bool) {
+	defaults, found := profile.GeneIndelDefaults[g]
+	return defaults, found
+}
+
+// Check that the profile isn't empty, that its coding sequences
+// translate to the reference sequences, and that its regions, expected
+// frameshifts, prevalences and position-specific scores fit them
 func (profile AlignmentProfile) validate() error {
 	if len(profile.ReferenceSequences) == 0 {
 		return fmt.Errorf("Missing key: ReferenceSequence")
 	}
+	for gene, cds := range profile.ReferenceCDS {
+		if err := validateCDS(cds, profile.ReferenceSequences[gene], profile.GeneticCodeOrDefault()); err != nil {
+			return fmt.Errorf("Invalid ReferenceCDS of %v: %v", gene, err)
+		}
+	}
+	regionGenes := make(map[string]Gene)
+	for gene, regions := range profile.GeneRegions {
+		if err := validateRegions(regions, len(profile.ReferenceSequences[gene]), profile.ReferenceSequences); err != nil {
+			return fmt.Errorf("Invalid GeneRegions of %v: %v", gene, err)
+		}
+		for _, region := range regions {
+			if other, ok := regionGenes[region.Name]; ok {
+				return fmt.Errorf("Invalid GeneRegions of %v: region %v is also a region of %v", gene, region.Name, other)
+			}
+			regionGenes[region.Name] = gene
+		}
+	}
+	for gene, frameShifts := range profile.GeneFrameShifts {
+		if err := validateFrameShifts(frameShifts, len(profile.ReferenceSequences[gene])); err != nil {
+			return fmt.Errorf("Invalid ExpectedFrameShifts of %v: %v", gene, err)
+		}
+	}
+	for gene, prevalences := range profile.GenePrevalences {
+		if err := validatePrevalences(prevalences, len(profile.ReferenceSequences[gene])); err != nil {
+			return fmt.Errorf("Invalid AminoAcidPrevalences of %v: %v", gene, err)
+		}
+	}
+	for gene, pssm := range profile.GenePSSMs {
+		if err := validatePSSM(pssm, len(profile.ReferenceSequences[gene])); err != nil {
+			return fmt.Errorf("Invalid PositionSpecificScores of %v: %v", gene, err)
+		}
+	}
+	return nil
+}
+
+// Check that cds translates to aaSeq in the genetic code, optionally
+// followed by a stop codon
+func validateCDS(cds []n.NucleicAcid, aaSeq []a.AminoAcid, code *c.GeneticCode) error {
+	if len(aaSeq) == 0 {
+		return fmt.Errorf("no reference sequence")
+	}
+	if len(cds) != 3*len(aaSeq) && len(cds) != 3*len(aaSeq)+3 {
+		return fmt.Errorf(
+			"expecting %d nucleotides but got %d", 3*len(aaSeq), len(cds))
+	}
+	for idx := 0; idx < len(cds); idx += 3 {
+		codon := c.Codon{Base1: cds[idx], Base2: cds[idx+1], Base3: cds[idx+2]}
+		switch {
+		case codon.IsAmbiguous():
+			return fmt.Errorf("ambiguous codon %v at %d", codon.ToString(), idx/3+1)
+		case idx/3 == len(aaSeq):
+			if !code.IsStopCodon(codon) {
+				return fmt.Errorf("expecting a stop codon but got %v", codon.ToString())
+			}
+		case code.IsStopCodon(codon) || code.ToAminoAcidUnsafe(codon) != aaSeq[idx/3]:
+			return fmt.Errorf(
+				"codon %v at %d does not code %v",
+				codon.ToString(), idx/3+1, a.ToString(aaSeq[idx/3]))
+		}
+	}
 	return nil
 }
+
+// Retrieve the reference codon at an amino acid position of a Gene, if
+// the profile has the coding sequence of the gene.
+func (profile *AlignmentProfile) ReferenceCodonFor(g Gene, position int) (c.Codon, bool) {
+	cds := profile.ReferenceCDS[g]
+	if position < 1 || 3*position > len(cds) || position > len(profile.ReferenceSequences[g]) {
+		return c.Codon{}, false
+	}
+	idx := 3 * (position - 1)
+	return c.Codon{Base1: cds[idx], Base2: cds[idx+1], Base3: cds[idx+2]}, true
+}
@@ -68,6 +68,38 @@ func TestRawIndelScoresFromProfile(t *testing.T) {
 	}
 }
 
+func TestCompressRawIndelScores(t *testing.T) {
+	profile := AlignmentProfile{
+		GeneIndelScores: GenePositionalIndelScores{
+			Gene("A"): PositionalIndelScores{
+				2:  [2]int{1, 2},
+				3:  [2]int{1, 2},
+				4:  [2]int{1, 2},
+				5:  [2]int{1, 3},
+				7:  [2]int{1, 3},
+				-3: [2]int{1, 2},
+				-4: [2]int{1, 2},
+			},
+		},
+		GeneIndelDefaults: map[Gene]IndelScoreDefaults{
+			Gene("A"): {Del: &[2]int{-1, -1}},
+		},
+	}
+	expected := map[string][]rawIndelScore{
+		"A": []rawIndelScore{
+			{Kind: "del", IsDefault: true, Open: -1, Extend: -1},
+			{Kind: "ins", Position: 2, End: 4, Open: 1, Extend: 2},
+			{Kind: "del", Position: 3, End: 4, Open: 1, Extend: 2},
+			{Kind: "ins", Position: 5, Open: 1, Extend: 3},
+			{Kind: "ins", Position: 7, Open: 1, Extend: 3},
+		},
+	}
+	constructed := profile.rawIndelScores()
+	if !reflect.DeepEqual(constructed, expected) {
+		t.Errorf("%v != %v", constructed, expected)
+	}
+}
+
 func TestRoundTripToRawProfile(t *testing.T) {
 	raw := exampleProfile.asRaw()
 	constructed, err := raw.asProfile()
@@ -0,0 +1,64 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	"sort"
+)
+
+// A PSSM (position-specific scoring matrix) maps positions of the
+// reference of a gene to the score of each amino acid there, in the
+// order of a.AminoAcids, such as scores derived from an alignment of
+// the subtypes of a hypervariable region. They replace the
+// substitution matrix at these positions.
+type PSSM map[int][a.NumAminoAcids]int
+
+// This structure is a de-serialization target that the YAML package
+// uses to parse the PositionSpecificScores in a serialized profile,
+// which are written as the position followed by the scores of the
+// amino acids in alphabetical order (A, C, D, ..., W, Y).
+type rawPSSMRow struct {
+	Position int
+	Scores   [a.NumAminoAcids]int
+}
+
+func (t *rawPSSMRow) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var bucket []int
+	if err := unmarshal(&bucket); err != nil || len(bucket) != a.NumAminoAcids+1 {
+		return fmt.Errorf(
+			"Expecting position-specific scores as [position, %d scores] but got %v",
+			a.NumAminoAcids, bucket)
+	}
+	t.Position = bucket[0]
+	copy(t.Scores[:], bucket[1:])
+	return nil
+}
+
+// Retrieve the position-specific scores of a Gene. Genes without
+// position-specific scores return nil.
+func (profile *AlignmentProfile) PSSMFor(g Gene) PSSM {
+	return profile.GenePSSMs[g]
+}
+
+// asRaw returns the rows of the PSSM sorted by position
+func (pssm PSSM) asRaw() []rawPSSMRow {
+	result := make([]rawPSSMRow, 0, len(pssm))
+	for position, scores := range pssm {
+		result = append(result, rawPSSMRow{position, scores})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Position < result[j].Position
+	})
+	return result
+}
+
+// Check that the PSSM lies within the reference of refLen amino acids
+func validatePSSM(pssm PSSM, refLen int) error {
+	for position := range pssm {
+		if position < 1 || position > refLen {
+			return fmt.Errorf(
+				"scores at %d are not within the reference (1-%d)", position, refLen)
+		}
+	}
+	return nil
+}
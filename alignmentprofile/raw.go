@@ -9,34 +9,117 @@ package alignmentprofile
 
 import (
 	"fmt"
+	d "github.com/hivdb/nucamino/data"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"strconv"
+	"strings"
 )
 
 // This structure is a de-serialization target that the YAML package
-// uses to parse the GeneIndelScores in a serialized profile.
+// uses to parse the GeneIndelScores in a serialized profile. They are
+// written as [kind, positions, open, extend], where kind is ins, del or
+// indel (both), and positions is a position, a range of positions such
+// as 128-137, or '*' for the default of the gene.
 type rawIndelScore struct {
 	Kind     string
 	Position int
-	Open     int
-	Extend   int
+	// The last position of a range, or zero for a single position
+	End int
+	// Whether these are the default scores of the gene
+	IsDefault bool
+	Open      int
+	Extend    int
 }
 
 func (t *rawIndelScore) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	bucket := make([]interface{}, 4)
-	e := unmarshal(&bucket)
-	t.Kind = bucket[0].(string)
-	t.Position = bucket[1].(int)
-	t.Open = bucket[2].(int)
-	t.Extend = bucket[3].(int)
-	if e != nil {
-		return e
+	var bucket []interface{}
+	if err := unmarshal(&bucket); err != nil {
+		return err
+	}
+	var isKind, isOpen, isExtend bool
+	if len(bucket) == 4 {
+		t.Kind, isKind = bucket[0].(string)
+		t.Open, isOpen = bucket[2].(int)
+		t.Extend, isExtend = bucket[3].(int)
+	}
+	if !isKind || !isOpen || !isExtend {
+		return fmt.Errorf(
+			"Expecting indel scores as [kind, positions, open, extend] but got %v", bucket)
+	}
+	return t.parsePositions(bucket[1])
+}
+
+func (t *rawIndelScore) parsePositions(positions interface{}) error {
+	isValid := false
+	switch positions := positions.(type) {
+	case int:
+		t.Position = positions
+		isValid = positions >= 0
+	case string:
+		if positions == "*" {
+			t.IsDefault = true
+			return nil
+		}
+		bounds := strings.Split(positions, "-")
+		if len(bounds) != 2 {
+			break
+		}
+		start, startErr := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		end, endErr := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		t.Position = start
+		if end > start {
+			t.End = end
+		}
+		isValid = startErr == nil && endErr == nil && start >= 0 && end >= start
+	}
+	if !isValid {
+		return fmt.Errorf(
+			"Invalid indel positions '%v' (expecting a position, a range such as 128-137 or '*')",
+			positions)
 	}
 	return nil
 }
 
+// Positions formats the positions of the scores the way they're parsed
+func (t rawIndelScore) Positions() string {
+	if t.IsDefault {
+		return "'*'"
+	} else if t.End > t.Position {
+		return fmt.Sprintf("%d-%d", t.Position, t.End)
+	}
+	return strconv.Itoa(t.Position)
+}
+
+// lastPosition returns the last position the scores apply to
+func (t rawIndelScore) lastPosition() int {
+	if t.End > t.Position {
+		return t.End
+	}
+	return t.Position
+}
+
+// indelKindSigns returns the signs of the keys in PositionalIndelScores
+// of the kind of indel scores: positive for insertions and negative for
+// deletions.
+func indelKindSigns(kind string) ([]int, error) {
+	switch kind {
+	case "ins":
+		return []int{1}, nil
+	case "del":
+		return []int{-1}, nil
+	case "indel":
+		return []int{1, -1}, nil
+	}
+	msgFmt := "Unknown indel score kind '%v' (expecting 'ins', 'del' or 'indel')"
+	return nil, fmt.Errorf(msgFmt, kind)
+}
+
 // This type alias lets us implement the sorting interface for
-// []rawIndelScore. We sort it first by position (with lower positions
-// first) and then by kind (with insertions before deletions).
+// []rawIndelScore. We sort it first by position (with the defaults and
+// then lower positions first) and then by kind (with insertions before
+// deletions).
 type byPositionAndKind []rawIndelScore
 
 func (a byPositionAndKind) Len() int {
@@ -48,6 +131,10 @@ func (a byPositionAndKind) Swap(i, j int) {
 }
 
 func (a byPositionAndKind) Less(i, j int) bool {
+	if a[i].IsDefault != a[j].IsDefault {
+		// the defaults of the gene come first
+		return a[i].IsDefault
+	}
 	if a[i].Position < a[j].Position {
 		return true
 	} else if a[i].Position == a[j].Position {
@@ -68,8 +155,23 @@ type rawAlignmentProfile struct {
 	GapExtensionPenalty      int                        `yaml:"GapExtensionPenalty"`
 	IndelCodonOpeningBonus   int                        `yaml:"IndelCodonOpeningBonus"`
 	IndelCodonExtensionBonus int                        `yaml:"IndelCodonExtensionBonus"`
+	BandWidth                int                        `yaml:"BandWidth,omitempty"`
+	MinUnsequencedCodons     int                        `yaml:"MinUnsequencedCodons,omitempty"`
+	AlignmentMode            string                     `yaml:"AlignmentMode,omitempty"`
+	GeneAlignmentModes       map[string]string          `yaml:"GeneAlignmentModes,omitempty"`
+	AmbiguousCodonPolicy     string                     `yaml:"AmbiguousCodonPolicy,omitempty"`
+	SubstitutionMatrix       string                     `yaml:"SubstitutionMatrix,omitempty"`
+	GeneticCode              int                        `yaml:"GeneticCode,omitempty"`
 	RawIndelScores           map[string][]rawIndelScore `yaml:"PositionalIndelScores,flow"`
 	ReferenceSequences       map[string]string          `yaml:"ReferenceSequences"`
+	ReferenceCDS             map[string]string          `yaml:"ReferenceCDS,omitempty"`
+	RawRegions               map[string][]rawRegion     `yaml:"GeneRegions,omitempty"`
+	RawFrameShifts           map[string][]rawFrameShift `yaml:"ExpectedFrameShifts,omitempty"`
+	RawPrevalences           map[string][]rawPrevalence `yaml:"AminoAcidPrevalences,omitempty"`
+	RawPSSMs                 map[string][]rawPSSMRow    `yaml:"PositionSpecificScores,omitempty"`
+	// The directory which relative paths, such as the one of the
+	// substitution matrix, are resolved against
+	dir string
 }
 
 // Construct a GenePositionalIndelScores instance from a
@@ -79,23 +181,56 @@ func (rawProfile rawAlignmentProfile) geneIndelScores() (*GenePositionalIndelSco
 	for geneSrc, rawIndelScores := range rawProfile.RawIndelScores {
 		indelScores := make(PositionalIndelScores)
 		for _, indelScore := range rawIndelScores {
-			var scoreKeySign int
-			if indelScore.Kind == "ins" {
-				scoreKeySign = 1
-			} else if indelScore.Kind == "del" {
-				scoreKeySign = -1
-			} else {
-				msgFmt := "Unknown indel score kind '%v' (expecting 'ins' or 'del')"
-				return nil, fmt.Errorf(msgFmt, indelScore.Kind)
+			scoreKeySigns, err := indelKindSigns(indelScore.Kind)
+			if err != nil {
+				return nil, err
+			}
+			if indelScore.IsDefault {
+				continue
+			}
+			for pos := indelScore.Position; pos <= indelScore.lastPosition(); pos++ {
+				for _, scoreKeySign := range scoreKeySigns {
+					indelKey := scoreKeySign * pos
+					indelScores[indelKey] = [2]int{indelScore.Open, indelScore.Extend}
+				}
 			}
-			indelKey := scoreKeySign * indelScore.Position
-			indelScores[indelKey] = [2]int{indelScore.Open, indelScore.Extend}
 		}
 		geneIndelScores[Gene(geneSrc)] = indelScores
 	}
 	return &geneIndelScores, nil
 }
 
+// Collect the default indel scores of the genes, which are given at the
+// position '*'.
+func (rawProfile rawAlignmentProfile) geneIndelDefaults() (map[Gene]IndelScoreDefaults, error) {
+	var result map[Gene]IndelScoreDefaults
+	for geneSrc, rawIndelScores := range rawProfile.RawIndelScores {
+		for _, indelScore := range rawIndelScores {
+			if !indelScore.IsDefault {
+				continue
+			}
+			scoreKeySigns, err := indelKindSigns(indelScore.Kind)
+			if err != nil {
+				return nil, err
+			}
+			if result == nil {
+				result = make(map[Gene]IndelScoreDefaults)
+			}
+			defaults := result[Gene(geneSrc)]
+			for _, scoreKeySign := range scoreKeySigns {
+				scores := [2]int{indelScore.Open, indelScore.Extend}
+				if scoreKeySign > 0 {
+					defaults.Ins = &scores
+				} else {
+					defaults.Del = &scores
+				}
+			}
+			result[Gene(geneSrc)] = defaults
+		}
+	}
+	return result, nil
+}
+
 // Construct an AlignmentProfile from a rawAlignmentProfile
 func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 	var profile AlignmentProfile
@@ -105,6 +240,58 @@ func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 	profile.IndelCodonOpeningBonus = raw.IndelCodonOpeningBonus
 	profile.IndelCodonExtensionBonus = raw.IndelCodonExtensionBonus
 
+	if raw.BandWidth < 0 {
+		return nil, fmt.Errorf("BandWidth must not be negative (got %v)", raw.BandWidth)
+	}
+	profile.BandWidth = raw.BandWidth
+
+	if raw.MinUnsequencedCodons < 0 {
+		return nil, fmt.Errorf("MinUnsequencedCodons must not be negative (got %v)", raw.MinUnsequencedCodons)
+	}
+	profile.MinUnsequencedCodons = raw.MinUnsequencedCodons
+
+	if raw.AlignmentMode != "" {
+		mode := AlignmentMode(raw.AlignmentMode)
+		if err := mode.validate(); err != nil {
+			return nil, err
+		}
+		profile.AlignmentMode = mode
+	}
+	if len(raw.GeneAlignmentModes) > 0 {
+		profile.GeneAlignmentModes = make(map[Gene]AlignmentMode)
+		for geneSrc, modeSrc := range raw.GeneAlignmentModes {
+			mode := AlignmentMode(modeSrc)
+			if err := mode.validate(); err != nil {
+				return nil, err
+			}
+			profile.GeneAlignmentModes[Gene(geneSrc)] = mode
+		}
+	}
+
+	if raw.AmbiguousCodonPolicy != "" {
+		policy, err := ParseAmbiguousCodonPolicy(raw.AmbiguousCodonPolicy)
+		if err != nil {
+			return nil, err
+		}
+		profile.AmbiguousCodonPolicy = policy
+	}
+
+	if raw.SubstitutionMatrix != "" {
+		matrix, err := d.LoadSubstitutionMatrixIn(raw.dir, raw.SubstitutionMatrix)
+		if err != nil {
+			return nil, err
+		}
+		profile.SubstitutionMatrix = matrix
+	}
+
+	if raw.GeneticCode != 0 {
+		code, err := c.LoadGeneticCode(raw.GeneticCode)
+		if err != nil {
+			return nil, err
+		}
+		profile.GeneticCode = code
+	}
+
 	if len(raw.ReferenceSequences) == 0 {
 		return nil, fmt.Errorf("Missing key: ReferenceSequences")
 	} else {
@@ -114,6 +301,68 @@ func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 		}
 	}
 
+	if len(raw.ReferenceCDS) > 0 {
+		profile.ReferenceCDS = make(ReferenceNASeqs)
+		for geneSrc, naSrc := range raw.ReferenceCDS {
+			profile.ReferenceCDS[Gene(geneSrc)] = n.ReadString(naSrc)
+		}
+	}
+
+	if len(raw.RawRegions) > 0 {
+		profile.GeneRegions = make(map[Gene][]Region)
+		for geneSrc, rawRegions := range raw.RawRegions {
+			for _, region := range rawRegions {
+				profile.GeneRegions[Gene(geneSrc)] = append(
+					profile.GeneRegions[Gene(geneSrc)], Region(region))
+			}
+		}
+	}
+
+	if len(raw.RawFrameShifts) > 0 {
+		profile.GeneFrameShifts = make(map[Gene][]ExpectedFrameShift)
+		for geneSrc, rawFrameShifts := range raw.RawFrameShifts {
+			for _, frameShift := range rawFrameShifts {
+				profile.GeneFrameShifts[Gene(geneSrc)] = append(
+					profile.GeneFrameShifts[Gene(geneSrc)], ExpectedFrameShift(frameShift))
+			}
+		}
+	}
+
+	if len(raw.RawPrevalences) > 0 {
+		profile.GenePrevalences = make(map[Gene]AminoAcidPrevalences)
+		for geneSrc, rawPrevalences := range raw.RawPrevalences {
+			prevalences := make(AminoAcidPrevalences)
+			for _, prevalence := range rawPrevalences {
+				aas := a.ReadString(prevalence.AminoAcid)
+				if len(aas) != 1 || len(prevalence.AminoAcid) != 1 {
+					return nil, fmt.Errorf(
+						"Unknown amino acid '%v' in the AminoAcidPrevalences of %v",
+						prevalence.AminoAcid, geneSrc)
+				}
+				if prevalences[prevalence.Position] == nil {
+					prevalences[prevalence.Position] = make(map[a.AminoAcid]float64)
+				}
+				prevalences[prevalence.Position][aas[0]] = prevalence.Percent
+			}
+			profile.GenePrevalences[Gene(geneSrc)] = prevalences
+		}
+	}
+
+	if len(raw.RawPSSMs) > 0 {
+		profile.GenePSSMs = make(map[Gene]PSSM)
+		for geneSrc, rows := range raw.RawPSSMs {
+			pssm := make(PSSM)
+			for _, row := range rows {
+				if _, found := pssm[row.Position]; found {
+					return nil, fmt.Errorf(
+						"Duplicate PositionSpecificScores at %d of %v", row.Position, geneSrc)
+				}
+				pssm[row.Position] = row.Scores
+			}
+			profile.GenePSSMs[Gene(geneSrc)] = pssm
+		}
+	}
+
 	if raw.RawIndelScores == nil || len(raw.RawIndelScores) == 0 {
 		profile.GeneIndelScores = nil
 	} else {
@@ -122,6 +371,10 @@ func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 			return nil, err
 		}
 		profile.GeneIndelScores = *geneIndelScores
+		profile.GeneIndelDefaults, err = raw.geneIndelDefaults()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &profile, nil
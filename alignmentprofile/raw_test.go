@@ -24,6 +24,37 @@ func TestUnmarshalRawIndelScore(t *testing.T) {
 	}
 }
 
+func TestUnmarshalRawIndelScoreRanges(t *testing.T) {
+	cases := map[string]rawIndelScore{
+		"[del, 128-137, -2, -2]": {Kind: "del", Position: 128, End: 137, Open: -2, Extend: -2},
+		"[indel, 5-5, 1, 2]":     {Kind: "indel", Position: 5, Open: 1, Extend: 2},
+		"[ins, '*', 1, 2]":       {Kind: "ins", IsDefault: true, Open: 1, Extend: 2},
+	}
+	for src, expected := range cases {
+		var constructed rawIndelScore
+		if err := yaml.Unmarshal([]byte(src), &constructed); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(constructed, expected) {
+			t.Errorf("%+v != %+v", constructed, expected)
+		}
+	}
+	errCases := []string{
+		"[del, 137-128, -2, -2]",
+		"[del, 128-, -2, -2]",
+		"[del, -1, -2, -2]",
+		"[del, all, -2, -2]",
+		"[del, 128, -2]",
+		"[del, 128, -2, x]",
+	}
+	for _, src := range errCases {
+		var constructed rawIndelScore
+		if err := yaml.Unmarshal([]byte(src), &constructed); err == nil {
+			t.Errorf("Expected error on %v", src)
+		}
+	}
+}
+
 var exampleRawProfile = rawAlignmentProfile{
 	StopCodonPenalty:         0,
 	GapOpeningPenalty:        1,
@@ -68,6 +99,43 @@ func TestGeneIndelScoresFromRawProfile(t *testing.T) {
 	}
 }
 
+func TestGeneIndelScoresFromRawRanges(t *testing.T) {
+	raw := rawAlignmentProfile{
+		RawIndelScores: map[string][]rawIndelScore{
+			"A": []rawIndelScore{
+				{Kind: "indel", IsDefault: true, Open: 1, Extend: 1},
+				{Kind: "indel", Position: 2, End: 3, Open: 4, Extend: 5},
+				{Kind: "del", Position: 3, Open: 6, Extend: 7},
+			},
+		},
+	}
+	constructed, err := raw.geneIndelScores()
+	if err != nil {
+		t.Errorf("Unexpected Error: %v", err)
+	}
+	expected := &GenePositionalIndelScores{
+		Gene("A"): PositionalIndelScores{
+			2:  [2]int{4, 5},
+			-2: [2]int{4, 5},
+			3:  [2]int{4, 5},
+			-3: [2]int{6, 7},
+		},
+	}
+	if !reflect.DeepEqual(constructed, expected) {
+		t.Errorf("%+v != %+v", constructed, expected)
+	}
+	defaults, err := raw.geneIndelDefaults()
+	if err != nil {
+		t.Errorf("Unexpected Error: %v", err)
+	}
+	expectedDefaults := map[Gene]IndelScoreDefaults{
+		Gene("A"): {Ins: &[2]int{1, 1}, Del: &[2]int{1, 1}},
+	}
+	if !reflect.DeepEqual(defaults, expectedDefaults) {
+		t.Errorf("%+v != %+v", defaults, expectedDefaults)
+	}
+}
+
 var exampleInvalidRawProfile = rawAlignmentProfile{
 	RawIndelScores: map[string][]rawIndelScore{
 		"A": []rawIndelScore{
@@ -102,6 +170,10 @@ func TestRawIndelScoreSortOrder(t *testing.T) {
 			{Position: 1, Kind: "del"},
 			{Position: 2, Kind: "ins"},
 		},
+		{
+			{IsDefault: true, Kind: "del"},
+			{Position: 0, Kind: "ins"},
+		},
 	}
 	for _, c := range lessCases {
 		if !byPositionAndKind(c).Less(0, 1) {
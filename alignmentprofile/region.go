@@ -0,0 +1,75 @@
+package alignmentprofile
+
+import "fmt"
+
+// A Region is a named part of the reference of a gene, such as a
+// mature protein of a polyprotein. Start and End are the positions of
+// its first and last amino acids in the reference.
+type Region struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// This structure is a de-serialization target that the YAML package
+// uses to parse the GeneRegions in a serialized profile, which are
+// written as [name, start, end].
+type rawRegion struct {
+	Name  string
+	Start int
+	End   int
+}
+
+func (t *rawRegion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var bucket []interface{}
+	if err := unmarshal(&bucket); err != nil {
+		return err
+	}
+	if len(bucket) != 3 {
+		return fmt.Errorf("Expecting a region as [name, start, end] but got %v", bucket)
+	}
+	name, nameOk := bucket[0].(string)
+	start, startOk := bucket[1].(int)
+	end, endOk := bucket[2].(int)
+	if !nameOk || !startOk || !endOk {
+		return fmt.Errorf("Expecting a region as [name, start, end] but got %v", bucket)
+	}
+	t.Name, t.Start, t.End = name, start, end
+	return nil
+}
+
+// Retrieve the regions of a Gene, in the order of the reference. Genes
+// without regions return nil.
+func (profile *AlignmentProfile) RegionsFor(g Gene) []Region {
+	return profile.GeneRegions[g]
+}
+
+// Check that the regions are named, lie within the reference of
+// refLen amino acids, and are in order without overlapping. Regions
+// are reported in the columns of the genes, so they can't be named
+// after one of the genes.
+func validateRegions(regions []Region, refLen int, genes ReferenceSeqs) error {
+	names := make(map[string]bool)
+	prevEnd := 0
+	for _, region := range regions {
+		_, isGene := genes[Gene(region.Name)]
+		switch {
+		case region.Name == "":
+			return fmt.Errorf("region without name")
+		case names[region.Name]:
+			return fmt.Errorf("duplicate region %v", region.Name)
+		case isGene:
+			return fmt.Errorf("region %v has the name of a gene", region.Name)
+		case region.Start < 1 || region.End > refLen || region.Start > region.End:
+			return fmt.Errorf(
+				"region %v (%d-%d) is not within the reference (1-%d)",
+				region.Name, region.Start, region.End, refLen)
+		case region.Start <= prevEnd:
+			return fmt.Errorf(
+				"region %v overlaps the previous region or is out of order", region.Name)
+		}
+		names[region.Name] = true
+		prevEnd = region.End
+	}
+	return nil
+}
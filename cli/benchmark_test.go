@@ -0,0 +1,100 @@
+package cli
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// benchmarkPerformAlignment aligns 8 sequences to a gene as long as
+// POL with the given number of goroutines. Every position of the gene
+// is scored by a PSSM, so that its substitution tables take about
+// 28MB; they are built once and shared by the goroutines, so the bytes
+// allocated grow only by the workspace of each additional aligner.
+func benchmarkPerformAlignment(b *testing.B, goroutines int) {
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rnd := rand.New(rand.NewSource(1))
+	aSeq := make([]a.AminoAcid, 1000)
+	pssm := make(ap.PSSM)
+	for idx := range aSeq {
+		aSeq[idx] = a.AminoAcids[rnd.Intn(a.NumAminoAcids)]
+		var scores [a.NumAminoAcids]int
+		for _, aa := range a.AminoAcids {
+			scores[aa] = d.Blosum62.Lookup(aa, aSeq[idx])
+		}
+		pssm[idx+1] = scores
+	}
+	codonsOf := map[a.AminoAcid][]c.Codon{}
+	for codon, aa := range c.CodonToAminoAcidTable {
+		codonsOf[aa] = append(codonsOf[aa], codon)
+	}
+	for _, codons := range codonsOf {
+		// in a fixed order, so that the sequences are the same in
+		// every run
+		sort.Slice(codons, func(i, j int) bool {
+			return codons[i].ToString() < codons[j].ToString()
+		})
+	}
+	fasta := ""
+	for seqIdx := 0; seqIdx < 8; seqIdx++ {
+		nSeq := []n.NucleicAcid{}
+		for _, aa := range aSeq {
+			if rnd.Intn(20) == 0 {
+				// a random substitution
+				aa = a.AminoAcids[rnd.Intn(a.NumAminoAcids)]
+			}
+			codons := codonsOf[aa]
+			codon := codons[rnd.Intn(len(codons))]
+			nSeq = append(nSeq, codon.Base1, codon.Base2, codon.Base3)
+		}
+		fasta += ">seq" + strconv.Itoa(seqIdx) + "\n" + n.WriteString(nSeq) + "\n"
+	}
+	input := filepath.Join(dir, "input.fasta")
+	if err := ioutil.WriteFile(input, []byte(fasta), 0644); err != nil {
+		b.Fatal(err)
+	}
+	output := filepath.Join(dir, "output.tsv")
+	profile := ap.AlignmentProfile{
+		StopCodonPenalty:         4,
+		GapOpeningPenalty:        10,
+		GapExtensionPenalty:      2,
+		IndelCodonExtensionBonus: 2,
+		ReferenceSequences:       ap.ReferenceSeqs{"A": aSeq},
+		GenePSSMs:                map[ap.Gene]ap.PSSM{"A": pssm},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := PerformAlignment(
+			input, output, "tsv", []string{"A"}, goroutines, true,
+			AlignmentOptions{}, profile)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPerformAlignment1Goroutine(b *testing.B) {
+	benchmarkPerformAlignment(b, 1)
+}
+
+func BenchmarkPerformAlignment4Goroutines(b *testing.B) {
+	benchmarkPerformAlignment(b, 4)
+}
+
+func BenchmarkPerformAlignment8Goroutines(b *testing.B) {
+	benchmarkPerformAlignment(b, 8)
+}
@@ -2,24 +2,198 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/hivdb/nucamino/alignment"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	h "github.com/hivdb/nucamino/scorehandler/general"
 	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
 	"github.com/hivdb/nucamino/utils/fastareader"
 	"log"
 	"os"
 	"runtime"
 	"sync"
+	"time"
 )
 
 type AlignmentResult struct {
-	Name   string
-	Report *alignment.AlignmentReport
-	Error  string
-	Err    error
+	Name     string
+	HitIndex int `json:",omitempty"`
+	Report   *alignment.AlignmentReport
+	Sites    []SiteScores `json:"sites,omitempty"`
+	Error    string
+	Err      error
+}
+
+// SiteScores is the score breakdown of an aligned site, as dumped by the
+// "sites" section of the JSON output.
+type SiteScores struct {
+	PosAA             int
+	PosNA             int
+	LengthNA          int
+	SubstitutionScore float64
+	GapScore          float64
+	IndelBonus        float64
+	CumulativeScore   float64
+}
+
+func makeResult(
+	name string, hitIndex int, report *alignment.AlignmentReport,
+	options AlignmentOptions) AlignmentResult {
+	var sites []SiteScores
+	if options.Sites {
+		sites = siteScoresOf(report)
+	}
+	return AlignmentResult{name, hitIndex, report, sites, "", nil}
+}
+
+// errorResult is the result of a sequence which couldn't be aligned.
+func errorResult(name string, err error) []AlignmentResult {
+	return []AlignmentResult{{name, 0, nil, nil, err.Error(), err}}
+}
+
+// sequenceContext returns the context shared by all the alignments of
+// one sequence, which is done once the timeout elapsed. A zero timeout
+// means no limit.
+func sequenceContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// deadlineError reports the deadline of a sequence as a TimeoutError.
+func deadlineError(err error, timeout time.Duration) error {
+	if err == context.DeadlineExceeded {
+		return &alignment.TimeoutError{Timeout: timeout}
+	}
+	return err
+}
+
+func siteScoresOf(report *alignment.AlignmentReport) []SiteScores {
+	sites := make([]SiteScores, len(report.AlignedSites))
+	for idx, site := range report.AlignedSites {
+		sites[idx] = SiteScores{
+			PosAA:             site.PosAA,
+			PosNA:             site.PosNA,
+			LengthNA:          site.LengthNA,
+			SubstitutionScore: site.SubstitutionScore,
+			GapScore:          site.GapScore,
+			IndelBonus:        site.IndelBonus,
+			CumulativeScore:   site.CumulativeScore,
+		}
+	}
+	return sites
+}
+
+// AlignmentOptions holds the optional behaviors of PerformAlignment.
+// The zero value aligns the forward strand only and reports one hit
+// per gene.
+type AlignmentOptions struct {
+	// Also align the reverse complement and keep the better strand.
+	// Nucleotide positions still count from the start of the sequence,
+	// so that FirstNA is greater than LastNA for the reverse strand.
+	ReverseComplement bool
+	// Locate each gene with translated k-mer seeds and only align the
+	// window around them; sequences where the seeds don't cover enough
+	// of a gene are reported as errors of that gene
+	Seed bool
+	// Report up to MaxHits non-overlapping hits of each gene
+	MaxHits int
+	// Ignore the hits after the first one which score lower than this,
+	// in the units of the alignment profile
+	MinHitScore float64
+	// Report the E-value of each hit
+	EValue bool
+	// Turn hits with higher E-values into errors; implies EValue
+	MaxEValue float64
+	// Include the score breakdown of every aligned site in the JSON
+	// output
+	Sites bool
+	// Type of the input sequences: "nucleotide" (the default) or
+	// "protein"
+	InputType string
+	// Report the regions of genes which have regions in the profile,
+	// such as the mature proteins of a polyprotein, as if they were
+	// genes
+	SplitRegions bool
+	// Limits of the alignments of each sequence; sequences exceeding
+	// them are reported as errors. The matrix limit applies to each
+	// gene, while the timeout covers all the genes and hits of the
+	// sequence. Zero means no limit.
+	MaxMatrixCells int
+	Timeout        time.Duration
+	// Alignments whose matrix has more cells than this are traced back
+	// in linear memory; zero means alignment.DefaultMaxFullMatrixCells
+	MaxFullMatrixCells int
+	// Nucleotides of FASTQ reads with a lower Phred quality than this
+	// are aligned as N, and the mutations including them are flagged
+	// as IsLowQuality. Zero keeps every nucleotide.
+	MinQuality int
+}
+
+// Random sequences used to estimate the significance of alignments are
+// generated from this seed, so E-values are reproducible
+const scoreDistributionSeed = 1
+
+func (self AlignmentOptions) assessesSignificance() bool {
+	return self.EValue || self.MaxEValue > 0
+}
+
+// estimateScoreDistributions estimates the score distribution of every
+// gene concurrently.
+func estimateScoreDistributions(
+	genes []ap.Gene, refs [][]a.AminoAcid,
+	scoreHandlers []*h.GeneralScoreHandler) ([]*alignment.ScoreDistribution, error) {
+
+	var (
+		wg            = sync.WaitGroup{}
+		distributions = make([]*alignment.ScoreDistribution, len(genes))
+		errs          = make([]error, len(genes))
+	)
+	for i, gene := range genes {
+		wg.Add(1)
+		go func(i int, gene ap.Gene) {
+			distributions[i], errs[i] = alignment.EstimateScoreDistribution(
+				refs[i], scoreHandlers[i], scoreDistributionSeed)
+			wg.Done()
+		}(i, gene)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("Unable to estimate the score distribution of %v: %v", genes[i], err)
+		}
+	}
+	return distributions, nil
+}
+
+func validInputType(inputType string) bool {
+	validTypes := []string{"nucleotide", "protein"}
+	for _, validType := range validTypes {
+		if inputType == validType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProteinOptions checks that the options apply to protein input,
+// which is aligned on its only strand and gives one hit per gene.
+func checkProteinOptions(options AlignmentOptions) error {
+	switch {
+	case options.ReverseComplement:
+		return fmt.Errorf("Protein sequences have no reverse complement")
+	case options.Seed:
+		return fmt.Errorf("Protein sequences are aligned without seeds")
+	case options.MaxHits > 1:
+		return fmt.Errorf("Protein sequences are aligned with one hit per gene")
+	case options.assessesSignificance():
+		return fmt.Errorf("E-values are only available for nucleotide sequences")
+	}
+	return nil
 }
 
 func validOutputFormat(format string) bool {
@@ -34,17 +208,39 @@ func validOutputFormat(format string) bool {
 
 func writeTSV(
 	file *os.File, textGenes []string,
-	seqs []fastareader.Sequence, resultMap map[string][]AlignmentResult) {
+	seqs []fastareader.Sequence, resultMap map[string][][]AlignmentResult,
+	options AlignmentOptions, withCodonDifferences bool) {
 
-	genesCount := len(textGenes)
+	var (
+		genesCount     = len(textGenes)
+		withHitIndex   = options.MaxHits > 1
+		withEValue     = options.assessesSignificance()
+		withLowQuality = options.MinQuality > 0
+	)
 	file.WriteString("Sequence Name")
+	if withHitIndex {
+		file.WriteString("\tHit Index")
+	}
 	for _, textGene := range textGenes {
 		file.WriteString("\t" + textGene + " FirstAA")
 		file.WriteString("\t" + textGene + " LastAA")
 		file.WriteString("\t" + textGene + " FirstNA")
 		file.WriteString("\t" + textGene + " LastNA")
 		file.WriteString("\t" + textGene + " Mutations")
+		if withLowQuality {
+			file.WriteString("\t" + textGene + " LowQualityMutations")
+		}
 		file.WriteString("\t" + textGene + " FrameShifts")
+		if withCodonDifferences {
+			file.WriteString("\t" + textGene + " CodonDifferences")
+		}
+		file.WriteString("\t" + textGene + " Score")
+		file.WriteString("\t" + textGene + " NormalizedScore")
+		file.WriteString("\t" + textGene + " Identity")
+		file.WriteString("\t" + textGene + " Similarity")
+		if withEValue {
+			file.WriteString("\t" + textGene + " EValue")
+		}
 	}
 	file.WriteString("\n")
 	for _, seq := range seqs {
@@ -52,49 +248,102 @@ func writeTSV(
 		if result == nil {
 			continue
 		}
-		file.WriteString(seq.Name)
+		hitsCount := 1
 		for i := 0; i < genesCount; i++ {
-			err := result[i].Err
-			if err != nil {
-				file.WriteString("\tNA\tNA\tNA\tNA\tNA\tNA")
-				continue
+			if len(result[i]) > hitsCount {
+				hitsCount = len(result[i])
 			}
-			r := result[i].Report
-			file.WriteString(fmt.Sprintf(
-				"\t%d\t%d\t%d\t%d\t%s\t%s",
-				r.FirstAA, r.LastAA,
-				r.FirstNA, r.LastNA,
-				func() string {
+		}
+		// one row for each hit; genes with fewer hits are filled with NA
+		for hitIdx := 0; hitIdx < hitsCount; hitIdx++ {
+			file.WriteString(seq.Name)
+			if withHitIndex {
+				file.WriteString(fmt.Sprintf("\t%d", hitIdx+1))
+			}
+			for i := 0; i < genesCount; i++ {
+				if hitIdx >= len(result[i]) || result[i][hitIdx].Err != nil {
+					file.WriteString("\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA")
+					if withLowQuality {
+						file.WriteString("\tNA")
+					}
+					if withCodonDifferences {
+						file.WriteString("\tNA")
+					}
+					if withEValue {
+						file.WriteString("\tNA")
+					}
+					continue
+				}
+				r := result[i][hitIdx].Report
+				lowQualityMuts := ""
+				if withLowQuality {
 					var muts bytes.Buffer
 					for _, mut := range r.Mutations {
-						muts.WriteString(mut.ToString())
-						muts.WriteString(",")
+						if mut.IsLowQuality {
+							muts.WriteString(mut.ToString())
+							muts.WriteString(",")
+						}
 					}
 					if muts.Len() > 0 {
 						muts.Truncate(muts.Len() - 1)
 					}
-					return muts.String()
-				}(),
-				func() string {
-					var fss bytes.Buffer
-					for _, fs := range r.FrameShifts {
-						fss.WriteString(fs.ToString())
-						fss.WriteString(",")
+					lowQualityMuts = "\t" + muts.String()
+				}
+				codonDiffs := ""
+				if withCodonDifferences {
+					var diffs bytes.Buffer
+					for _, diff := range r.CodonDifferences {
+						diffs.WriteString(diff.ToString())
+						diffs.WriteString(",")
 					}
-					if fss.Len() > 0 {
-						fss.Truncate(fss.Len() - 1)
+					if diffs.Len() > 0 {
+						diffs.Truncate(diffs.Len() - 1)
 					}
-					return fss.String()
-				}(),
-			))
+					codonDiffs = "\t" + diffs.String()
+				}
+				file.WriteString(fmt.Sprintf(
+					"\t%d\t%d\t%d\t%d\t%s%s\t%s%s\t%.2f\t%.4f\t%.2f\t%.2f",
+					r.FirstAA, r.LastAA,
+					r.FirstNA, r.LastNA,
+					func() string {
+						var muts bytes.Buffer
+						for _, mut := range r.Mutations {
+							muts.WriteString(mut.ToString())
+							muts.WriteString(",")
+						}
+						if muts.Len() > 0 {
+							muts.Truncate(muts.Len() - 1)
+						}
+						return muts.String()
+					}(),
+					lowQualityMuts,
+					func() string {
+						var fss bytes.Buffer
+						for _, fs := range r.FrameShifts {
+							fss.WriteString(fs.ToString())
+							fss.WriteString(",")
+						}
+						if fss.Len() > 0 {
+							fss.Truncate(fss.Len() - 1)
+						}
+						return fss.String()
+					}(),
+					codonDiffs,
+					r.Score, r.NormalizedScore,
+					r.Identity, r.Similarity,
+				))
+				if withEValue {
+					file.WriteString(fmt.Sprintf("\t%.3g", r.EValue))
+				}
+			}
+			file.WriteString("\n")
 		}
-		file.WriteString("\n")
 	}
 }
 
 func writeJSON(
 	file *os.File, textGenes []string,
-	seqs []fastareader.Sequence, resultMap map[string][]AlignmentResult) {
+	seqs []fastareader.Sequence, resultMap map[string][][]AlignmentResult) {
 
 	finalResultMap := make(map[string][]AlignmentResult)
 	genesCount := len(textGenes)
@@ -104,8 +353,7 @@ func writeJSON(
 		for _, seq := range seqs {
 			seqResult := resultMap[seq.Name]
 			if seqResult != nil {
-				seqGeneResult := seqResult[i]
-				finalResultMap[textGene] = append(finalResultMap[textGene], seqGeneResult)
+				finalResultMap[textGene] = append(finalResultMap[textGene], seqResult[i]...)
 			}
 		}
 	}
@@ -134,6 +382,7 @@ func PerformAlignment(
 	textGenes []string,
 	goroutines int,
 	quiet bool,
+	options AlignmentOptions,
 	alignmentProfile ap.AlignmentProfile) error {
 
 	// Check output format
@@ -141,6 +390,30 @@ func PerformAlignment(
 		err := fmt.Errorf("Unknown output format %v. Options are: tsv, json", outputFormat)
 		return err
 	}
+	if options.Sites && outputFormat != "json" {
+		return fmt.Errorf("Site scores are only available in the json output format")
+	}
+	if options.InputType == "" {
+		options.InputType = "nucleotide"
+	}
+	if !validInputType(options.InputType) {
+		return fmt.Errorf("Unknown input type %v. Options are: nucleotide, protein", options.InputType)
+	}
+	isProtein := options.InputType == "protein"
+	if isProtein {
+		if err := checkProteinOptions(options); err != nil {
+			return err
+		}
+	}
+	if options.MaxMatrixCells < 0 || options.Timeout < 0 || options.MaxFullMatrixCells < 0 {
+		return fmt.Errorf("Limits of alignments must not be negative")
+	}
+	// the timeout bounds a whole sequence rather than each alignment,
+	// see sequenceContext
+	limits := alignment.Limits{
+		MaxMatrixCells:     options.MaxMatrixCells,
+		MaxFullMatrixCells: options.MaxFullMatrixCells,
+	}
 
 	// Configure runtime
 	runtime.LockOSThread()
@@ -176,45 +449,142 @@ func PerformAlignment(
 		}
 	}
 
+	if !options.SplitRegions {
+		// the alignments have no need for the regions
+		alignmentProfile.GeneRegions = nil
+	}
 	genesCount := len(textGenes)
 	genes := make([]ap.Gene, genesCount)
 	refs := make([][]a.AminoAcid, genesCount)
+	// the handlers don't change once built, so that all the goroutines
+	// share them
+	scoreHandlers := make([]*h.GeneralScoreHandler, genesCount)
+	geneRegions := make([][]ap.Region, genesCount)
+	withCodonDifferences := false
 	for i, textGene := range textGenes {
 		genes[i] = ap.Gene(textGene)
 		refs[i] = alignmentProfile.ReferenceSequences[genes[i]]
+		scoreHandlers[i] = h.New(genes[i], alignmentProfile)
+		geneRegions[i] = alignmentProfile.RegionsFor(genes[i])
+		if len(alignmentProfile.ReferenceCDS[genes[i]]) > 0 && !isProtein {
+			withCodonDifferences = true
+		}
 	}
 
 	var (
 		wg         = sync.WaitGroup{}
-		seqs       = fastareader.ReadSequences(input)
-		resultChan = make(chan []AlignmentResult)
-		resultMap  = make(map[string][]AlignmentResult)
+		seqs       []fastareader.Sequence
+		resultChan = make(chan [][]AlignmentResult)
+		resultMap  = make(map[string][][]AlignmentResult)
 	)
+	if isProtein {
+		seqs, err = fastareader.ReadProteinSequences(input)
+		if err != nil {
+			return err
+		}
+	} else {
+		seqs, err = fastareader.ReadNucleicAcidSequences(input)
+		if err != nil {
+			return err
+		}
+	}
+	if options.MaxHits < 1 {
+		options.MaxHits = 1
+	}
 	if !quiet {
 		logger.Printf("%d sequences were found from the input file.\n", len(seqs))
 	}
 
+	var distributions []*alignment.ScoreDistribution
+	if options.assessesSignificance() {
+		if !quiet {
+			logger.Printf("Estimating score distributions of random sequences.\n")
+		}
+		distributions, err = estimateScoreDistributions(genes, refs, scoreHandlers)
+		if err != nil {
+			return err
+		}
+	}
+	searchedLen := func(nSeq []n.NucleicAcid) int {
+		if options.ReverseComplement {
+			return 2 * len(nSeq)
+		}
+		return len(nSeq)
+	}
+
 	var seqChan = seqSlice2Chan(seqs, goroutines*4)
 	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
-		go func(idx int, rChan chan<- []AlignmentResult) {
-			scoreHandlers := make([]*h.GeneralScoreHandler, genesCount)
-			for i, gene := range genes {
-				scoreHandlers[i] = h.New(gene, alignmentProfile)
+		go func(idx int, rChan chan<- [][]AlignmentResult) {
+			aligners := make([]*alignment.Aligner, genesCount)
+			for i := range genes {
+				aligners[i] = alignment.NewAligner(refs[i], scoreHandlers[i])
+				aligners[i].SetLimits(limits)
+			}
+			// alignGene aligns the sequence against the i-th gene.
+			alignGene := func(ctx context.Context, i int, seq fastareader.Sequence, lowQuality []bool) (result []AlignmentResult, isSimpleAlignment bool) {
+				if isProtein {
+					aligned, err := alignment.NewProteinAlignmentContext(
+						ctx, seq.AminoAcids, refs[i], scoreHandlers[i], limits)
+					if err != nil {
+						return errorResult(seq.Name, deadlineError(err, options.Timeout)), false
+					}
+					return []AlignmentResult{makeResult(seq.Name, 0, aligned.GetReport(), options)}, false
+				}
+				align := func(nSeq []n.NucleicAcid) (*alignment.Alignment, error) {
+					return aligners[i].NewAlignmentContext(ctx, nSeq)
+				}
+				if options.Seed {
+					align = alignment.SeededAlignFunc(
+						refs[i], scoreHandlers[i].GetGeneticCode(), align)
+				}
+				if options.ReverseComplement {
+					align = alignment.BothStrandsAlignFunc(align)
+				}
+				hits, err := alignment.AlignHits(
+					seq.Sequence, align, options.MaxHits, options.MinHitScore)
+				if err != nil {
+					return errorResult(seq.Name, deadlineError(err, options.Timeout)), true
+				}
+				if distributions != nil {
+					// hits are sorted by score, so the hits after an
+					// insignificant one are insignificant as well
+					for hitIdx, hit := range hits {
+						err = distributions[i].Assess(
+							hit, searchedLen(seq.Sequence), options.MaxEValue)
+						if err != nil {
+							hits = hits[:hitIdx]
+							break
+						}
+					}
+					if len(hits) == 0 {
+						return errorResult(seq.Name, err), true
+					}
+				}
+				isSimpleAlignment = true
+				for hitIdx, hit := range hits {
+					r := hit.GetReport()
+					r.FlagLowQuality(lowQuality)
+					hitIndex := 0
+					if options.MaxHits > 1 {
+						hitIndex = hitIdx + 1
+					}
+					result = append(result, makeResult(seq.Name, hitIndex, r, options))
+					isSimpleAlignment = isSimpleAlignment && r.IsSimpleAlignment
+				}
+				return result, isSimpleAlignment
 			}
 			for seq := range seqChan {
 				isSimpleAlignment := true
-				result := make([]AlignmentResult, genesCount)
+				result := make([][]AlignmentResult, genesCount)
+				seq, lowQuality := seq.MaskLowQuality(options.MinQuality)
+				ctx, cancel := sequenceContext(options.Timeout)
 				for i := 0; i < genesCount; i++ {
-					aligned, err := alignment.NewAlignment(seq.Sequence, refs[i], scoreHandlers[i])
-					if err != nil {
-						result[i] = AlignmentResult{seq.Name, nil, err.Error(), err}
-					} else {
-						r := aligned.GetReport()
-						result[i] = AlignmentResult{seq.Name, r, "", nil}
-						isSimpleAlignment = isSimpleAlignment && r.IsSimpleAlignment
-					}
+					var isSimple bool
+					result[i], isSimple = alignGene(ctx, i, seq, lowQuality)
+					isSimpleAlignment = isSimpleAlignment && isSimple
 				}
+				cancel()
 				rChan <- result
 				if !quiet {
 					if isSimpleAlignment {
@@ -227,7 +597,7 @@ func PerformAlignment(
 			wg.Done()
 		}(i, resultChan)
 	}
-	go func(rChan chan<- []AlignmentResult) {
+	go func(rChan chan<- [][]AlignmentResult) {
 		wg.Wait()
 		if !quiet {
 			logger.Printf("\n")
@@ -235,14 +605,22 @@ func PerformAlignment(
 		close(rChan)
 	}(resultChan)
 	for result := range resultChan {
-		resultMap[result[0].Name] = result
+		resultMap[result[0][0].Name] = result
+	}
+	outputGenes := textGenes
+	if options.SplitRegions {
+		columns := outputColumns(textGenes, geneRegions)
+		for name, result := range resultMap {
+			resultMap[name] = splitResults(columns, result, options)
+		}
+		outputGenes = columnNames(columns)
 	}
 	switch outputFormat {
 	case "tsv":
-		writeTSV(output, textGenes, seqs, resultMap)
+		writeTSV(output, outputGenes, seqs, resultMap, options, withCodonDifferences)
 		break
 	case "json":
-		writeJSON(output, textGenes, seqs, resultMap)
+		writeJSON(output, outputGenes, seqs, resultMap)
 		break
 	}
 	if !quiet && outputFileName != "-" {
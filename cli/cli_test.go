@@ -1,6 +1,23 @@
 package cli
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestValidOutputFormat(t *testing.T) {
 	okCases := []string{"json", "tsv"}
@@ -16,3 +33,300 @@ func TestValidOutputFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestValidInputType(t *testing.T) {
+	for _, c := range []string{"nucleotide", "protein"} {
+		if !validInputType(c) {
+			t.Errorf("Expected %v to be a valid input type", c)
+		}
+	}
+	for _, c := range []string{"", "dna", "aa"} {
+		if validInputType(c) {
+			t.Errorf("Expected %v to not be a valid input type", c)
+		}
+	}
+}
+
+func TestCheckProteinOptions(t *testing.T) {
+	if err := checkProteinOptions(AlignmentOptions{MaxHits: 1, Sites: true}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	errCases := []AlignmentOptions{
+		{ReverseComplement: true},
+		{Seed: true},
+		{MaxHits: 2},
+		{EValue: true},
+		{MaxEValue: 1e-5},
+	}
+	for _, c := range errCases {
+		if checkProteinOptions(c) == nil {
+			t.Errorf("Expected %+v to not apply to protein input", c)
+		}
+	}
+}
+
+func TestWriteTSVWithHitIndex(t *testing.T) {
+	file, err := ioutil.TempFile("", "nucamino-tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	seqs := []fastareader.Sequence{{Name: "seq1"}}
+	report := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 2, FirstNA: 3, LastNA: 8,
+		Score: 12, NormalizedScore: 6, Identity: 100, Similarity: 100,
+	}
+	resultMap := map[string][][]AlignmentResult{
+		"seq1": {
+			{{"seq1", 1, report, nil, "", nil}, {"seq1", 2, report, nil, "", nil}},
+			{{"seq1", 1, report, nil, "", nil}},
+		},
+	}
+	writeTSV(file, []string{"A", "B"}, seqs, resultMap, AlignmentOptions{MaxHits: 2}, false)
+	file.Close()
+	written, _ := ioutil.ReadFile(file.Name())
+	lines := strings.Split(string(written), "\n")
+	expected := []string{
+		"seq1\t1\t1\t2\t3\t8\t\t\t12.00\t6.0000\t100.00\t100.00" +
+			"\t1\t2\t3\t8\t\t\t12.00\t6.0000\t100.00\t100.00",
+		"seq1\t2\t1\t2\t3\t8\t\t\t12.00\t6.0000\t100.00\t100.00" +
+			"\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA",
+	}
+	if !strings.HasPrefix(lines[0], "Sequence Name\tHit Index\tA FirstAA") {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	for idx, line := range expected {
+		if lines[idx+1] != line {
+			t.Errorf("Expected %q but received %q", line, lines[idx+1])
+		}
+	}
+}
+
+func TestWriteTSVWithCodonDifferences(t *testing.T) {
+	file, err := ioutil.TempFile("", "nucamino-tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	seqs := []fastareader.Sequence{{Name: "seq1"}, {Name: "seq2"}}
+	report := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 2, FirstNA: 1, LastNA: 6,
+		CodonDifferences: []m.CodonDifference{
+			{1, 1, "AAG", "AAA", "K", "K", true, []int{3}},
+			{2, 4, "AAC", "AAA", "N", "K", false, []int{3}},
+		},
+	}
+	err = errors.New("sequence misaligned")
+	resultMap := map[string][][]AlignmentResult{
+		"seq1": {{{"seq1", 0, report, nil, "", nil}}},
+		"seq2": {{{"seq2", 0, nil, nil, err.Error(), err}}},
+	}
+	writeTSV(file, []string{"A"}, seqs, resultMap, AlignmentOptions{}, true)
+	file.Close()
+	written, _ := ioutil.ReadFile(file.Name())
+	lines := strings.Split(string(written), "\n")
+	expected := []string{
+		"Sequence Name\tA FirstAA\tA LastAA\tA FirstNA\tA LastNA\tA Mutations" +
+			"\tA FrameShifts\tA CodonDifferences\tA Score\tA NormalizedScore" +
+			"\tA Identity\tA Similarity",
+		"seq1\t1\t2\t1\t6\t\t\tK1K:AAA>AAG,K2N:AAA>AAC\t0.00\t0.0000\t0.00\t0.00",
+		"seq2\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA\tNA",
+	}
+	for idx, line := range expected {
+		if lines[idx] != line {
+			t.Errorf("Expected %q but received %q", line, lines[idx])
+		}
+	}
+}
+
+func TestWriteTSVWithLowQuality(t *testing.T) {
+	file, err := ioutil.TempFile("", "nucamino-tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	seqs := []fastareader.Sequence{{Name: "seq1"}}
+	lowQuality := *m.New(1, 1, c.Codon{n.A, n.A, n.N}, a.N, false, ":::")
+	lowQuality.IsLowQuality = true
+	report := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 2, FirstNA: 1, LastNA: 6,
+		Mutations: []m.Mutation{
+			lowQuality,
+			*m.New(2, 4, c.Codon{n.A, n.A, n.A}, a.N, false, ":::"),
+		},
+	}
+	resultMap := map[string][][]AlignmentResult{
+		"seq1": {{{"seq1", 0, report, nil, "", nil}}},
+	}
+	writeTSV(file, []string{"A"}, seqs, resultMap, AlignmentOptions{MinQuality: 20}, false)
+	file.Close()
+	written, _ := ioutil.ReadFile(file.Name())
+	lines := strings.Split(string(written), "\n")
+	expected := []string{
+		"Sequence Name\tA FirstAA\tA LastAA\tA FirstNA\tA LastNA\tA Mutations" +
+			"\tA LowQualityMutations\tA FrameShifts\tA Score\tA NormalizedScore" +
+			"\tA Identity\tA Similarity",
+		"seq1\t1\t2\t1\t6\tN1KN:AAN,N2K:AAA\tN1KN:AAN\t\t0.00\t0.0000\t0.00\t0.00",
+	}
+	for idx, line := range expected {
+		if lines[idx] != line {
+			t.Errorf("Expected %q but received %q", line, lines[idx])
+		}
+	}
+}
+
+func TestPerformAlignmentReverseComplement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the reverse complement of a sequence coding TVLVGPTPVNIIGRNLLTQ,
+	// with the mixtures R and Y
+	nSeq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
+	input := filepath.Join(dir, "input.fasta")
+	fasta := ">rev\n" + n.WriteString(n.ReverseComplement(nSeq)) + "\n"
+	if err := ioutil.WriteFile(input, []byte(fasta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output.tsv")
+	profile := ap.AlignmentProfile{
+		StopCodonPenalty:         4,
+		GapOpeningPenalty:        10,
+		GapExtensionPenalty:      2,
+		IndelCodonExtensionBonus: 2,
+		ReferenceSequences: ap.ReferenceSeqs{
+			"A": a.ReadString("TVLVGPTPVNIIGRNLLTQ"),
+		},
+	}
+	options := AlignmentOptions{ReverseComplement: true}
+	err = PerformAlignment(input, output, "tsv", []string{"A"}, 1, true, options, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, _ := ioutil.ReadFile(output)
+	lines := strings.Split(string(written), "\n")
+	// the positions count from the start of the input, so that FirstNA
+	// is greater than LastNA
+	expect := "rev\t1\t19\t57\t1\tV9A:GCC\t\t"
+	if !strings.HasPrefix(lines[1], expect) {
+		t.Errorf("Expected %q but received %q", expect, lines[1])
+	}
+	err = PerformAlignment(input, output, "json", []string{"A"}, 1, true, options, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, _ = ioutil.ReadFile(output)
+	var results map[string][]AlignmentResult
+	if err := json.Unmarshal(written, &results); err != nil {
+		t.Fatal(err)
+	}
+	report := results["A"][0].Report
+	if !report.IsReverseComplement {
+		t.Errorf("Expected %v but received %v", true, report.IsReverseComplement)
+	}
+	// the codon GCC of V9A is at 25-27 of the reverse complement, which
+	// are 33-31 of the input; the mutation points at 33
+	if pos := report.Mutations[0].NAPosition; pos != 33 {
+		t.Errorf("Expected %v but received %v", 33, pos)
+	}
+}
+
+func TestWriteJSONWithSites(t *testing.T) {
+	file, err := ioutil.TempFile("", "nucamino-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	seqs := []fastareader.Sequence{{Name: "seq1"}, {Name: "seq2"}}
+	report := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 1, FirstNA: 1, LastNA: 3,
+		AlignedSites: []alignment.AlignedSite{{1, 1, 3, 5, 0, 0, 5}},
+	}
+	resultMap := map[string][][]AlignmentResult{
+		"seq1": {{{"seq1", 0, report, siteScoresOf(report), "", nil}}},
+		"seq2": {{{"seq2", 0, report, nil, "", nil}}},
+	}
+	writeJSON(file, []string{"A"}, seqs, resultMap)
+	file.Close()
+	written, _ := ioutil.ReadFile(file.Name())
+	if count := strings.Count(string(written), `"sites"`); count != 1 {
+		t.Errorf("Expected one sites section but received %d in %s", count, written)
+	}
+	if !strings.Contains(string(written), `"SubstitutionScore": 5`) {
+		t.Errorf("Expected the substitution score in %s", written)
+	}
+}
+
+func TestSiteScoresOf(t *testing.T) {
+	report := &alignment.AlignmentReport{
+		AlignedSites: []alignment.AlignedSite{{
+			PosAA: 1, PosNA: 2, LengthNA: 3, SubstitutionScore: 4,
+			GapScore: 5, IndelBonus: 6, CumulativeScore: 7,
+		}},
+	}
+	expect := []SiteScores{{
+		PosAA: 1, PosNA: 2, LengthNA: 3, SubstitutionScore: 4,
+		GapScore: 5, IndelBonus: 6, CumulativeScore: 7,
+	}}
+	if sites := siteScoresOf(report); !reflect.DeepEqual(sites, expect) {
+		t.Errorf("Expected %#v but received %#v", expect, sites)
+	}
+}
+
+func TestSequenceContext(t *testing.T) {
+	ctx, cancel := sequenceContext(0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Expected no deadline without a timeout")
+	}
+	cancel()
+	ctx, cancel = sequenceContext(time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	// every alignment of the sequence shares the deadline, so that the
+	// ones after it are reported as timed out as well
+	expect := &alignment.TimeoutError{Timeout: time.Nanosecond}
+	for i := 0; i < 2; i++ {
+		err := deadlineError(ctx.Err(), time.Nanosecond)
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf("Expected %#v but received %#v", expect, err)
+		}
+	}
+	other := errors.New("other")
+	if err := deadlineError(other, time.Nanosecond); err != other {
+		t.Errorf("Expected %#v but received %#v", other, err)
+	}
+}
+
+func TestSplitResults(t *testing.T) {
+	regions := [][]ap.Region{nil, {{"B1", 1, 10}, {"B2", 11, 20}}}
+	columns := outputColumns([]string{"A", "B"}, regions)
+	if names := columnNames(columns); !reflect.DeepEqual(names, []string{"A", "B1", "B2"}) {
+		t.Errorf("Unexpected columns %v", names)
+	}
+	reportA := &alignment.AlignmentReport{FirstAA: 1, LastAA: 5}
+	reportB := &alignment.AlignmentReport{FirstAA: 3, LastAA: 8, Regions: []alignment.RegionReport{
+		{"B1", alignment.AlignmentReport{FirstAA: 3, LastAA: 8}},
+	}}
+	err := errors.New("sequence misaligned")
+	result := [][]AlignmentResult{
+		{{"seq1", 0, reportA, nil, "", nil}},
+		{{"seq1", 1, reportB, nil, "", nil}, {"seq1", 2, nil, nil, err.Error(), err}},
+	}
+	splitted := splitResults(columns, result, AlignmentOptions{})
+	if len(splitted) != 3 || len(splitted[1]) != 2 || len(splitted[2]) != 2 {
+		t.Fatalf("Unexpected results %v", splitted)
+	}
+	if splitted[0][0].Report != reportA {
+		t.Errorf("Expected the results of A to be kept")
+	}
+	if r := splitted[1][0]; r.Report != &reportB.Regions[0].AlignmentReport || r.HitIndex != 1 {
+		t.Errorf("Expected the report of B1 but received %v", r)
+	}
+	if r := splitted[2][0]; r.Err == nil || r.Error != "B2 is not covered by the alignment" || r.HitIndex != 1 {
+		t.Errorf("Expected B2 not to be covered but received %v", r)
+	}
+	if r := splitted[1][1]; r.Err != err {
+		t.Errorf("Expected the error of the hit but received %v", r)
+	}
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+)
+
+// An outputColumn is a gene of the output. With SplitRegions, genes
+// which have regions are replaced by their regions.
+type outputColumn struct {
+	name string
+	// index of the aligned gene
+	gene int
+	// region of the gene, or empty for the whole gene
+	region string
+}
+
+func outputColumns(textGenes []string, geneRegions [][]ap.Region) []outputColumn {
+	var columns []outputColumn
+	for i, textGene := range textGenes {
+		if len(geneRegions[i]) == 0 {
+			columns = append(columns, outputColumn{textGene, i, ""})
+			continue
+		}
+		for _, region := range geneRegions[i] {
+			columns = append(columns, outputColumn{region.Name, i, region.Name})
+		}
+	}
+	return columns
+}
+
+func columnNames(columns []outputColumn) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// splitResults turns the results of the genes of a sequence into the
+// results of the columns. A hit which doesn't cover a region is
+// reported as an error of that region.
+func splitResults(
+	columns []outputColumn, result [][]AlignmentResult,
+	options AlignmentOptions) [][]AlignmentResult {
+	splitted := make([][]AlignmentResult, len(columns))
+	for i, column := range columns {
+		if column.region == "" {
+			splitted[i] = result[column.gene]
+			continue
+		}
+		for _, hit := range result[column.gene] {
+			if hit.Err != nil {
+				splitted[i] = append(splitted[i], hit)
+				continue
+			}
+			regionResult := errorResult(
+				hit.Name, fmt.Errorf("%v is not covered by the alignment", column.region))[0]
+			regionResult.HitIndex = hit.HitIndex
+			for idx := range hit.Report.Regions {
+				region := &hit.Report.Regions[idx]
+				if region.Name == column.region {
+					regionResult = makeResult(hit.Name, hit.HitIndex, &region.AlignmentReport, options)
+					break
+				}
+			}
+			splitted[i] = append(splitted[i], regionResult)
+		}
+	}
+	return splitted
+}
@@ -2,19 +2,24 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/hivdb/nucamino/alignment"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	"github.com/hivdb/nucamino/alignmentprofile/builtin"
 	"github.com/hivdb/nucamino/cli"
+	d "github.com/hivdb/nucamino/data"
 	"github.com/pkg/profile"
 	"github.com/spf13/cobra"
 	"strings"
+	"time"
 )
 
 // The cobra cli library will populate these variables with values
 // provided as command line flags.
-var alignInputFilename, alignOutputFilename, alignOutputFormat string
-var alignQuiet, alignPprof bool
-var alignGoroutines int
+var alignInputFilename, alignInputType, alignOutputFilename, alignOutputFormat, alignAmbiguousCodons, alignSubstitutionMatrix string
+var alignQuiet, alignPprof, alignReverseComplement, alignSeed, alignEValue, alignSites, alignSplitRegions bool
+var alignGoroutines, alignBandWidth, alignMaxHits, alignMaxMatrixCells, alignMaxFullMatrixCells, alignMinQuality int
+var alignMinHitScore, alignMaxEValue float64
+var alignTimeout time.Duration
 
 func init() {
 	rootCmd.AddCommand(alignCmd)
@@ -60,6 +65,105 @@ func init() {
 		0,
 		"number of goroutines the aligner will use. (default: number of CPUs)",
 	)
+	alignCmd.Flags().BoolVarP(
+		&alignReverseComplement,
+		"reverse-complement",
+		"r",
+		false,
+		"also align the reverse complement of each sequence and keep the better-scoring strand; FirstNA is greater than LastNA for the reverse strand",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignSeed,
+		"seed",
+		false,
+		"locate each gene in long sequences, such as assembled genomes, with translated k-mer seeds and only align the window around them",
+	)
+	alignCmd.Flags().IntVar(
+		&alignBandWidth,
+		"band-width",
+		-1,
+		"band width in nucleotides for banded alignment; 0 disables banding and -1 uses the profile's BandWidth",
+	)
+	alignCmd.Flags().IntVar(
+		&alignMaxHits,
+		"max-hits",
+		1,
+		"report up to this many non-overlapping hits of each gene",
+	)
+	alignCmd.Flags().Float64Var(
+		&alignMinHitScore,
+		"min-hit-score",
+		0,
+		"ignore additional hits scoring lower than this, in the units of the profile; by default only the hits which don't score at all are ignored",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignEValue,
+		"evalue",
+		false,
+		"estimate and report the E-value of each alignment",
+	)
+	alignCmd.Flags().Float64Var(
+		&alignMaxEValue,
+		"max-evalue",
+		0,
+		"report alignments with higher E-values as errors; implies --evalue. (default: no cutoff)",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignSites,
+		"sites",
+		false,
+		"include the score breakdown of every aligned site; json output only",
+	)
+	alignCmd.Flags().StringVar(
+		&alignInputType,
+		"input-type",
+		"nucleotide",
+		"type of the input sequences. (options: \"nucleotide\", \"protein\")",
+	)
+	alignCmd.Flags().IntVar(
+		&alignMaxMatrixCells,
+		"max-matrix-cells",
+		0,
+		"report sequences whose alignment matrix against a gene would exceed this many cells as errors; 0 means no limit",
+	)
+	alignCmd.Flags().IntVar(
+		&alignMaxFullMatrixCells,
+		"max-full-matrix-cells",
+		alignment.DefaultMaxFullMatrixCells,
+		"trace back alignments whose matrix against a gene exceeds this many cells in linear memory, which is slower",
+	)
+	alignCmd.Flags().DurationVar(
+		&alignTimeout,
+		"timeout",
+		0,
+		"report sequences whose alignments against all the genes take longer than this (e.g. 30s) as errors; 0 means no limit",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignSplitRegions,
+		"split-regions",
+		false,
+		"report the regions of genes declared by the profile, such as the mature proteins of a polyprotein, as if they were genes",
+	)
+	alignCmd.Flags().StringVar(
+		&alignAmbiguousCodons,
+		"ambiguous-codons",
+		"",
+		"how codons with ambiguous nucleotides are scored. (options: \"average\", \"best\", \"worst\", \"weighted\"; default: the profile's AmbiguousCodonPolicy)",
+	)
+	alignCmd.Flags().StringVar(
+		&alignSubstitutionMatrix,
+		"substitution-matrix",
+		"",
+		fmt.Sprintf(
+			"name of a built-in substitution matrix (%v) or path to a matrix file in the NCBI format. (default: the profile's SubstitutionMatrix)",
+			strings.Join(d.BuiltinSubstitutionMatrixNames(), ", ")),
+	)
+	alignCmd.Flags().IntVar(
+		&alignMinQuality,
+		"min-quality",
+		0,
+		"align the nucleotides of FASTQ reads with a lower Phred quality than this as N, and flag the mutations including them (as the LowQualityMutations column of the TSV output)",
+	)
 }
 
 // Check that a gene-name is in a list of GEnes
@@ -108,6 +212,23 @@ func alignRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if alignBandWidth >= 0 {
+		profile.BandWidth = alignBandWidth
+	}
+	if alignAmbiguousCodons != "" {
+		policy, err := ap.ParseAmbiguousCodonPolicy(alignAmbiguousCodons)
+		if err != nil {
+			return err
+		}
+		profile.AmbiguousCodonPolicy = policy
+	}
+	if alignSubstitutionMatrix != "" {
+		matrix, err := d.LoadSubstitutionMatrix(alignSubstitutionMatrix)
+		if err != nil {
+			return err
+		}
+		profile.SubstitutionMatrix = matrix
+	}
 	return cli.PerformAlignment(
 		alignInputFilename,
 		alignOutputFilename,
@@ -115,16 +236,31 @@ func alignRun(cmd *cobra.Command, args []string) error {
 		genes,
 		alignGoroutines,
 		alignQuiet,
+		cli.AlignmentOptions{
+			ReverseComplement:  alignReverseComplement,
+			Seed:               alignSeed,
+			MaxHits:            alignMaxHits,
+			MinHitScore:        alignMinHitScore,
+			EValue:             alignEValue,
+			MaxEValue:          alignMaxEValue,
+			Sites:              alignSites,
+			InputType:          alignInputType,
+			MaxMatrixCells:     alignMaxMatrixCells,
+			Timeout:            alignTimeout,
+			MaxFullMatrixCells: alignMaxFullMatrixCells,
+			SplitRegions:       alignSplitRegions,
+			MinQuality:         alignMinQuality,
+		},
 		*profile,
 	)
 }
 
 var alignLongMsg = `
-Loads nucleotide sequences from a FASTA file and aligns them using a
-built-in profile. The first argument is the name of the built-in
-profile to use for the alignment. The second argument is a comma
-separated list of genes to align against. (This list should either be
-surrounded by quote marks or contain no spaces).
+Loads nucleotide sequences from a FASTA or FASTQ file and aligns them
+using a built-in profile. The first argument is the name of the
+built-in profile to use for the alignment. The second argument is a
+comma separated list of genes to align against. (This list should
+either be surrounded by quote marks or contain no spaces).
 
 Examples:
 
@@ -138,7 +274,7 @@ Use 'nucamino align-with' to use a custom alignment profile.`
 
 var alignCmd = &cobra.Command{
 	Use:   "align <profile name> <genes> [flags]",
-	Short: "align sequences in a FASTA file using a built-in alignment profile",
+	Short: "align sequences in a FASTA or FASTQ file using a built-in alignment profile",
 	Long:  alignLongMsg,
 	Args:  cobra.ExactArgs(2),
 	RunE:  alignRun,
@@ -2,19 +2,23 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/hivdb/nucamino/alignment"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	"github.com/hivdb/nucamino/cli"
+	d "github.com/hivdb/nucamino/data"
 	"github.com/pkg/profile"
 	"github.com/spf13/cobra"
-	"io/ioutil"
 	"strings"
+	"time"
 )
 
 // The cobra cli library will populate these variables with values
 // provided as command line flags.
-var alignWithInputFilename, alignWithOutputFilename, alignWithOutputFormat string
-var alignWithQuiet, alignWithPprof bool
-var alignWithGoroutines int
+var alignWithInputFilename, alignWithInputType, alignWithOutputFilename, alignWithOutputFormat, alignWithAmbiguousCodons, alignWithSubstitutionMatrix string
+var alignWithQuiet, alignWithPprof, alignWithReverseComplement, alignWithSeed, alignWithEValue, alignWithSites, alignWithSplitRegions bool
+var alignWithGoroutines, alignWithBandWidth, alignWithMaxHits, alignWithMaxMatrixCells, alignWithMaxFullMatrixCells, alignWithMinQuality int
+var alignWithMinHitScore, alignWithMaxEValue float64
+var alignWithTimeout time.Duration
 
 func init() {
 	rootCmd.AddCommand(alignWithCmd)
@@ -59,16 +63,111 @@ func init() {
 		0,
 		"number of goroutines the aligner will use. (default: number of CPUs)",
 	)
+	alignWithCmd.Flags().BoolVarP(
+		&alignWithReverseComplement,
+		"reverse-complement",
+		"r",
+		false,
+		"also align the reverse complement of each sequence and keep the better-scoring strand; FirstNA is greater than LastNA for the reverse strand",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithSeed,
+		"seed",
+		false,
+		"locate each gene in long sequences, such as assembled genomes, with translated k-mer seeds and only align the window around them",
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithBandWidth,
+		"band-width",
+		-1,
+		"band width in nucleotides for banded alignment; 0 disables banding and -1 uses the profile's BandWidth",
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithMaxHits,
+		"max-hits",
+		1,
+		"report up to this many non-overlapping hits of each gene",
+	)
+	alignWithCmd.Flags().Float64Var(
+		&alignWithMinHitScore,
+		"min-hit-score",
+		0,
+		"ignore additional hits scoring lower than this, in the units of the profile; by default only the hits which don't score at all are ignored",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithEValue,
+		"evalue",
+		false,
+		"estimate and report the E-value of each alignment",
+	)
+	alignWithCmd.Flags().Float64Var(
+		&alignWithMaxEValue,
+		"max-evalue",
+		0,
+		"report alignments with higher E-values as errors; implies --evalue. (default: no cutoff)",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithSites,
+		"sites",
+		false,
+		"include the score breakdown of every aligned site; json output only",
+	)
+	alignWithCmd.Flags().StringVar(
+		&alignWithInputType,
+		"input-type",
+		"nucleotide",
+		"type of the input sequences. (options: \"nucleotide\", \"protein\")",
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithMaxMatrixCells,
+		"max-matrix-cells",
+		0,
+		"report sequences whose alignment matrix against a gene would exceed this many cells as errors; 0 means no limit",
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithMaxFullMatrixCells,
+		"max-full-matrix-cells",
+		alignment.DefaultMaxFullMatrixCells,
+		"trace back alignments whose matrix against a gene exceeds this many cells in linear memory, which is slower",
+	)
+	alignWithCmd.Flags().DurationVar(
+		&alignWithTimeout,
+		"timeout",
+		0,
+		"report sequences whose alignments against all the genes take longer than this (e.g. 30s) as errors; 0 means no limit",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithSplitRegions,
+		"split-regions",
+		false,
+		"report the regions of genes declared by the profile, such as the mature proteins of a polyprotein, as if they were genes",
+	)
+	alignWithCmd.Flags().StringVar(
+		&alignWithAmbiguousCodons,
+		"ambiguous-codons",
+		"",
+		"how codons with ambiguous nucleotides are scored. (options: \"average\", \"best\", \"worst\", \"weighted\"; default: the profile's AmbiguousCodonPolicy)",
+	)
+	alignWithCmd.Flags().StringVar(
+		&alignWithSubstitutionMatrix,
+		"substitution-matrix",
+		"",
+		fmt.Sprintf(
+			"name of a built-in substitution matrix (%v) or path to a matrix file in the NCBI format. (default: the profile's SubstitutionMatrix)",
+			strings.Join(d.BuiltinSubstitutionMatrixNames(), ", ")),
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithMinQuality,
+		"min-quality",
+		0,
+		"align the nucleotides of FASTQ reads with a lower Phred quality than this as N, and flag the mutations including them (as the LowQualityMutations column of the TSV output)",
+	)
 }
 
 func alignWithGetParameters(args []string) (*ap.AlignmentProfile, []string, error) {
 
 	profileFileName := args[0]
-	srcBytes, err := ioutil.ReadFile(profileFileName)
-	if err != nil {
-		return nil, nil, err
-	}
-	profile, err := ap.Parse(string(srcBytes))
+	profile, err := ap.ParseFile(profileFileName)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -94,6 +193,23 @@ func alignWithRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if alignWithBandWidth >= 0 {
+		profile.BandWidth = alignWithBandWidth
+	}
+	if alignWithAmbiguousCodons != "" {
+		policy, err := ap.ParseAmbiguousCodonPolicy(alignWithAmbiguousCodons)
+		if err != nil {
+			return err
+		}
+		profile.AmbiguousCodonPolicy = policy
+	}
+	if alignWithSubstitutionMatrix != "" {
+		matrix, err := d.LoadSubstitutionMatrix(alignWithSubstitutionMatrix)
+		if err != nil {
+			return err
+		}
+		profile.SubstitutionMatrix = matrix
+	}
 	return cli.PerformAlignment(
 		alignWithInputFilename,
 		alignWithOutputFilename,
@@ -101,15 +217,30 @@ func alignWithRun(cmd *cobra.Command, args []string) error {
 		genes,
 		alignWithGoroutines,
 		alignWithQuiet,
+		cli.AlignmentOptions{
+			ReverseComplement:  alignWithReverseComplement,
+			Seed:               alignWithSeed,
+			MaxHits:            alignWithMaxHits,
+			MinHitScore:        alignWithMinHitScore,
+			EValue:             alignWithEValue,
+			MaxEValue:          alignWithMaxEValue,
+			Sites:              alignWithSites,
+			InputType:          alignWithInputType,
+			MaxMatrixCells:     alignWithMaxMatrixCells,
+			Timeout:            alignWithTimeout,
+			MaxFullMatrixCells: alignWithMaxFullMatrixCells,
+			SplitRegions:       alignWithSplitRegions,
+			MinQuality:         alignWithMinQuality,
+		},
 		*profile,
 	)
 }
 
 var alignWithLongMsg = `
-Loads nucleotide sequences from a FASTA file and aligns them using a
-custom profile loaded from a YAML file. The first argument is the path
-to the YAML file containing the profile. The second argument is a
-comma separated list of genes to align against. (This list should
+Loads nucleotide sequences from a FASTA or FASTQ file and aligns them
+using a custom profile loaded from a YAML file. The first argument is
+the path to the YAML file containing the profile. The second argument
+is a comma separated list of genes to align against. (This list should
 either be surrounded by quote marks or contain no spaces).
 
 Examples:
@@ -125,7 +256,7 @@ Use 'nucamino align' to use a built-in alignment profile.`
 
 var alignWithCmd = &cobra.Command{
 	Use:   "align-with",
-	Short: "align sequences in a FASTA file using a custom alignment profile",
+	Short: "align sequences in a FASTA or FASTQ file using a custom alignment profile",
 	Long:  alignWithLongMsg,
 	Args:  cobra.ExactArgs(2),
 	RunE:  alignWithRun,
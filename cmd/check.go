@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // checkCmd represents the check command
@@ -16,7 +17,8 @@ var checkCmd = &cobra.Command{
 	Long: `
 Loads a YAML document and parses an alignment profile from it. Checks
 that the required 'ReferenceSequences' value is present, that amino
-acid sequences are valid, and that algorithm parameters have the
+acid sequences are valid, that the substitution matrix, if any, scores
+all twenty amino acids, and that algorithm parameters have the
 appropriate types. The argument, if given, is the filename to load the
 profile from; reads from standard input if no argument is given.
 
@@ -48,8 +50,10 @@ func runCheck(cmd *cobra.Command, args []string) {
 	}
 }
 
-func checkSource(source []byte) {
-	_, err := ap.Parse(string(source))
+// checkSource checks a profile, whose relative paths are relative to
+// dir.
+func checkSource(dir string, source []byte) {
+	_, err := ap.ParseIn(dir, string(source))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing alignment profile: %v\n", err)
 		os.Exit(1)
@@ -63,7 +67,7 @@ func checkFile(filename string) {
 		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
 		os.Exit(1)
 	}
-	checkSource(srcBytes)
+	checkSource(filepath.Dir(filename), srcBytes)
 }
 
 func checkStandardInput() {
@@ -72,5 +76,5 @@ func checkStandardInput() {
 		fmt.Fprintf(os.Stderr, "Error reading from standard input: %v\n", err)
 		os.Exit(1)
 	}
-	checkSource(srcBytes)
+	checkSource("", srcBytes)
 }
@@ -0,0 +1,160 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A SubstitutionMatrix scores the substitution of an amino acid of the
+// reference by an amino acid of the query, such as BLOSUM62.
+type SubstitutionMatrix struct {
+	// The name of a built-in matrix, or the path of the file the
+	// matrix was read from
+	Name   string
+	scores [a.NumAminoAcids][a.NumAminoAcids]int
+}
+
+// Lookup returns the score of substituting aa2 by aa1
+func (self *SubstitutionMatrix) Lookup(aa1 a.AminoAcid, aa2 a.AminoAcid) int {
+	return self.scores[aa1][aa2]
+}
+
+// Blosum62 is the substitution matrix used by profiles which don't
+// name one.
+var Blosum62 = matrixFromMap("BLOSUM62", blosum62)
+
+var builtinMatrices = map[string]*SubstitutionMatrix{
+	Blosum45.Name: Blosum45,
+	Blosum50.Name: Blosum50,
+	Blosum62.Name: Blosum62,
+	Blosum80.Name: Blosum80,
+	Pam250.Name:   Pam250,
+}
+
+func matrixFromMap(name string, scores map[a.AminoAcid]map[a.AminoAcid]int8) *SubstitutionMatrix {
+	matrix := &SubstitutionMatrix{Name: name}
+	for aa1, row := range scores {
+		for aa2, score := range row {
+			matrix.scores[aa1][aa2] = int(score)
+		}
+	}
+	return matrix
+}
+
+// BuiltinSubstitutionMatrix returns the built-in matrix of the given
+// name (case insensitive), if there is one.
+func BuiltinSubstitutionMatrix(name string) (*SubstitutionMatrix, bool) {
+	matrix, found := builtinMatrices[strings.ToUpper(name)]
+	return matrix, found
+}
+
+// BuiltinSubstitutionMatrixNames returns the names of the built-in
+// matrices in alphabetical order.
+func BuiltinSubstitutionMatrixNames() []string {
+	names := make([]string, 0, len(builtinMatrices))
+	for name := range builtinMatrices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadSubstitutionMatrix returns the built-in matrix named nameOrPath,
+// or else reads the matrix from the file at that path.
+func LoadSubstitutionMatrix(nameOrPath string) (*SubstitutionMatrix, error) {
+	return LoadSubstitutionMatrixIn("", nameOrPath)
+}
+
+// LoadSubstitutionMatrixIn works like LoadSubstitutionMatrix, but
+// resolves a relative path against dir, such as the directory of the
+// profile naming the matrix. The matrix is still named nameOrPath.
+func LoadSubstitutionMatrixIn(dir string, nameOrPath string) (*SubstitutionMatrix, error) {
+	if matrix, found := BuiltinSubstitutionMatrix(nameOrPath); found {
+		return matrix, nil
+	}
+	path := nameOrPath
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Substitution matrix '%v' is neither a built-in matrix (%v) nor a readable file: %v",
+			nameOrPath, strings.Join(BuiltinSubstitutionMatrixNames(), ", "), err)
+	}
+	defer file.Close()
+	return ReadSubstitutionMatrix(nameOrPath, file)
+}
+
+// ReadSubstitutionMatrix reads a matrix in the text format of the
+// NCBI: lines starting with '#' are comments, the first other line
+// lists the amino acids of the columns, and each following line starts
+// with the amino acid of the row followed by its scores. The matrix
+// must score every pair of the twenty amino acids; other letters, such
+// as B, Z, X and *, are ignored.
+func ReadSubstitutionMatrix(name string, reader io.Reader) (*SubstitutionMatrix, error) {
+	var (
+		matrix  = &SubstitutionMatrix{Name: name}
+		scored  [a.NumAminoAcids][a.NumAminoAcids]bool
+		columns []string
+		scanner = bufio.NewScanner(reader)
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if columns == nil {
+			columns = fields
+			continue
+		}
+		if len(fields) != len(columns)+1 {
+			return nil, fmt.Errorf(
+				"Expecting %d scores in the row %v of the substitution matrix %v but got %d",
+				len(columns), fields[0], name, len(fields)-1)
+		}
+		rowAA, isRowAA := lookupAminoAcid(fields[0])
+		for idx, column := range columns {
+			score, err := strconv.Atoi(fields[idx+1])
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Invalid score '%v' of %v against %v in the substitution matrix %v",
+					fields[idx+1], fields[0], column, name)
+			}
+			if colAA, isColAA := lookupAminoAcid(column); isRowAA && isColAA {
+				matrix.scores[rowAA][colAA] = score
+				scored[rowAA][colAA] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, aa1 := range a.AminoAcids {
+		for _, aa2 := range a.AminoAcids {
+			if !scored[aa1][aa2] {
+				return nil, fmt.Errorf(
+					"The substitution matrix %v doesn't score %v against %v",
+					name, a.ToString(aa1), a.ToString(aa2))
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// lookupAminoAcid returns the amino acid of a letter of a matrix, if it
+// is one of the twenty amino acids.
+func lookupAminoAcid(letter string) (a.AminoAcid, bool) {
+	aas := a.ReadString(letter)
+	if len(letter) != 1 || len(aas) != 1 {
+		return 0, false
+	}
+	return aas[0], true
+}
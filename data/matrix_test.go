@@ -0,0 +1,162 @@
+package data
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	MSG_NOT_EQUAL = "Expect %#v but received %#v"
+)
+
+// blosum62Text writes BLOSUM62 in the NCBI format, with the columns and
+// rows of the ambiguous amino acids and of stops
+func blosum62Text() string {
+	var text strings.Builder
+	text.WriteString("#  Matrix made by matblas from blosum62.iij\n")
+	text.WriteString("   A  R  N  D  C  Q  E  G  H  I  L  K  M  F  P  S  T  W  Y  V  B  Z  X  *\n")
+	letters := "ARNDCQEGHILKMFPSTWYV"
+	for _, rowLetter := range letters {
+		rowAA := a.ReadString(string(rowLetter))[0]
+		text.WriteString(string(rowLetter))
+		for _, colLetter := range letters {
+			colAA := a.ReadString(string(colLetter))[0]
+			fmt.Fprintf(&text, " %2d", LookupBlosum62(rowAA, colAA))
+		}
+		text.WriteString(" -1 -1 -1 -4\n")
+	}
+	for _, letter := range "BZX*" {
+		text.WriteString(string(letter) + strings.Repeat(" -4", 24) + "\n")
+	}
+	return text.String()
+}
+
+func TestBlosum62(t *testing.T) {
+	for _, aa1 := range a.AminoAcids {
+		for _, aa2 := range a.AminoAcids {
+			if Blosum62.Lookup(aa1, aa2) != int(LookupBlosum62(aa1, aa2)) {
+				t.Errorf(MSG_NOT_EQUAL, LookupBlosum62(aa1, aa2), Blosum62.Lookup(aa1, aa2))
+			}
+		}
+	}
+}
+
+func TestBuiltinSubstitutionMatrices(t *testing.T) {
+	for _, name := range BuiltinSubstitutionMatrixNames() {
+		matrix, _ := BuiltinSubstitutionMatrix(name)
+		for _, aa1 := range a.AminoAcids {
+			for _, aa2 := range a.AminoAcids {
+				if matrix.Lookup(aa1, aa2) != matrix.Lookup(aa2, aa1) {
+					t.Errorf("%v isn't symmetric at %v, %v", name, a.ToString(aa1), a.ToString(aa2))
+				}
+			}
+		}
+	}
+	for _, cell := range []struct {
+		name   string
+		aa1    a.AminoAcid
+		aa2    a.AminoAcid
+		expect int
+	}{
+		{"BLOSUM45", a.W, a.W, 15},
+		{"BLOSUM45", a.C, a.C, 12},
+		{"BLOSUM45", a.R, a.K, 3},
+		{"BLOSUM45", a.W, a.C, -5},
+		{"BLOSUM50", a.P, a.P, 10},
+		{"BLOSUM50", a.D, a.F, -5},
+		{"BLOSUM50", a.I, a.V, 4},
+		{"BLOSUM80", a.W, a.W, 11},
+		{"BLOSUM80", a.D, a.W, -6},
+		{"BLOSUM80", a.L, a.L, 4},
+		{"PAM250", a.W, a.W, 17},
+		{"PAM250", a.C, a.W, -8},
+		{"PAM250", a.F, a.Y, 7},
+		{"PAM250", a.R, a.W, 2},
+	} {
+		matrix, found := BuiltinSubstitutionMatrix(cell.name)
+		if !found {
+			t.Errorf("Expected the built-in matrix %v", cell.name)
+			continue
+		}
+		if score := matrix.Lookup(cell.aa1, cell.aa2); score != cell.expect {
+			t.Errorf(MSG_NOT_EQUAL, cell.expect, score)
+		}
+	}
+}
+
+func TestReadSubstitutionMatrix(t *testing.T) {
+	matrix, err := ReadSubstitutionMatrix("test", strings.NewReader(blosum62Text()))
+	if err != nil {
+		t.Fatalf(MSG_NOT_EQUAL, nil, err)
+	}
+	if matrix.Name != "test" || matrix.scores != Blosum62.scores {
+		t.Errorf(MSG_NOT_EQUAL, Blosum62, matrix)
+	}
+}
+
+func TestReadSubstitutionMatrixInvalid(t *testing.T) {
+	text := blosum62Text()
+	for _, invalid := range []string{
+		// no row of W
+		strings.Replace(text, "\nW ", "\n# W ", 1),
+		// no column of W
+		strings.Replace(text, "  W  Y", "  X  Y", 1),
+		strings.Replace(text, "\nA  4", "\nA  four", 1),
+		strings.Replace(text, "\nA  4", "\nA", 1),
+		"",
+	} {
+		if _, err := ReadSubstitutionMatrix("test", strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
+
+func TestLoadSubstitutionMatrix(t *testing.T) {
+	matrix, err := LoadSubstitutionMatrix("blosum62")
+	if matrix != Blosum62 || err != nil {
+		t.Errorf(MSG_NOT_EQUAL, Blosum62, matrix)
+	}
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "matrix.txt")
+	if err := ioutil.WriteFile(path, []byte(blosum62Text()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matrix, err = LoadSubstitutionMatrix(path)
+	if err != nil || matrix.Name != path || matrix.scores != Blosum62.scores {
+		t.Errorf(MSG_NOT_EQUAL, path, matrix)
+	}
+	if _, err = LoadSubstitutionMatrix(filepath.Join(dir, "BLOSUM30")); err == nil {
+		t.Errorf("Expected an error for an unknown matrix")
+	}
+}
+
+func TestLoadSubstitutionMatrixIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nucamino")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "matrix.txt")
+	if err := ioutil.WriteFile(path, []byte(blosum62Text()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a relative path is resolved against dir, an absolute one isn't
+	for _, nameOrPath := range []string{"matrix.txt", path} {
+		matrix, err := LoadSubstitutionMatrixIn(dir, nameOrPath)
+		if err != nil || matrix.Name != nameOrPath || matrix.scores != Blosum62.scores {
+			t.Errorf(MSG_NOT_EQUAL, nameOrPath, matrix)
+		}
+	}
+	if matrix, _ := LoadSubstitutionMatrixIn(dir, "pam250"); matrix != Pam250 {
+		t.Errorf(MSG_NOT_EQUAL, Pam250, matrix)
+	}
+}
@@ -1,7 +1,10 @@
 package scorehandler
 
 import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	d "github.com/hivdb/nucamino/data"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
 	n "github.com/hivdb/nucamino/types/nucleic"
 )
 
@@ -29,3 +32,89 @@ type ScoreHandler interface {
 		/* openingBonus */ int,
 		/* extensionBonus */ int)
 }
+
+// The aligner checks if a ScoreHandler also implements the interfaces
+// below, and falls back to defaults for the ones it doesn't.
+
+// CodonScores holds the substitution scores of the codons aligned to one
+// amino acid of the reference, indexed by their three nucleotides.
+type CodonScores [n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int
+
+// CodonScoreTables hold the substitution scores of every codon, computed
+// in advance so that they can be shared by the aligners of several
+// goroutines. The aligner looks up the table of a reference position
+// once for all the codons aligned to it. At positions without a table,
+// where GetCodonScores returns nil, and without CodonScoreTables, it
+// scores each codon of the query once per position instead, with
+// GetSubstitutionScoreNoCache or GetSubstitutionScore respectively.
+type CodonScoreTables interface {
+	GetCodonScores(
+		/* refPosition */ int,
+		/* ref */ a.AminoAcid) *CodonScores
+	GetSubstitutionScoreNoCache(
+		/* refPosition */ int,
+		/* base1 */ n.NucleicAcid,
+		/* base2 */ n.NucleicAcid,
+		/* base3 */ n.NucleicAcid,
+		/* ref */ a.AminoAcid) int
+}
+
+// A ScoreScaler returns scores multiplied by its scale, so that they
+// can be integers; the reports divide them by it. Scores of other
+// handlers aren't scaled.
+type ScoreScaler interface {
+	GetScoreScale() int
+}
+
+// AlignmentSettings tell how to align: which leading and trailing gaps
+// are free, the band width (zero for no band) and the least number of
+// consecutive unsequenced codons reported as an unsequenced region.
+// Without them, gaps at the ends are free, the alignment isn't banded
+// and ap.DefaultMinUnsequencedCodons is used.
+type AlignmentSettings interface {
+	GetAlignmentMode() ap.AlignmentMode
+	GetBandWidth() int
+	GetMinUnsequencedCodons() int
+}
+
+// ReferenceAnnotations describe the reference beyond its amino acids:
+// its regions, its coding sequence and the positions where its reading
+// frame is expected to shift. Without them, the reference has none.
+type ReferenceAnnotations interface {
+	GetRegions() []ap.Region
+	GetReferenceCodon( /* refPosition */ int) (c.Codon, bool)
+	GetExpectedFrameShift( /* refPosition */ int) int
+}
+
+// An AmbiguousCodonMatcher tells if an ambiguous codon matches the
+// reference, and so isn't reported as a mutation. Without it, a codon
+// matches if all of its unambiguous codons code the reference.
+type AmbiguousCodonMatcher interface {
+	IsMatchingCodon(
+		/* refPosition */ int,
+		/* codon */ c.Codon,
+		/* ref */ a.AminoAcid) bool
+}
+
+// A Translator translates codons with the genetic code of the
+// reference, such as a mitochondrial code. Without it, the standard
+// genetic code is used.
+type Translator interface {
+	GetGeneticCode() *c.GeneticCode
+}
+
+// A MatrixSelector tells the substitution matrix which scores amino
+// acids, and so which of them are similar. Without it, BLOSUM62 is used.
+type MatrixSelector interface {
+	GetSubstitutionMatrix() *d.SubstitutionMatrix
+}
+
+// An AminoAcidScoreHandler also scores amino acids, which protein
+// queries are made of.
+type AminoAcidScoreHandler interface {
+	ScoreHandler
+	GetAminoAcidSubstitutionScore(
+		/* refPosition */ int,
+		/* aa */ a.AminoAcid,
+		/* ref */ a.AminoAcid) int
+}
@@ -3,101 +3,304 @@ package general
 import (
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	d "github.com/hivdb/nucamino/data"
+	s "github.com/hivdb/nucamino/scorehandler"
 	a "github.com/hivdb/nucamino/types/amino"
 	c "github.com/hivdb/nucamino/types/codon"
 	n "github.com/hivdb/nucamino/types/nucleic"
 )
 
-const negInf = -int((^uint(0))>>1) - 1
+type GeneralScoreHandler struct {
+	scoreScale               int
+	stopCodonPenalty         int
+	gapOpenPenalty           int
+	gapExtensionPenalty      int
+	indelCodonOpeningBonus   int
+	indelCodonExtensionBonus int
+	// The opening and extension bonuses of insertions and deletions,
+	// indexed by position
+	insertionScores                 [][2]int
+	deletionScores                  [][2]int
+	insertionScoreDefaults          [2]int
+	deletionScoreDefaults           [2]int
+	isPositionalIndelScoreSupported bool
+	bandWidth                       int
+	minUnsequencedCodons            int
+	alignmentMode                   ap.AlignmentMode
+	referenceCodons                 []c.Codon
+	regions                         []ap.Region
+	expectedFrameShifts             []int
+	ambiguousCodonPolicy            ap.AmbiguousCodonPolicy
+	prevalences                     ap.AminoAcidPrevalences
+	geneticCode                     *c.GeneticCode
+	substitutionMatrix              *d.SubstitutionMatrix
+	// The scaled scores of the substitution matrix, indexed by the
+	// amino acid of the reference
+	matrixScores   [a.NumAminoAcids][a.NumAminoAcids]int
+	scoreMatrix    *[a.NumAminoAcids]s.CodonScores
+	positionScores []*positionScores
+}
 
-// FNV algorithm was used to generated index number for the bloom filter
-const FNVPrime int64 = 16777619
-const FNVOffsetBasis int64 = 2166136261
+// The handler implements all the optional interfaces of the aligner
+var (
+	_ s.AminoAcidScoreHandler = (*GeneralScoreHandler)(nil)
+	_ s.CodonScoreTables      = (*GeneralScoreHandler)(nil)
+	_ s.ScoreScaler           = (*GeneralScoreHandler)(nil)
+	_ s.AlignmentSettings     = (*GeneralScoreHandler)(nil)
+	_ s.ReferenceAnnotations  = (*GeneralScoreHandler)(nil)
+	_ s.AmbiguousCodonMatcher = (*GeneralScoreHandler)(nil)
+	_ s.Translator            = (*GeneralScoreHandler)(nil)
+	_ s.MatrixSelector        = (*GeneralScoreHandler)(nil)
+)
 
-func simpleFNV1a(key int) int64 {
-	// http://www.isthe.com/chongo/tech/comp/fnv/index.html
-	// The possible values of "key" are between -50000 and 50000
-	// and there's no collision in this region
+// positionScores holds the position-specific scores of the profile at
+// one position, which replace the substitution matrix there. Since they
+// don't depend on the reference amino acid, the scores of codons at the
+// position are tabulated apart from scoreMatrix.
+type positionScores struct {
+	aminoAcidScores [a.NumAminoAcids]int
+	codonScores     s.CodonScores
+}
 
-	// This is not a strict implementation of FNV (the key is not an octet),
-	// however it reduced the collisions of bloom filter in HIV-1 POL case
-	return (FNVOffsetBasis ^ int64(key)) * FNVPrime
+// getPositionScores returns the position-specific scores at position,
+// or nil if the profile has none there
+func (self *GeneralScoreHandler) getPositionScores(position int) *positionScores {
+	// a single comparison also rules out positions below 1
+	if idx := uint(position - 1); idx < uint(len(self.positionScores)) {
+		return self.positionScores[idx]
+	}
+	return nil
 }
 
-type GeneralScoreHandler struct {
-	scoreScale                       int
-	stopCodonPenalty                 int
-	gapOpenPenalty                   int
-	gapExtensionPenalty              int
-	indelCodonOpeningBonus           int
-	indelCodonExtensionBonus         int
-	positionalIndelScores            map[int][2]int
-	positionalIndelScoresBloomFilter int64
-	isPositionalIndelScoreSupported  bool
-	scoreMatrix                      *[a.NumAminoAcids][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int
-}
-
-func (self *GeneralScoreHandler) GetCachedSubstitutionScore(
-	position int,
-	base1 n.NucleicAcid,
-	base2 n.NucleicAcid,
-	base3 n.NucleicAcid,
-	ref a.AminoAcid) (int, bool) {
-	score, present := self.scoreMatrix[ref][base1][base2][base3], true
-	if score == negInf {
-		present = false
+// getAminoAcidScores returns the scaled scores of the amino acids at
+// position, where the reference has ref
+func (self *GeneralScoreHandler) getAminoAcidScores(position int, ref a.AminoAcid) *[a.NumAminoAcids]int {
+	if scores := self.getPositionScores(position); scores != nil {
+		return &scores.aminoAcidScores
+	}
+	return &self.matrixScores[ref]
+}
+
+// GetCodonScores returns the table of the scores of the codons aligned
+// to ref at position, which New computed. The aligner looks it up once
+// for all the cells of a position. There's no table at the positions
+// whose amino acid prevalences weigh the scores of ambiguous codons.
+func (self *GeneralScoreHandler) GetCodonScores(position int, ref a.AminoAcid) *s.CodonScores {
+	if self.ambiguousCodonPolicy == ap.WeightedAmbiguousCodons {
+		if _, found := self.prevalences[position]; found {
+			return nil
+		}
 	}
-	return score, present
+	if scores := self.getPositionScores(position); scores != nil {
+		return &scores.codonScores
+	}
+	return &self.scoreMatrix[ref]
 }
 
+// GetSubstitutionScoreNoCache scores the codon without looking up the
+// tables.
 func (self *GeneralScoreHandler) GetSubstitutionScoreNoCache(
 	position int,
 	base1 n.NucleicAcid,
 	base2 n.NucleicAcid,
 	base3 n.NucleicAcid,
-	ref a.AminoAcid) (score int) {
+	ref a.AminoAcid) int {
 	codon := c.Codon{base1, base2, base3}
-	if codon.IsAmbiguous() {
-		// this loop also works with unambiguous codon,
-		// but it slows thing down a little bit
-		scores := 0
-		numAAs := 0
-		for _, ucodon := range codon.GetUnambiguousCodons() {
-			if ucodon.IsStopCodon() {
-				scores -= self.stopCodonPenalty
+	aaScores := self.getAminoAcidScores(position, ref)
+	if !codon.IsAmbiguous() {
+		return self.scoreUnambiguousCodon(codon, aaScores)
+	}
+	var (
+		ucodons = codon.GetUnambiguousCodons()
+		scores  = make([]int, len(ucodons))
+		weights []float64
+	)
+	for idx, ucodon := range ucodons {
+		scores[idx] = self.scoreUnambiguousPart(ucodon, aaScores)
+	}
+	if self.ambiguousCodonPolicy == ap.WeightedAmbiguousCodons {
+		weights = self.getPrevalenceWeights(position, ucodons)
+	}
+	return self.combineAmbiguousScores(scores, weights)
+}
+
+// scoreUnambiguousCodon returns the score of the unambiguous codon given
+// the scaled scores of the amino acids
+func (self *GeneralScoreHandler) scoreUnambiguousCodon(codon c.Codon, aaScores *[a.NumAminoAcids]int) int {
+	if self.geneticCode.IsStopCodon(codon) {
+		return -self.stopCodonPenalty
+	}
+	// unambiguous codon, can use unsafe function safely
+	return aaScores[self.geneticCode.ToAminoAcidUnsafe(codon)]
+}
+
+// scoreUnambiguousPart returns the score of the unambiguous codon ucodon
+// as one of the codons an ambiguous codon may be
+func (self *GeneralScoreHandler) scoreUnambiguousPart(ucodon c.Codon, aaScores *[a.NumAminoAcids]int) (score int) {
+	if self.geneticCode.IsStopCodon(ucodon) {
+		score -= self.stopCodonPenalty
+	}
+	return score + aaScores[self.geneticCode.ToAminoAcidUnsafe(ucodon)]
+}
+
+// combineAmbiguousScores returns the score of an ambiguous codon from
+// the scores of its unambiguous codons under the ambiguous codon policy
+// of the profile. weights are the prevalences of their amino acids, or
+// nil to weigh them equally.
+func (self *GeneralScoreHandler) combineAmbiguousScores(scores []int, weights []float64) (score int) {
+	switch self.ambiguousCodonPolicy {
+	case ap.BestAmbiguousCodons:
+		score = scores[0]
+		for _, ucodonScore := range scores {
+			if ucodonScore > score {
+				score = ucodonScore
 			}
-			scores += int(d.LookupBlosum62(ucodon.ToAminoAcidUnsafe(), ref)) * self.scoreScale
-			numAAs++
-		}
-		score = scores / numAAs
-		// if score > 0 {
-		// 	print(codon.ToString(), " ", score, "\n")
-		// }
-	} else if codon.IsStopCodon() {
-		score = -self.stopCodonPenalty
-	} else {
-		// unambiguous codon, can use unsafe function safely
-		aa := codon.ToAminoAcidUnsafe()
-		score = int(d.LookupBlosum62(aa, ref)) * self.scoreScale
-	}
-	self.scoreMatrix[ref][base1][base2][base3] = score
+		}
+	case ap.WorstAmbiguousCodons:
+		score = scores[0]
+		for _, ucodonScore := range scores {
+			if ucodonScore < score {
+				score = ucodonScore
+			}
+		}
+	case ap.WeightedAmbiguousCodons:
+		if weights != nil {
+			var weighted, total float64
+			for idx, ucodonScore := range scores {
+				weighted += weights[idx] * float64(ucodonScore)
+				total += weights[idx]
+			}
+			score = int(weighted / total)
+			break
+		}
+		fallthrough
+	default:
+		for _, ucodonScore := range scores {
+			score += ucodonScore
+		}
+		score /= len(scores)
+	}
 	return
 }
 
+// fillCodonScores scores every codon into table given the scaled scores
+// of the amino acids. The scores of ambiguous codons are combined from
+// the ones of their unambiguous codons, which are only computed once.
+func (self *GeneralScoreHandler) fillCodonScores(table *s.CodonScores, aaScores *[a.NumAminoAcids]int) {
+	var (
+		partScores [n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int
+		scores     = make([]int, 0, 64)
+	)
+	for _, na1 := range n.GetUnambiguousNucleicAcids(n.N) {
+		for _, na2 := range n.GetUnambiguousNucleicAcids(n.N) {
+			for _, na3 := range n.GetUnambiguousNucleicAcids(n.N) {
+				codon := c.Codon{na1, na2, na3}
+				table[na1][na2][na3] = self.scoreUnambiguousCodon(codon, aaScores)
+				partScores[na1][na2][na3] = self.scoreUnambiguousPart(codon, aaScores)
+			}
+		}
+	}
+	for _, na1 := range n.NucleicAcids {
+		for _, na2 := range n.NucleicAcids {
+			for _, na3 := range n.NucleicAcids {
+				codon := c.Codon{na1, na2, na3}
+				if !codon.IsAmbiguous() {
+					continue
+				}
+				scores = scores[:0]
+				for _, una1 := range n.GetUnambiguousNucleicAcids(na1) {
+					for _, una2 := range n.GetUnambiguousNucleicAcids(na2) {
+						for _, una3 := range n.GetUnambiguousNucleicAcids(na3) {
+							scores = append(scores, partScores[una1][una2][una3])
+						}
+					}
+				}
+				table[na1][na2][na3] = self.combineAmbiguousScores(scores, nil)
+			}
+		}
+	}
+}
+
+// getPrevalenceWeights returns the prevalence at position of the amino
+// acid of each of ucodons, or nil if none of them is prevalent. Stop
+// codons are never prevalent.
+func (self *GeneralScoreHandler) getPrevalenceWeights(position int, ucodons []c.Codon) []float64 {
+	percents, found := self.prevalences[position]
+	if !found {
+		return nil
+	}
+	var (
+		weights = make([]float64, len(ucodons))
+		total   float64
+	)
+	for idx, ucodon := range ucodons {
+		if !self.geneticCode.IsStopCodon(ucodon) {
+			weights[idx] = percents[self.geneticCode.ToAminoAcidUnsafe(ucodon)]
+			total += weights[idx]
+		}
+	}
+	if total <= 0 {
+		return nil
+	}
+	return weights
+}
+
+// IsMatchingCodon tells if codon, which may be ambiguous, matches the
+// reference amino acid ref at position under the ambiguous codon policy
+// of the profile. It can serve as a mutation.CodonMatcher.
+func (self *GeneralScoreHandler) IsMatchingCodon(position int, codon c.Codon, ref a.AminoAcid) bool {
+	var (
+		ucodons = codon.GetUnambiguousCodons()
+		weights []float64
+	)
+	switch self.ambiguousCodonPolicy {
+	case ap.BestAmbiguousCodons:
+		for _, ucodon := range ucodons {
+			if !self.geneticCode.IsStopCodon(ucodon) && self.geneticCode.ToAminoAcidUnsafe(ucodon) == ref {
+				return true
+			}
+		}
+		return false
+	case ap.WeightedAmbiguousCodons:
+		weights = self.getPrevalenceWeights(position, ucodons)
+	}
+	for idx, ucodon := range ucodons {
+		if weights != nil && weights[idx] == 0 {
+			// not prevalent; doesn't count
+			continue
+		}
+		if self.geneticCode.IsStopCodon(ucodon) || self.geneticCode.ToAminoAcidUnsafe(ucodon) != ref {
+			return false
+		}
+	}
+	return true
+}
+
 func (self *GeneralScoreHandler) GetSubstitutionScore(
 	position int,
 	base1 n.NucleicAcid,
 	base2 n.NucleicAcid,
 	base3 n.NucleicAcid,
 	ref a.AminoAcid) int {
-	// We use GetCachedSubstitutionScore separately to trigger gc's function inlining
-	// which can improve the performance by about 1/6
-	if score, present := self.GetCachedSubstitutionScore(position, base1, base2, base3, ref); present {
-		return score
+	if scores := self.GetCodonScores(position, ref); scores != nil {
+		return scores[base1][base2][base3]
 	}
 	return self.GetSubstitutionScoreNoCache(position, base1, base2, base3, ref)
 }
 
+// GetAminoAcidSubstitutionScore returns the score of aligning the
+// amino acid aa of a query protein to ref
+func (self *GeneralScoreHandler) GetAminoAcidSubstitutionScore(
+	position int,
+	aa a.AminoAcid,
+	ref a.AminoAcid) int {
+	return self.getAminoAcidScores(position, ref)[aa]
+}
+
+// Scores returned by the handler are multiplied by the score scale
+func (self *GeneralScoreHandler) GetScoreScale() int {
+	return self.scoreScale
+}
+
 func (self *GeneralScoreHandler) GetGapOpeningScore() int {
 	return -self.gapOpenPenalty
 }
@@ -110,30 +313,69 @@ func (self *GeneralScoreHandler) IsPositionalIndelScoreSupported() bool {
 	return self.isPositionalIndelScoreSupported
 }
 
+func (self *GeneralScoreHandler) GetBandWidth() int {
+	return self.bandWidth
+}
+
+func (self *GeneralScoreHandler) GetMinUnsequencedCodons() int {
+	return self.minUnsequencedCodons
+}
+
+func (self *GeneralScoreHandler) GetAlignmentMode() ap.AlignmentMode {
+	return self.alignmentMode
+}
+
+// GetGeneticCode returns the genetic code of the profile
+func (self *GeneralScoreHandler) GetGeneticCode() *c.GeneticCode {
+	return self.geneticCode
+}
+
+// GetSubstitutionMatrix returns the substitution matrix of the profile,
+// or BLOSUM62 if it has none
+func (self *GeneralScoreHandler) GetSubstitutionMatrix() *d.SubstitutionMatrix {
+	return self.substitutionMatrix
+}
+
+// GetReferenceCodon returns the codon of the reference coding sequence
+// at position, if the profile has one
+func (self *GeneralScoreHandler) GetReferenceCodon(position int) (c.Codon, bool) {
+	if position < 1 || position > len(self.referenceCodons) {
+		return c.Codon{}, false
+	}
+	return self.referenceCodons[position-1], true
+}
+
+// GetRegions returns the regions of the gene declared by the profile
+func (self *GeneralScoreHandler) GetRegions() []ap.Region {
+	return self.regions
+}
+
+// GetExpectedFrameShift returns the number of nucleotides the reading
+// frame of the gene is expected to shift by at position, or zero
+func (self *GeneralScoreHandler) GetExpectedFrameShift(position int) int {
+	if position < 1 || position > len(self.expectedFrameShifts) {
+		return 0
+	}
+	return self.expectedFrameShifts[position-1]
+}
+
 func (self *GeneralScoreHandler) GetConstantIndelCodonScore() (int, int) {
 	return self.indelCodonOpeningBonus, self.indelCodonExtensionBonus
 }
 
 func (self *GeneralScoreHandler) GetPositionalIndelCodonScore(position int, isInsertion bool) (int, int) {
-	score := self.indelCodonOpeningBonus
-	ext := self.indelCodonExtensionBonus
-	if self.isPositionalIndelScoreSupported {
-		sign := -1 // deletion
-		if isInsertion {
-			sign = 1
-		}
-		key := sign * position
-		hashed := simpleFNV1a(key)
-		if self.positionalIndelScoresBloomFilter&hashed == hashed {
-			// the bloom filter removed most negatives; now search the real map
-			_score, ok := self.positionalIndelScores[key]
-			if ok {
-				score = _score[0]
-				ext = _score[1]
-			}
-		}
+	if !self.isPositionalIndelScoreSupported {
+		return self.indelCodonOpeningBonus, self.indelCodonExtensionBonus
+	}
+	scores, defaults := self.deletionScores, self.deletionScoreDefaults
+	if isInsertion {
+		scores, defaults = self.insertionScores, self.insertionScoreDefaults
 	}
-	return score, ext
+	// a single comparison also rules out negative positions
+	if idx := uint(position); idx < uint(len(scores)) {
+		return scores[idx][0], scores[idx][1]
+	}
+	return defaults[0], defaults[1]
 }
 
 type GeneralScoreHandlerParams struct {
@@ -146,36 +388,128 @@ type GeneralScoreHandlerParams struct {
 	SupportsPositionalIndelScores bool
 }
 
+// New builds the handler of a gene of the profile. It scores every
+// codon against each amino acid and at each position-specific scoring
+// position in advance, so that the handler never changes afterwards
+// and can be shared by the aligners of several goroutines.
 func New(gene ap.Gene, profile ap.AlignmentProfile) *GeneralScoreHandler {
 	scoreScale := 100
-	scoreMatrix := [a.NumAminoAcids][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int{}
-	for i, matrix3d := range scoreMatrix {
-		for x, matrix2d := range matrix3d {
-			for y, matrix1d := range matrix2d {
-				for z := range matrix1d {
-					scoreMatrix[i][x][y][z] = negInf
-				}
+	indelScores, supported := profile.PositionalIndelScoresFor(gene)
+	insertionScoreDefaults := [2]int{
+		profile.IndelCodonOpeningBonus * scoreScale, profile.IndelCodonExtensionBonus * scoreScale}
+	deletionScoreDefaults := insertionScoreDefaults
+	if defaults, found := profile.IndelScoreDefaultsFor(gene); found {
+		// the defaults of the gene replace the constant bonuses
+		if defaults.Ins != nil {
+			insertionScoreDefaults = [2]int{defaults.Ins[0] * scoreScale, defaults.Ins[1] * scoreScale}
+		}
+		if defaults.Del != nil {
+			deletionScoreDefaults = [2]int{defaults.Del[0] * scoreScale, defaults.Del[1] * scoreScale}
+		}
+		supported = true
+	}
+	var insertionScores, deletionScores [][2]int
+	if supported {
+		// indexed by position, from 0 (before the first amino acid)
+		// to the end of the reference or the last positional scores
+		size := len(profile.ReferenceSequences[gene]) + 1
+		for key := range indelScores {
+			if key >= size {
+				size = key + 1
+			} else if -key >= size {
+				size = -key + 1
+			}
+		}
+		insertionScores = make([][2]int, size)
+		deletionScores = make([][2]int, size)
+		for pos := 0; pos < size; pos++ {
+			insertionScores[pos] = insertionScoreDefaults
+			deletionScores[pos] = deletionScoreDefaults
+		}
+		for key, score := range indelScores {
+			// the magnitude of the key is the position, negative
+			// indicates deletion; zero stands for both at position 0
+			scaled := [2]int{score[0] * scoreScale, score[1] * scoreScale}
+			if key >= 0 {
+				insertionScores[key] = scaled
+			}
+			if key <= 0 {
+				deletionScores[-key] = scaled
 			}
 		}
 	}
-	var positionalIndelScoresBloomFilter int64 = 0
-	scaledPositionalIndelScores := map[int][2]int{}
-	indelScores, supported := profile.PositionalIndelScoresFor(gene)
-	for key, score := range indelScores {
-		// create a tiny bloom filter to prune negatives
-		positionalIndelScoresBloomFilter |= simpleFNV1a(key)
-		scaledPositionalIndelScores[key] = [2]int{score[0] * scoreScale, score[1] * scoreScale}
-	}
-	return &GeneralScoreHandler{
-		scoreScale:                       scoreScale,
-		stopCodonPenalty:                 profile.StopCodonPenalty * scoreScale,
-		gapOpenPenalty:                   profile.GapOpeningPenalty * scoreScale,
-		gapExtensionPenalty:              profile.GapExtensionPenalty * scoreScale,
-		indelCodonOpeningBonus:           profile.IndelCodonOpeningBonus * scoreScale,
-		indelCodonExtensionBonus:         profile.IndelCodonExtensionBonus * scoreScale,
-		positionalIndelScores:            scaledPositionalIndelScores,
-		positionalIndelScoresBloomFilter: positionalIndelScoresBloomFilter,
-		isPositionalIndelScoreSupported:  supported,
-		scoreMatrix:                      &scoreMatrix,
+	var referenceCodons []c.Codon
+	for pos := 1; ; pos++ {
+		codon, ok := profile.ReferenceCodonFor(gene, pos)
+		if !ok {
+			break
+		}
+		referenceCodons = append(referenceCodons, codon)
+	}
+	var expectedFrameShifts []int
+	if frameShifts := profile.ExpectedFrameShiftsFor(gene); len(frameShifts) > 0 {
+		// indexed by position, so that the aligner can look them up in
+		// each cell
+		for _, frameShift := range frameShifts {
+			for len(expectedFrameShifts) < frameShift.Position {
+				expectedFrameShifts = append(expectedFrameShifts, 0)
+			}
+			expectedFrameShifts[frameShift.Position-1] = frameShift.Shift
+		}
+	}
+	var positionScoresList []*positionScores
+	if pssm := profile.PSSMFor(gene); len(pssm) > 0 {
+		// indexed by position, like expectedFrameShifts
+		for position, aaScores := range pssm {
+			for len(positionScoresList) < position {
+				positionScoresList = append(positionScoresList, nil)
+			}
+			scores := &positionScores{}
+			for aa, score := range aaScores {
+				scores.aminoAcidScores[aa] = score * scoreScale
+			}
+			positionScoresList[position-1] = scores
+		}
+	}
+	substitutionMatrix := profile.SubstitutionMatrix
+	if substitutionMatrix == nil {
+		substitutionMatrix = d.Blosum62
+	}
+	handler := &GeneralScoreHandler{
+		scoreScale:                      scoreScale,
+		stopCodonPenalty:                profile.StopCodonPenalty * scoreScale,
+		gapOpenPenalty:                  profile.GapOpeningPenalty * scoreScale,
+		gapExtensionPenalty:             profile.GapExtensionPenalty * scoreScale,
+		indelCodonOpeningBonus:          profile.IndelCodonOpeningBonus * scoreScale,
+		indelCodonExtensionBonus:        profile.IndelCodonExtensionBonus * scoreScale,
+		insertionScores:                 insertionScores,
+		deletionScores:                  deletionScores,
+		isPositionalIndelScoreSupported: supported,
+		insertionScoreDefaults:          insertionScoreDefaults,
+		deletionScoreDefaults:           deletionScoreDefaults,
+		bandWidth:                       profile.BandWidth,
+		minUnsequencedCodons:            profile.MinUnsequencedCodonsOrDefault(),
+		alignmentMode:                   profile.AlignmentModeFor(gene),
+		referenceCodons:                 referenceCodons,
+		regions:                         profile.RegionsFor(gene),
+		expectedFrameShifts:             expectedFrameShifts,
+		ambiguousCodonPolicy:            profile.AmbiguousCodonPolicy,
+		prevalences:                     profile.PrevalencesFor(gene),
+		geneticCode:                     profile.GeneticCodeOrDefault(),
+		substitutionMatrix:              substitutionMatrix,
+		scoreMatrix:                     new([a.NumAminoAcids]s.CodonScores),
+		positionScores:                  positionScoresList,
+	}
+	for _, ref := range a.AminoAcids {
+		for _, aa := range a.AminoAcids {
+			handler.matrixScores[ref][aa] = substitutionMatrix.Lookup(aa, ref) * scoreScale
+		}
+		handler.fillCodonScores(&handler.scoreMatrix[ref], &handler.matrixScores[ref])
+	}
+	for _, scores := range positionScoresList {
+		if scores != nil {
+			handler.fillCodonScores(&scores.codonScores, &scores.aminoAcidScores)
+		}
 	}
+	return handler
 }
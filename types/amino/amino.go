@@ -1,6 +1,7 @@
 package amino
 
 import (
+	"fmt"
 	"github.com/hivdb/nucamino/utils"
 	"strings"
 )
@@ -102,6 +103,24 @@ func ReadString(aminoAcidSequence string) []AminoAcid {
 	return result[:idx]
 }
 
+// ReadStringStrict works like ReadString, but reports the first letter
+// which isn't one of the twenty amino acids, with its position among
+// the letters, instead of leaving it out.
+func ReadStringStrict(aminoAcidSequence string) ([]AminoAcid, error) {
+	aminoAcidSequence = strings.ToUpper(
+		utils.StripWhiteSpace(aminoAcidSequence))
+	result := make([]AminoAcid, 0, len(aminoAcidSequence))
+	for _, runeVal := range aminoAcidSequence {
+		aa, present := aminoAcidLookupR[runeVal]
+		if !present {
+			return nil, fmt.Errorf(
+				"unexpected %q at position %d", runeVal, len(result)+1)
+		}
+		result = append(result, aa)
+	}
+	return result, nil
+}
+
 func WriteString(aas []AminoAcid) string {
 	var result string
 	for _, aa := range aas {
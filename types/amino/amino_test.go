@@ -33,6 +33,19 @@ func TestReadString(t *testing.T) {
 	}
 }
 
+func TestReadStringStrict(t *testing.T) {
+	result, err := ReadStringStrict("acd ef")
+	expect := []AminoAcid{A, C, D, E, F}
+	if err != nil || !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result, err = ReadStringStrict("ACDX*F")
+	expectErr := "unexpected 'X' at position 4"
+	if result != nil || err == nil || err.Error() != expectErr {
+		t.Errorf(MSG_NOT_EQUAL, expectErr, err)
+	}
+}
+
 func TestWriteString(t *testing.T) {
 	result := WriteString([]AminoAcid{A, C, D, E, F, G, V, W, Y, Y})
 	expect := "ACDEFGVWYY"
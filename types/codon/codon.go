@@ -11,107 +11,9 @@ type Codon struct {
 	Base3 NucleicAcid
 }
 
-var stopCodons = map[Codon]bool{
-	Codon{T, A, A}: true,
-	Codon{T, A, G}: true,
-	Codon{T, G, A}: true,
-	Codon{T, A, R}: true, // TAA & TAG
-	Codon{T, R, A}: true, // TAA & TGA
-}
-
-var CodonToAminoAcidTable = map[Codon]a.AminoAcid{
-	Codon{T, T, T}: a.F,
-	Codon{T, T, C}: a.F,
-	Codon{T, T, A}: a.L,
-	Codon{T, T, G}: a.L,
-
-	Codon{C, T, T}: a.L,
-	Codon{C, T, C}: a.L,
-	Codon{C, T, A}: a.L,
-	Codon{C, T, G}: a.L,
-
-	Codon{A, T, T}: a.I,
-	Codon{A, T, C}: a.I,
-	Codon{A, T, A}: a.I,
-	Codon{A, T, G}: a.M,
-
-	Codon{G, T, T}: a.V,
-	Codon{G, T, C}: a.V,
-	Codon{G, T, A}: a.V,
-	Codon{G, T, G}: a.V,
-
-	Codon{T, C, T}: a.S,
-	Codon{T, C, C}: a.S,
-	Codon{T, C, A}: a.S,
-	Codon{T, C, G}: a.S,
-
-	Codon{C, C, T}: a.P,
-	Codon{C, C, C}: a.P,
-	Codon{C, C, A}: a.P,
-	Codon{C, C, G}: a.P,
-
-	Codon{A, C, T}: a.T,
-	Codon{A, C, C}: a.T,
-	Codon{A, C, A}: a.T,
-	Codon{A, C, G}: a.T,
-
-	Codon{G, C, T}: a.A,
-	Codon{G, C, C}: a.A,
-	Codon{G, C, A}: a.A,
-	Codon{G, C, G}: a.A,
-
-	Codon{T, A, T}: a.Y,
-	Codon{T, A, C}: a.Y,
-
-	Codon{C, A, T}: a.H,
-	Codon{C, A, C}: a.H,
-	Codon{C, A, A}: a.Q,
-	Codon{C, A, G}: a.Q,
-
-	Codon{A, A, T}: a.N,
-	Codon{A, A, C}: a.N,
-	Codon{A, A, A}: a.K,
-	Codon{A, A, G}: a.K,
-
-	Codon{G, A, T}: a.D,
-	Codon{G, A, C}: a.D,
-	Codon{G, A, A}: a.E,
-	Codon{G, A, G}: a.E,
-
-	Codon{T, G, T}: a.C,
-	Codon{T, G, C}: a.C,
-	Codon{T, G, G}: a.W,
-
-	Codon{C, G, T}: a.R,
-	Codon{C, G, C}: a.R,
-	Codon{C, G, A}: a.R,
-	Codon{C, G, G}: a.R,
-
-	Codon{A, G, T}: a.S,
-	Codon{A, G, C}: a.S,
-	Codon{A, G, A}: a.R,
-	Codon{A, G, G}: a.R,
-
-	Codon{G, G, T}: a.G,
-	Codon{G, G, C}: a.G,
-	Codon{G, G, A}: a.G,
-	Codon{G, G, G}: a.G,
-}
-
-var aminoAcidSearchMatrix = [a.NumAminoAcids][3][NumNucleicAcids][]Codon{}
-
-func init() {
-	for codon, aa := range CodonToAminoAcidTable {
-		for idx, na := range codon.GetNucleicAcids() {
-			codons := aminoAcidSearchMatrix[aa][idx][na]
-			if codons == nil {
-				codons = make([]Codon, 0, 4)
-			}
-			codons = append(codons, codon)
-			aminoAcidSearchMatrix[aa][idx][na] = codons
-		}
-	}
-}
+// CodonToAminoAcidTable holds the amino acids of the unambiguous codons
+// in the standard genetic code, leaving out the stop codons.
+var CodonToAminoAcidTable = StandardCode.CodonTable()
 
 func hasCommonCodon(codons0 []Codon, codons1 []Codon) bool {
 	for _, codon0 := range codons0 {
@@ -130,7 +32,17 @@ var twoNAsCases = [3][2]int{
 	[2]int{0, 2},
 }
 
+// FindBestMatch works like GeneticCode.FindBestMatch in the standard
+// genetic code.
 func FindBestMatch(nas []NucleicAcid, aa a.AminoAcid) Codon {
+	return StandardCode.FindBestMatch(nas, aa)
+}
+
+// FindBestMatch returns the codon made of nas, which miss one or two
+// nucleotides when they are fewer than three, that best matches aa:
+// the missing nucleotides are filled with N where the codon can still
+// code aa.
+func (self *GeneticCode) FindBestMatch(nas []NucleicAcid, aa a.AminoAcid) Codon {
 	var (
 		codon, partialCodon *Codon
 		lenNAs              = len(nas)
@@ -142,10 +54,10 @@ func FindBestMatch(nas []NucleicAcid, aa a.AminoAcid) Codon {
 		for _, p := range twoNAsCases {
 			codons0, codons1 := []Codon{}, []Codon{}
 			for _, na := range GetUnambiguousNucleicAcids(nas[0]) {
-				codons0 = append(codons0, aminoAcidSearchMatrix[aa][p[0]][na]...)
+				codons0 = append(codons0, self.searchMatrix[aa][p[0]][na]...)
 			}
 			for _, na := range GetUnambiguousNucleicAcids(nas[1]) {
-				codons1 = append(codons1, aminoAcidSearchMatrix[aa][p[1]][na]...)
+				codons1 = append(codons1, self.searchMatrix[aa][p[1]][na]...)
 			}
 			pNAs := [3]NucleicAcid{N, N, N}
 			pNAs[p[0]] = nas[0]
@@ -163,7 +75,7 @@ func FindBestMatch(nas []NucleicAcid, aa a.AminoAcid) Codon {
 		for i := 0; i < 3; i++ {
 			codons := []Codon{}
 			for _, na := range GetUnambiguousNucleicAcids(nas[0]) {
-				codons = append(codons, aminoAcidSearchMatrix[aa][i][na]...)
+				codons = append(codons, self.searchMatrix[aa][i][na]...)
 			}
 			if len(codons) > 0 {
 				pNAs := [3]NucleicAcid{N, N, N}
@@ -179,8 +91,10 @@ func FindBestMatch(nas []NucleicAcid, aa a.AminoAcid) Codon {
 	return *codon
 }
 
+// IsStopCodon tells if the codon is a stop codon in the standard
+// genetic code.
 func (self *Codon) IsStopCodon() bool {
-	return stopCodons[*self]
+	return StandardCode.IsStopCodon(*self)
 }
 
 func (self *Codon) GetNucleicAcids() [3]NucleicAcid {
@@ -192,30 +106,7 @@ func (self *Codon) GetNucleicAcids() [3]NucleicAcid {
 }
 
 func (self *Codon) ToAminoAcidsText() string {
-	aas := make([]a.AminoAcid, 0, 1)
-	hasStopCodon := false
-
-UnambiguousCodonsLoop:
-	for _, ucodon := range self.GetUnambiguousCodons() {
-		if ucodon.IsStopCodon() {
-			hasStopCodon = true
-			continue
-		}
-		aa := ucodon.ToAminoAcidUnsafe()
-		if len(aas) > 0 {
-			for _, knownAA := range aas {
-				if knownAA == aa {
-					continue UnambiguousCodonsLoop
-				}
-			}
-		}
-		aas = append(aas, aa)
-	}
-	text := a.WriteString(aas)
-	if hasStopCodon {
-		text += "*"
-	}
-	return text
+	return StandardCode.ToAminoAcidsText(*self)
 }
 
 func (self *Codon) IsAmbiguous() bool {
@@ -226,7 +117,7 @@ func (self *Codon) IsAmbiguous() bool {
 
 // NOTE: This method doesn't check if self is stop codon or ambiguous
 func (self *Codon) ToAminoAcidUnsafe() a.AminoAcid {
-	return CodonToAminoAcidTable[*self]
+	return StandardCode.ToAminoAcidUnsafe(*self)
 }
 
 func (self *Codon) GetUnambiguousCodons() []Codon {
@@ -0,0 +1,233 @@
+package codon
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	. "github.com/hivdb/nucamino/types/nucleic"
+	"sort"
+)
+
+// A GeneticCode translates codons to amino acids, such as one of the
+// translation tables of the NCBI. Its stop codons include the ambiguous
+// codons, such as TAR in the standard code, whose unambiguous codons are
+// all stop codons.
+//
+// In the tables 27, 28 and 31 some codons code an amino acid, but stop
+// the translation at the end of a gene. The aligner can't tell the end
+// of a gene from the codons, so they always code the amino acid.
+type GeneticCode struct {
+	// The translation table ID of the NCBI
+	ID   int
+	Name string
+	// The amino acids of the unambiguous codons which aren't stop codons
+	aminoAcids [NumNucleicAcids][NumNucleicAcids][NumNucleicAcids]a.AminoAcid
+	stopCodons [NumNucleicAcids][NumNucleicAcids][NumNucleicAcids]bool
+	// The codons of each amino acid having a nucleotide at a position,
+	// which FindBestMatch searches
+	searchMatrix [a.NumAminoAcids][3][NumNucleicAcids][]Codon
+}
+
+// The order of the nucleotides of the codons in the translation tables
+// of the NCBI, from TTT to GGG
+var ncbiBases = [4]NucleicAcid{T, C, A, G}
+
+const standardAminoAcids = "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"
+
+// StandardCode is the genetic code of profiles which don't declare one.
+var StandardCode = newGeneticCode(1, "Standard", standardAminoAcids)
+
+var builtinGeneticCodes = map[int]*GeneticCode{
+	1: StandardCode,
+	2: newGeneticCode(
+		2, "Vertebrate Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSS**VVVVAAAADDEEGGGG"),
+	3: newGeneticCode(
+		3, "Yeast Mitochondrial",
+		"FFLLSSSSYY**CCWWTTTTPPPPHHQQRRRRIIMMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	4: newGeneticCode(
+		4, "Mold, Protozoan, and Coelenterate Mitochondrial and Mycoplasma/Spiroplasma",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	5: newGeneticCode(
+		5, "Invertebrate Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSSSVVVVAAAADDEEGGGG"),
+	6: newGeneticCode(
+		6, "Ciliate, Dasycladacean and Hexamita Nuclear",
+		"FFLLSSSSYYQQCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	9: newGeneticCode(
+		9, "Echinoderm and Flatworm Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG"),
+	10: newGeneticCode(
+		10, "Euplotid Nuclear",
+		"FFLLSSSSYY**CCCWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	11: newGeneticCode(
+		11, "Bacterial, Archaeal and Plant Plastid", standardAminoAcids),
+	12: newGeneticCode(
+		12, "Alternative Yeast Nuclear",
+		"FFLLSSSSYY**CC*WLLLSPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	13: newGeneticCode(
+		13, "Ascidian Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSGGVVVVAAAADDEEGGGG"),
+	14: newGeneticCode(
+		14, "Alternative Flatworm Mitochondrial",
+		"FFLLSSSSYYY*CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG"),
+	16: newGeneticCode(
+		16, "Chlorophycean Mitochondrial",
+		"FFLLSSSSYY*LCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	21: newGeneticCode(
+		21, "Trematode Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNNKSSSSVVVVAAAADDEEGGGG"),
+	22: newGeneticCode(
+		22, "Scenedesmus obliquus Mitochondrial",
+		"FFLLSS*SYY*LCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	23: newGeneticCode(
+		23, "Thraustochytrium Mitochondrial",
+		"FF*LSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	24: newGeneticCode(
+		24, "Rhabdopleuridae Mitochondrial",
+		"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSSKVVVVAAAADDEEGGGG"),
+	25: newGeneticCode(
+		25, "Candidate Division SR1 and Gracilibacteria",
+		"FFLLSSSSYY**CCGWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	26: newGeneticCode(
+		26, "Pachysolen tannophilus Nuclear",
+		"FFLLSSSSYY**CC*WLLLAPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	27: newGeneticCode(
+		27, "Karyorelict Nuclear",
+		"FFLLSSSSYYQQCCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	28: newGeneticCode(
+		28, "Condylostoma Nuclear",
+		"FFLLSSSSYYQQCCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	29: newGeneticCode(
+		29, "Mesodinium Nuclear",
+		"FFLLSSSSYYYYCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	30: newGeneticCode(
+		30, "Peritrich Nuclear",
+		"FFLLSSSSYYEECC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	31: newGeneticCode(
+		31, "Blastocrithidia Nuclear",
+		"FFLLSSSSYYEECCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	32: newGeneticCode(
+		32, "Balanophoraceae Plastid",
+		"FFLLSSSSYY*WCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"),
+	33: newGeneticCode(
+		33, "Cephalodiscidae Mitochondrial",
+		"FFLLSSSSYYY*CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSSKVVVVAAAADDEEGGGG"),
+}
+
+// newGeneticCode builds the genetic code of the 64 amino acids aas,
+// written like in the translation tables of the NCBI: the amino acids
+// (or * for stop codons) of the codons from TTT to GGG, with the bases
+// in the order T, C, A and G.
+func newGeneticCode(id int, name string, aas string) *GeneticCode {
+	code := &GeneticCode{ID: id, Name: name}
+	isStop := make(map[Codon]bool)
+	for idx, char := range aas {
+		codon := Codon{ncbiBases[idx/16], ncbiBases[idx/4%4], ncbiBases[idx%4]}
+		if char == '*' {
+			isStop[codon] = true
+			continue
+		}
+		aa := a.ReadString(string(char))[0]
+		code.aminoAcids[codon.Base1][codon.Base2][codon.Base3] = aa
+		for pos, na := range codon.GetNucleicAcids() {
+			code.searchMatrix[aa][pos][na] = append(code.searchMatrix[aa][pos][na], codon)
+		}
+	}
+	for _, na1 := range NucleicAcids {
+		for _, na2 := range NucleicAcids {
+			for _, na3 := range NucleicAcids {
+				codon := Codon{na1, na2, na3}
+				allStops := true
+				for _, ucodon := range codon.GetUnambiguousCodons() {
+					allStops = allStops && isStop[ucodon]
+				}
+				code.stopCodons[na1][na2][na3] = allStops
+			}
+		}
+	}
+	return code
+}
+
+// GetGeneticCode returns the built-in genetic code of the NCBI
+// translation table id, if there is one.
+func GetGeneticCode(id int) (*GeneticCode, bool) {
+	code, found := builtinGeneticCodes[id]
+	return code, found
+}
+
+// GeneticCodeIDs returns the IDs of the built-in genetic codes in
+// ascending order.
+func GeneticCodeIDs() []int {
+	ids := make([]int, 0, len(builtinGeneticCodes))
+	for id := range builtinGeneticCodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// LoadGeneticCode works like GetGeneticCode, but reports unknown IDs as
+// an error.
+func LoadGeneticCode(id int) (*GeneticCode, error) {
+	if code, found := GetGeneticCode(id); found {
+		return code, nil
+	}
+	return nil, fmt.Errorf(
+		"Unknown genetic code %d (expecting one of the NCBI translation tables %v)",
+		id, GeneticCodeIDs())
+}
+
+// IsStopCodon tells if codon, which may be ambiguous, can only be a stop
+// codon.
+func (self *GeneticCode) IsStopCodon(codon Codon) bool {
+	return self.stopCodons[codon.Base1][codon.Base2][codon.Base3]
+}
+
+// NOTE: This method doesn't check if codon is stop codon or ambiguous
+func (self *GeneticCode) ToAminoAcidUnsafe(codon Codon) a.AminoAcid {
+	return self.aminoAcids[codon.Base1][codon.Base2][codon.Base3]
+}
+
+// ToAminoAcidsText returns the amino acids which codon may code, followed
+// by * if it may be a stop codon.
+func (self *GeneticCode) ToAminoAcidsText(codon Codon) string {
+	aas := make([]a.AminoAcid, 0, 1)
+	hasStopCodon := false
+
+UnambiguousCodonsLoop:
+	for _, ucodon := range codon.GetUnambiguousCodons() {
+		if self.IsStopCodon(ucodon) {
+			hasStopCodon = true
+			continue
+		}
+		aa := self.ToAminoAcidUnsafe(ucodon)
+		for _, knownAA := range aas {
+			if knownAA == aa {
+				continue UnambiguousCodonsLoop
+			}
+		}
+		aas = append(aas, aa)
+	}
+	text := a.WriteString(aas)
+	if hasStopCodon {
+		text += "*"
+	}
+	return text
+}
+
+// CodonTable returns the amino acids of the unambiguous codons which
+// aren't stop codons.
+func (self *GeneticCode) CodonTable() map[Codon]a.AminoAcid {
+	table := make(map[Codon]a.AminoAcid)
+	for _, na1 := range ncbiBases {
+		for _, na2 := range ncbiBases {
+			for _, na3 := range ncbiBases {
+				codon := Codon{na1, na2, na3}
+				if !self.IsStopCodon(codon) {
+					table[codon] = self.ToAminoAcidUnsafe(codon)
+				}
+			}
+		}
+	}
+	return table
+}
@@ -0,0 +1,163 @@
+package codon
+
+import (
+	a "github.com/hivdb/nucamino/types/amino"
+	. "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+func TestGetGeneticCode(t *testing.T) {
+	code, found := GetGeneticCode(1)
+	if !found || code != StandardCode {
+		t.Errorf(MSG_NOT_EQUAL, StandardCode, code)
+	}
+	if _, found := GetGeneticCode(7); found {
+		t.Errorf(MSG_NOT_EQUAL, false, found)
+	}
+	if _, err := LoadGeneticCode(7); err == nil {
+		t.Errorf("Expected error on unknown genetic code 7")
+	}
+	ids := GeneticCodeIDs()
+	expect := []int{
+		1, 2, 3, 4, 5, 6, 9, 10, 11, 12, 13, 14, 16, 21, 22, 23, 24, 25, 26,
+		27, 28, 29, 30, 31, 32, 33}
+	if !reflect.DeepEqual(expect, ids) {
+		t.Errorf(MSG_NOT_EQUAL, expect, ids)
+	}
+}
+
+func TestStandardCodonTable(t *testing.T) {
+	if len(CodonToAminoAcidTable) != 61 {
+		t.Errorf(MSG_NOT_EQUAL, 61, len(CodonToAminoAcidTable))
+	}
+	bacterial, _ := GetGeneticCode(11)
+	if !reflect.DeepEqual(CodonToAminoAcidTable, bacterial.CodonTable()) {
+		t.Errorf(MSG_NOT_EQUAL, CodonToAminoAcidTable, bacterial.CodonTable())
+	}
+	for _, codon := range []Codon{{A, T, G}, {T, G, G}, {A, G, A}, {C, T, G}} {
+		result := StandardCode.ToAminoAcidUnsafe(codon)
+		if expect := CodonToAminoAcidTable[codon]; result != expect {
+			t.Errorf(MSG_NOT_EQUAL, expect, result)
+		}
+	}
+}
+
+func TestAlternativeGeneticCodes(t *testing.T) {
+	mito, _ := GetGeneticCode(2)
+	if mito.IsStopCodon(Codon{T, G, A}) {
+		t.Errorf(MSG_NOT_EQUAL, false, true)
+	}
+	if result := mito.ToAminoAcidUnsafe(Codon{T, G, A}); result != a.W {
+		t.Errorf(MSG_NOT_EQUAL, a.W, result)
+	}
+	if result := mito.ToAminoAcidUnsafe(Codon{A, T, A}); result != a.M {
+		t.Errorf(MSG_NOT_EQUAL, a.M, result)
+	}
+	for _, codon := range []Codon{{A, G, A}, {A, G, G}, {A, G, R}} {
+		if !mito.IsStopCodon(codon) {
+			t.Errorf(MSG_NOT_EQUAL, true, false)
+		}
+	}
+	if result := mito.ToAminoAcidsText(Codon{T, G, R}); result != "W" {
+		t.Errorf(MSG_NOT_EQUAL, "W", result)
+	}
+	if result := StandardCode.ToAminoAcidsText(Codon{T, G, R}); result != "W*" {
+		t.Errorf(MSG_NOT_EQUAL, "W*", result)
+	}
+	ciliate, _ := GetGeneticCode(6)
+	if ciliate.IsStopCodon(Codon{T, A, R}) {
+		t.Errorf(MSG_NOT_EQUAL, false, true)
+	}
+	if result := ciliate.ToAminoAcidsText(Codon{T, A, R}); result != "Q" {
+		t.Errorf(MSG_NOT_EQUAL, "Q", result)
+	}
+}
+
+func TestOtherGeneticCodes(t *testing.T) {
+	for _, cell := range []struct {
+		id     int
+		codon  Codon
+		expect string
+	}{
+		{16, Codon{T, A, G}, "L"},
+		{16, Codon{T, A, A}, "*"},
+		{22, Codon{T, C, A}, "*"},
+		{23, Codon{T, T, A}, "*"},
+		{24, Codon{A, G, G}, "K"},
+		{25, Codon{T, G, A}, "G"},
+		{26, Codon{C, T, G}, "A"},
+		{27, Codon{T, G, A}, "W"},
+		{28, Codon{T, A, R}, "Q"},
+		{29, Codon{T, A, R}, "Y"},
+		{30, Codon{T, A, R}, "E"},
+		{31, Codon{T, A, A}, "E"},
+		{32, Codon{T, A, G}, "W"},
+		{32, Codon{T, R, A}, "*"},
+		{33, Codon{T, A, A}, "Y"},
+		{33, Codon{T, A, G}, "*"},
+	} {
+		code, found := GetGeneticCode(cell.id)
+		if !found {
+			t.Errorf("Expected the genetic code %d", cell.id)
+			continue
+		}
+		if result := code.ToAminoAcidsText(cell.codon); result != cell.expect {
+			t.Errorf(MSG_NOT_EQUAL, cell.expect, result)
+		}
+	}
+}
+
+func TestAmbiguousStopCodons(t *testing.T) {
+	var stops []Codon
+	for _, na1 := range NucleicAcids {
+		for _, na2 := range NucleicAcids {
+			for _, na3 := range NucleicAcids {
+				codon := Codon{na1, na2, na3}
+				if StandardCode.IsStopCodon(codon) {
+					stops = append(stops, codon)
+				}
+			}
+		}
+	}
+	// the standard code has only the two ambiguous stop codons TAR
+	// and TRA
+	expect := []Codon{{T, A, A}, {T, A, G}, {T, A, R}, {T, G, A}, {T, R, A}}
+	if !reflect.DeepEqual(expect, stops) {
+		t.Errorf(MSG_NOT_EQUAL, expect, stops)
+	}
+	for _, id := range GeneticCodeIDs() {
+		code, _ := GetGeneticCode(id)
+		for _, na1 := range NucleicAcids {
+			for _, na2 := range NucleicAcids {
+				for _, na3 := range NucleicAcids {
+					codon := Codon{na1, na2, na3}
+					allStops := true
+					for _, ucodon := range codon.GetUnambiguousCodons() {
+						allStops = allStops && code.IsStopCodon(ucodon)
+					}
+					if code.IsStopCodon(codon) != allStops {
+						t.Errorf("%v of genetic code %d: "+MSG_NOT_EQUAL,
+							codon.ToString(), id, allStops, code.IsStopCodon(codon))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFindBestMatchInGeneticCode(t *testing.T) {
+	// AGA and AGG code R in the standard code
+	result := StandardCode.FindBestMatch([]NucleicAcid{A, G}, a.R)
+	expect := Codon{A, G, N}
+	if !reflect.DeepEqual(expect, result) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	// but are stop codons in the vertebrate mitochondrial code
+	mito, _ := GetGeneticCode(2)
+	result = mito.FindBestMatch([]NucleicAcid{A, G}, a.R)
+	expect = Codon{A, N, G}
+	if !reflect.DeepEqual(expect, result) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
@@ -21,6 +21,9 @@ type FrameShift struct {
 	IsInsertion      bool
 	IsDeletion       bool
 	GapLength        int
+	// The frameshift is declared by the profile, such as a programmed
+	// ribosomal frameshift, rather than a defect of the sequence
+	IsExpected bool
 }
 
 func New(
@@ -66,6 +69,29 @@ func MakeFrameShift(position, naPosition int, allNAs []n.NucleicAcid) *FrameShif
 	return frameshift
 }
 
+// MakeFrameShiftWith works like MakeFrameShift, but the gene is
+// expected to shift its reading frame by expectedShift nucleotides at
+// position (see alignmentprofile.ExpectedFrameShift), and a frameshift
+// of that size is marked as expected.
+func MakeFrameShiftWith(
+	position, naPosition int, allNAs []n.NucleicAcid,
+	expectedShift int) *FrameShift {
+	frameshift := MakeFrameShift(position, naPosition, allNAs)
+	if frameshift != nil && expectedShift != 0 {
+		frameshift.IsExpected = frameshift.Shift() == expectedShift
+	}
+	return frameshift
+}
+
+// Shift returns the number of nucleotides the frameshift moves the
+// reading frame by: positive for insertions, negative for deletions.
+func (self *FrameShift) Shift() int {
+	if self.IsInsertion {
+		return self.GapLength
+	}
+	return -self.GapLength
+}
+
 func (self *FrameShift) ToString() string {
 	indel := "del"
 	if self.IsInsertion {
@@ -21,6 +21,7 @@ func TestNew(t *testing.T) {
 		false,
 		true,
 		2,
+		false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -35,6 +36,7 @@ func TestNew(t *testing.T) {
 		true,
 		false,
 		2,
+		false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -56,6 +58,7 @@ func TestMakeFrameShift(t *testing.T) {
 		true,
 		false,
 		1,
+		false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -70,12 +73,35 @@ func TestMakeFrameShift(t *testing.T) {
 		false,
 		true,
 		1,
+		false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
 
+func TestMakeFrameShiftWith(t *testing.T) {
+	result := MakeFrameShiftWith(155, 677, []n.NucleicAcid{n.A, n.R}, -1)
+	if !result.IsExpected {
+		t.Errorf(MSG_NOT_EQUAL, true, result.IsExpected)
+	}
+	result = MakeFrameShiftWith(155, 677, []n.NucleicAcid{n.A}, -1)
+	if result.IsExpected {
+		t.Errorf(MSG_NOT_EQUAL, false, result.IsExpected)
+	}
+	result = MakeFrameShiftWith(155, 677, []n.NucleicAcid{n.A, n.R, n.G, n.T}, -1)
+	if result.IsExpected {
+		t.Errorf(MSG_NOT_EQUAL, false, result.IsExpected)
+	}
+	result = MakeFrameShiftWith(155, 677, []n.NucleicAcid{n.A, n.R, n.G, n.T}, 1)
+	if !result.IsExpected || result.Shift() != 1 {
+		t.Errorf(MSG_NOT_EQUAL, 1, result.Shift())
+	}
+	if result = MakeFrameShiftWith(155, 677, []n.NucleicAcid{n.A, n.R, n.G}, 1); result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+}
+
 func TestToString(t *testing.T) {
 	result := MakeFrameShift(155, 677, []n.NucleicAcid{n.A, n.R, n.G, n.T}).ToString()
 	expect := "155ins1bp_T"
@@ -0,0 +1,71 @@
+package mutation
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// A CodonDifference is an aligned codon which differs from the codon of
+// the reference coding sequence, whether it changes the amino acid or
+// not.
+type CodonDifference struct {
+	Position           int
+	NAPosition         int
+	CodonText          string
+	ReferenceCodonText string
+	AminoAcidText      string
+	ReferenceText      string
+	IsSynonymous       bool
+	// Positions (1 to 3) of the differing nucleotides in the codon
+	ChangedBases []int
+}
+
+// MakeCodonDifference compares the codon made of the first three of nas
+// to refCodon, which codes ref in the genetic code. It returns nil when
+// the codons are identical or nas miss nucleotides.
+func MakeCodonDifference(
+	position, naPosition int,
+	nas []n.NucleicAcid, refCodon c.Codon, ref a.AminoAcid,
+	code *c.GeneticCode) *CodonDifference {
+	if len(nas) < 3 {
+		return nil
+	}
+	var (
+		codon        = c.Codon{Base1: nas[0], Base2: nas[1], Base3: nas[2]}
+		codonBases   = codon.GetNucleicAcids()
+		refBases     = refCodon.GetNucleicAcids()
+		changedBases []int
+	)
+	for idx := range codonBases {
+		if codonBases[idx] != refBases[idx] {
+			changedBases = append(changedBases, idx+1)
+		}
+	}
+	if len(changedBases) == 0 {
+		return nil
+	}
+	isSynonymous := true
+	for _, ucodon := range codon.GetUnambiguousCodons() {
+		if code.IsStopCodon(ucodon) || code.ToAminoAcidUnsafe(ucodon) != ref {
+			isSynonymous = false
+		}
+	}
+	return &CodonDifference{
+		Position:           position,
+		NAPosition:         naPosition,
+		CodonText:          codon.ToString(),
+		ReferenceCodonText: refCodon.ToString(),
+		AminoAcidText:      code.ToAminoAcidsText(codon),
+		ReferenceText:      a.ToString(ref),
+		IsSynonymous:       isSynonymous,
+		ChangedBases:       changedBases,
+	}
+}
+
+func (self *CodonDifference) ToString() string {
+	return fmt.Sprintf(
+		"%s%d%s:%s>%s", self.ReferenceText, self.Position,
+		self.AminoAcidText, self.ReferenceCodonText, self.CodonText)
+}
@@ -0,0 +1,43 @@
+package mutation
+
+import (
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"testing"
+)
+
+func TestMakeCodonDifference(t *testing.T) {
+	refCodon := c.Codon{n.A, n.A, n.A}
+	result := MakeCodonDifference(103, 307, []n.NucleicAcid{n.A, n.A, n.G}, refCodon, a.K, c.StandardCode)
+	expect := &CodonDifference{103, 307, "AAG", "AAA", "K", "K", true, []int{3}}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeCodonDifference(103, 307, []n.NucleicAcid{n.A, n.A, n.C, n.G}, refCodon, a.K, c.StandardCode)
+	expect = &CodonDifference{103, 307, "AAC", "AAA", "N", "K", false, []int{3}}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeCodonDifference(103, 307, []n.NucleicAcid{n.G, n.A, n.R}, refCodon, a.K, c.StandardCode)
+	expect = &CodonDifference{103, 307, "GAR", "AAA", "E", "K", false, []int{1, 3}}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	if result := MakeCodonDifference(103, 307, []n.NucleicAcid{n.A, n.A, n.A}, refCodon, a.K, c.StandardCode); result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+	if result := MakeCodonDifference(103, 307, []n.NucleicAcid{n.A, n.G}, refCodon, a.K, c.StandardCode); result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+}
+
+func TestCodonDifferenceToString(t *testing.T) {
+	result := MakeCodonDifference(
+		103, 307, []n.NucleicAcid{n.A, n.A, n.G}, c.Codon{n.A, n.A, n.A}, a.K, c.StandardCode).ToString()
+	expect := "K103K:AAA>AAG"
+	if result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
@@ -23,11 +23,21 @@ type Mutation struct {
 	InsertedCodonsText     string
 	InsertedAminoAcidsText string
 	insertedCodons         []c.Codon
+	// Set when the codon includes nucleotides of a low quality, such
+	// as the ones masked in a FASTQ read
+	IsLowQuality bool `json:",omitempty"`
 }
 
 func New(
 	position, naPosition int, codon c.Codon,
 	reference a.AminoAcid, isPartial bool, control string) *Mutation {
+	return newMutation(c.StandardCode, position, naPosition, codon, reference, isPartial, control)
+}
+
+// newMutation works like New, translating codon with the genetic code.
+func newMutation(
+	code *c.GeneticCode, position, naPosition int, codon c.Codon,
+	reference a.AminoAcid, isPartial bool, control string) *Mutation {
 	codonText := []rune(codon.ToString())
 	if isPartial {
 		for pos, char := range control {
@@ -41,7 +51,7 @@ func New(
 		NAPosition:    naPosition,
 		codon:         &codon,
 		CodonText:     string(codonText),
-		AminoAcidText: codon.ToAminoAcidsText(),
+		AminoAcidText: code.ToAminoAcidsText(codon),
 		reference:     reference,
 		ReferenceText: a.ToString(reference),
 		IsPartial:     isPartial,
@@ -53,6 +63,16 @@ func NewInsertion(
 	position, naPosition int, codon c.Codon,
 	reference a.AminoAcid,
 	insertedCodons []c.Codon, control string) *Mutation {
+	return newInsertion(
+		c.StandardCode, position, naPosition, codon, reference, insertedCodons, control)
+}
+
+// newInsertion works like NewInsertion, translating the codons with the
+// genetic code.
+func newInsertion(
+	code *c.GeneticCode, position, naPosition int, codon c.Codon,
+	reference a.AminoAcid,
+	insertedCodons []c.Codon, control string) *Mutation {
 
 	var (
 		insertedCodonsText     string
@@ -60,7 +80,7 @@ func NewInsertion(
 	)
 	for _, insCodon := range insertedCodons {
 		insertedCodonsText += insCodon.ToString()
-		insertedAAs := insCodon.ToAminoAcidsText()
+		insertedAAs := code.ToAminoAcidsText(insCodon)
 		if len(insertedAAs) > 1 {
 			insertedAminoAcidsText += "[" + insertedAAs + "]"
 		} else {
@@ -68,7 +88,7 @@ func NewInsertion(
 		}
 	}
 
-	mutation := New(position, naPosition, codon, reference, false, control)
+	mutation := newMutation(code, position, naPosition, codon, reference, false, control)
 	mutation.IsInsertion = true
 	mutation.insertedCodons = insertedCodons
 	mutation.InsertedCodonsText = insertedCodonsText
@@ -92,9 +112,43 @@ func NewDeletion(
 	}
 }
 
+// A CodonMatcher tells if codon, which may be ambiguous, matches the
+// reference amino acid ref at position.
+type CodonMatcher func(position int, codon c.Codon, ref a.AminoAcid) bool
+
+// AllMatched is the CodonMatcher used by MakeMutation: a codon matches
+// if all of its unambiguous codons code ref.
+var AllMatched = AllMatchedIn(c.StandardCode)
+
+// AllMatchedIn returns the CodonMatcher which works like AllMatched in
+// the genetic code.
+func AllMatchedIn(code *c.GeneticCode) CodonMatcher {
+	return func(position int, codon c.Codon, ref a.AminoAcid) bool {
+		allMatched := true
+		for _, ucodon := range codon.GetUnambiguousCodons() {
+			if code.IsStopCodon(ucodon) {
+				allMatched = false
+			} else {
+				allMatched = allMatched && code.ToAminoAcidUnsafe(ucodon) == ref
+			}
+		}
+		return allMatched
+	}
+}
+
 func MakeMutation(
 	position, naPosition int,
 	nas []n.NucleicAcid, ref a.AminoAcid) *Mutation {
+	return MakeMutationWith(position, naPosition, nas, ref, c.StandardCode, AllMatched)
+}
+
+// MakeMutationWith works like MakeMutation, but translates nas with the
+// genetic code, and a codon of nas is only reported as a substitution
+// if matches tells it doesn't match ref.
+func MakeMutationWith(
+	position, naPosition int,
+	nas []n.NucleicAcid, ref a.AminoAcid,
+	code *c.GeneticCode, matches CodonMatcher) *Mutation {
 	lenNAs := len(nas)
 	var (
 		control  string
@@ -103,14 +157,7 @@ func MakeMutation(
 	if lenNAs >= 3 {
 		// maybe substitution
 		codon := c.Codon{nas[0], nas[1], nas[2]}
-		allMatched := true
-		for _, ucodon := range codon.GetUnambiguousCodons() {
-			if ucodon.IsStopCodon() {
-				allMatched = false
-			} else {
-				allMatched = allMatched && ucodon.ToAminoAcidUnsafe() == ref
-			}
-		}
+		allMatched := matches(position, codon, ref)
 		if allMatched {
 			control = ":::"
 		} else {
@@ -125,15 +172,15 @@ func MakeMutation(
 				insertedCodons[idx] = c.Codon{nas[p], nas[p+1], nas[p+2]}
 				control += "+++"
 			}
-			mutation = NewInsertion(
-				position, naPosition, codon, ref, insertedCodons, control)
+			mutation = newInsertion(
+				code, position, naPosition, codon, ref, insertedCodons, control)
 		} else if !allMatched {
-			mutation = New(
-				position, naPosition, codon, ref, false, control)
+			mutation = newMutation(
+				code, position, naPosition, codon, ref, false, control)
 		}
 	} else if lenNAs > 0 {
 		// codon missed 1 or 2 NAs
-		codon := c.FindBestMatch(nas, ref)
+		codon := code.FindBestMatch(nas, ref)
 		allNs := true
 		for _, na := range codon.GetNucleicAcids() {
 			if na == n.N {
@@ -146,7 +193,7 @@ func MakeMutation(
 		if allNs {
 			control = strings.Repeat(".", lenNAs) + "-"
 		}
-		mutation = New(position, naPosition, codon, ref, true, control)
+		mutation = newMutation(code, position, naPosition, codon, ref, true, control)
 	} else if lenNAs == 0 {
 		// deletion
 		mutation = NewDeletion(position, naPosition, ref)
@@ -154,6 +201,41 @@ func MakeMutation(
 	return mutation
 }
 
+// MakeAminoAcidMutation is the counterpart of MakeMutation for protein
+// alignments: aas are the query amino acids aligned to ref, followed by
+// inserted ones if any, and naPosition is the position of the first of
+// them in the query. The mutation has no codon text, and its control
+// has one character per amino acid.
+func MakeAminoAcidMutation(
+	position, naPosition int,
+	aas []a.AminoAcid, ref a.AminoAcid) *Mutation {
+	if len(aas) == 0 {
+		mutation := NewDeletion(position, naPosition, ref)
+		mutation.Control = "-"
+		return mutation
+	}
+	if len(aas) == 1 && aas[0] == ref {
+		return nil
+	}
+	control := "."
+	if aas[0] == ref {
+		control = ":"
+	}
+	mutation := &Mutation{
+		Position:      position,
+		NAPosition:    naPosition,
+		AminoAcidText: a.ToString(aas[0]),
+		reference:     ref,
+		ReferenceText: a.ToString(ref),
+		Control:       control + strings.Repeat("+", len(aas)-1),
+	}
+	if len(aas) > 1 {
+		mutation.IsInsertion = true
+		mutation.InsertedAminoAcidsText = a.WriteString(aas[1:])
+	}
+	return mutation
+}
+
 func (self *Mutation) GetInsertedCodons() []c.Codon { return self.insertedCodons }
 
 func (self *Mutation) ToString() string {
@@ -162,7 +244,9 @@ func (self *Mutation) ToString() string {
 	if self.IsDeletion {
 		r += "-"
 	} else {
-		nas += ":" + self.CodonText
+		if self.codon != nil {
+			nas += ":" + self.CodonText
+		}
 		if self.IsPartial {
 			r += "X" // mutation contains del gap doesn't get displayed
 		} else {
@@ -170,7 +254,9 @@ func (self *Mutation) ToString() string {
 		}
 		if self.IsInsertion {
 			r += "_" + self.InsertedAminoAcidsText
-			nas += "_" + self.InsertedCodonsText
+			if self.codon != nil {
+				nas += "_" + self.InsertedCodonsText
+			}
 		}
 	}
 	return r + nas
@@ -16,7 +16,7 @@ func TestMakeMutation(t *testing.T) {
 	result := MakeMutation(155, 797, []n.NucleicAcid{n.A, n.C, n.T}, a.S)
 	expect := &Mutation{
 		155, 797, "ACT", "T", &c.Codon{n.A, n.C, n.T}, "S", a.S,
-		false, false, false, "...", "", "", nil,
+		false, false, false, "...", "", "", nil, false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -28,7 +28,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.A, n.T}, a.S)
 	expect = &Mutation{
 		155, 797, "A T", "NTSI", &c.Codon{n.A, n.N, n.T}, "S", a.S,
-		false, false, true, ".-.", "", "", nil,
+		false, false, true, ".-.", "", "", nil, false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -36,7 +36,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{}, a.S)
 	expect = &Mutation{
 		155, 797, "", "", nil, "S", a.S,
-		false, true, false, "---", "", "", nil,
+		false, true, false, "---", "", "", nil, false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -44,7 +44,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.A, n.C, n.T, n.A, n.C, n.T, n.R, n.C, n.T}, a.T)
 	expect = &Mutation{
 		155, 797, "ACT", "T", &c.Codon{n.A, n.C, n.T}, "T", a.T,
-		true, false, false, ":::++++++", "ACTRCT", "T[TA]", []c.Codon{c.Codon{n.A, n.C, n.T}, c.Codon{n.R, n.C, n.T}},
+		true, false, false, ":::++++++", "ACTRCT", "T[TA]", []c.Codon{c.Codon{n.A, n.C, n.T}, c.Codon{n.R, n.C, n.T}}, false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -52,13 +52,37 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.T, n.A, n.R}, a.L)
 	expect = &Mutation{
 		155, 797, "TAR", "*", &c.Codon{n.T, n.A, n.R}, "L", a.L,
-		false, false, false, "...", "", "", nil,
+		false, false, false, "...", "", "", nil, false,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
 
+func TestMakeMutationWith(t *testing.T) {
+	anyMatched := func(position int, codon c.Codon, ref a.AminoAcid) bool {
+		for _, ucodon := range codon.GetUnambiguousCodons() {
+			if !ucodon.IsStopCodon() && ucodon.ToAminoAcidUnsafe() == ref {
+				return true
+			}
+		}
+		return false
+	}
+	// GRA is GAA (E) or GGA (G)
+	nas := []n.NucleicAcid{n.G, n.R, n.A}
+	if result := MakeMutation(155, 797, nas, a.G); result == nil || result.Control != "..." {
+		t.Errorf(MSG_NOT_EQUAL, "...", result)
+	}
+	if result := MakeMutationWith(155, 797, nas, a.G, c.StandardCode, anyMatched); result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+	nas = []n.NucleicAcid{n.G, n.R, n.A, n.A, n.A, n.A}
+	result := MakeMutationWith(155, 797, nas, a.G, c.StandardCode, anyMatched)
+	if result == nil || result.Control != ":::+++" {
+		t.Errorf(MSG_NOT_EQUAL, ":::+++", result)
+	}
+}
+
 func TestGetInsertedCodons(t *testing.T) {
 	result := MakeMutation(155, 797, []n.NucleicAcid{n.T, n.A, n.R}, a.L).GetInsertedCodons()
 	if result != nil {
@@ -88,3 +112,69 @@ func TestToString(t *testing.T) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
+
+func TestMakeAminoAcidMutation(t *testing.T) {
+	result := MakeAminoAcidMutation(103, 98, []a.AminoAcid{a.N}, a.K)
+	expect := &Mutation{
+		103, 98, "", "N", nil, "K", a.K,
+		false, false, false, ".", "", "", nil, false,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeAminoAcidMutation(103, 98, []a.AminoAcid{a.K}, a.K)
+	if result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+	result = MakeAminoAcidMutation(69, 64, []a.AminoAcid{a.T, a.S, a.G}, a.T)
+	expect = &Mutation{
+		69, 64, "", "T", nil, "T", a.T,
+		true, false, false, ":++", "", "SG", nil, false,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeAminoAcidMutation(67, 62, []a.AminoAcid{}, a.D)
+	expect = &Mutation{
+		67, 62, "", "", nil, "D", a.D,
+		false, true, false, "-", "", "", nil, false,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
+
+func TestAminoAcidMutationToString(t *testing.T) {
+	result := MakeAminoAcidMutation(103, 98, []a.AminoAcid{a.N}, a.K).ToString()
+	expect := "K103N"
+	if result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeAminoAcidMutation(69, 64, []a.AminoAcid{a.T, a.S, a.G}, a.T).ToString()
+	expect = "T69T_SG"
+	if result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
+
+func TestMakeMutationWithGeneticCode(t *testing.T) {
+	mito, _ := c.GetGeneticCode(2)
+	// TGA codes W and AGA is a stop codon in the vertebrate
+	// mitochondrial code
+	nas := []n.NucleicAcid{n.T, n.G, n.A}
+	if result := MakeMutation(155, 797, nas, a.W); result == nil || result.AminoAcidText != "*" {
+		t.Errorf(MSG_NOT_EQUAL, "*", result)
+	}
+	if result := MakeMutationWith(155, 797, nas, a.W, mito, AllMatchedIn(mito)); result != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, result)
+	}
+	nas = []n.NucleicAcid{n.A, n.G, n.A, n.T, n.G, n.A}
+	result := MakeMutationWith(155, 797, nas, a.R, mito, AllMatchedIn(mito))
+	expect := &Mutation{
+		155, 797, "AGA", "*", &c.Codon{n.A, n.G, n.A}, "R", a.R,
+		true, false, false, "...+++", "TGA", "W", []c.Codon{c.Codon{n.T, n.G, n.A}}, false,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
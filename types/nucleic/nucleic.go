@@ -85,6 +85,24 @@ var ambiguousNucleicAcids = [NumNucleicAcids][]NucleicAcid{
 	{A, C, G, T},
 }
 
+var complementNucleicAcids = [NumNucleicAcids]NucleicAcid{
+	T, // A
+	G, // C
+	C, // G
+	A, // T
+	W, // W
+	S, // S
+	K, // M
+	M, // K
+	Y, // R
+	R, // Y
+	V, // B
+	H, // D
+	D, // H
+	B, // V
+	N, // N
+}
+
 func (na NucleicAcid) ToString() string {
 	return nucleicAcidLookup[na]
 }
@@ -115,6 +133,21 @@ func WriteString(nas []NucleicAcid) string {
 	return result
 }
 
+func (self NucleicAcid) Complement() NucleicAcid {
+	return complementNucleicAcids[self]
+}
+
+// ReverseComplement returns a new slice holding the reverse complement
+// of nas; ambiguous bases are complemented by their IUPAC counterpart.
+func ReverseComplement(nas []NucleicAcid) []NucleicAcid {
+	lenNAs := len(nas)
+	result := make([]NucleicAcid, lenNAs)
+	for idx, na := range nas {
+		result[lenNAs-idx-1] = na.Complement()
+	}
+	return result
+}
+
 func GetUnambiguousNucleicAcids(na NucleicAcid) []NucleicAcid {
 	return ambiguousNucleicAcids[na]
 }
@@ -71,3 +71,23 @@ func TestGetUnambiguousNucleicAcids(t *testing.T) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
+
+func TestComplement(t *testing.T) {
+	for _, na := range NucleicAcids {
+		if na.Complement().Complement() != na {
+			t.Errorf(MSG_NOT_EQUAL, na, na.Complement().Complement())
+		}
+	}
+	if A.Complement() != T || R.Complement() != Y || B.Complement() != V {
+		t.Errorf(MSG_NOT_EQUAL, []NucleicAcid{T, Y, V},
+			[]NucleicAcid{A.Complement(), R.Complement(), B.Complement()})
+	}
+}
+
+func TestReverseComplement(t *testing.T) {
+	result := ReverseComplement(ReadString("ACGTRYKMSWBDHVN"))
+	expect := ReadString("NBDHVWSKMRYACGT")
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
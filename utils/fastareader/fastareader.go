@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
 	n "github.com/hivdb/nucamino/types/nucleic"
 	"io"
 	"strings"
@@ -12,13 +13,43 @@ import (
 type Sequence struct {
 	Name     string
 	Sequence []n.NucleicAcid
+	// Set instead of Sequence for protein sequences
+	AminoAcids []a.AminoAcid
+	// Phred quality of each nucleotide; only set for FASTQ sequences
+	Qualities []int
 }
 
-func makeSequence(name string, seqText string) Sequence {
-	return Sequence{name, n.ReadString(seqText)}
+func makeSequence(name string, seqText string) (Sequence, error) {
+	return Sequence{Name: name, Sequence: n.ReadString(seqText)}, nil
+}
+
+func makeProteinSequence(name string, seqText string) (Sequence, error) {
+	// the stop codon which ends a translated coding sequence
+	seqText = strings.TrimSuffix(strings.TrimSpace(seqText), "*")
+	aas, err := a.ReadStringStrict(seqText)
+	if err != nil {
+		return Sequence{}, fmt.Errorf("Protein sequence %q: %v", name, err)
+	}
+	return Sequence{Name: name, AminoAcids: aas}, nil
 }
 
 func ReadSequences(reader io.Reader) []Sequence {
+	// reading nucleotides never fails; unknown letters become N
+	seqs, _ := readSequences(reader, makeSequence)
+	return seqs
+}
+
+// ReadProteinSequences reads amino acid sequences. Letters other than
+// the twenty amino acids, such as gaps, stops and X, would shift the
+// positions of the residues after them if they were left out, so they
+// are reported as errors; only a final stop is allowed.
+func ReadProteinSequences(reader io.Reader) ([]Sequence, error) {
+	return readSequences(reader, makeProteinSequence)
+}
+
+func readSequences(
+	reader io.Reader,
+	makeSequence func(name string, seqText string) (Sequence, error)) ([]Sequence, error) {
 	results := make([]Sequence, 0, 20)
 	name := ""
 	var seqBuffer bytes.Buffer
@@ -30,8 +61,11 @@ func ReadSequences(reader io.Reader) []Sequence {
 			continue
 		} else if strings.HasPrefix(line, ">") {
 			if name != "" {
-				results = append(
-					results, makeSequence(name, seqBuffer.String()))
+				seq, err := makeSequence(name, seqBuffer.String())
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, seq)
 				seqBuffer.Reset()
 			}
 			seqCount++
@@ -47,7 +81,11 @@ func ReadSequences(reader io.Reader) []Sequence {
 		if name == "" {
 			name = "unnamed sequence"
 		}
-		results = append(results, makeSequence(name, seqBuffer.String()))
+		seq, err := makeSequence(name, seqBuffer.String())
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, seq)
 	}
-	return results
+	return results, nil
 }
@@ -2,6 +2,7 @@ package fastareader
 
 import (
 	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
 	n "github.com/hivdb/nucamino/types/nucleic"
 	"strings"
 	"testing"
@@ -77,3 +78,51 @@ AAAA`)
 		t.Errorf(MSG_NOT_EQUAL, expectName, seq.Name)
 	}
 }
+
+func TestReadProteinSequences(t *testing.T) {
+	reader := strings.NewReader(`
+>TestProtein1
+PQIT LW
+QR*
+>TestProtein2
+KN`)
+	seqs, err := ReadProteinSequences(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectSeq := fmt.Sprintf(
+		"%#v", []a.AminoAcid{a.P, a.Q, a.I, a.T, a.L, a.W, a.Q, a.R})
+	if fmt.Sprintf("%#v", seqs[0].AminoAcids) != expectSeq {
+		t.Errorf(MSG_NOT_EQUAL, expectSeq, seqs[0].AminoAcids)
+	}
+	if seqs[0].Sequence != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, seqs[0].Sequence)
+	}
+	expectName := "TestProtein2"
+	if seqs[1].Name != expectName {
+		t.Errorf(MSG_NOT_EQUAL, expectName, seqs[1].Name)
+	}
+	expectSeq = fmt.Sprintf("%#v", []a.AminoAcid{a.K, a.N})
+	if fmt.Sprintf("%#v", seqs[1].AminoAcids) != expectSeq {
+		t.Errorf(MSG_NOT_EQUAL, expectSeq, seqs[1].AminoAcids)
+	}
+}
+
+func TestReadProteinSequencesUnknownLetters(t *testing.T) {
+	testCases := []struct {
+		text      string
+		expectErr string
+	}{
+		// the letters after X and * would move one position each if
+		// these were left out
+		{">P1\nPQIT\nLWXQR\n>P2\nKN", `Protein sequence "P1": unexpected 'X' at position 7`},
+		{">P1\nKN\n>P2\nPQIT LW\nQ*RK*", `Protein sequence "P2": unexpected '*' at position 8`},
+		{">P1\nPQ-IT", `Protein sequence "P1": unexpected '-' at position 3`},
+	}
+	for _, testCase := range testCases {
+		seqs, err := ReadProteinSequences(strings.NewReader(testCase.text))
+		if seqs != nil || err == nil || err.Error() != testCase.expectErr {
+			t.Errorf(MSG_NOT_EQUAL, testCase.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package fastareader
+
+import (
+	"bufio"
+	"fmt"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Qualities of FASTQ files are written as characters from '!' (0) on
+const phredOffset = '!'
+
+// ReadNucleicAcidSequences reads nucleotide sequences from either a
+// FASTA file or, when the first record starts with '@', a FASTQ file.
+func ReadNucleicAcidSequences(reader io.Reader) ([]Sequence, error) {
+	bufReader := bufio.NewReader(reader)
+	for {
+		char, _, err := bufReader.ReadRune()
+		if err == io.EOF {
+			return []Sequence{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !unicode.IsSpace(char) {
+			bufReader.UnreadRune()
+			if char == '@' {
+				return ReadFastqSequences(bufReader)
+			}
+			return ReadSequences(bufReader), nil
+		}
+	}
+}
+
+// ReadFastqSequences reads the sequences of a FASTQ file along with
+// the Phred qualities (with an offset of 33) of their nucleotides.
+// Sequences and qualities may span several lines.
+func ReadFastqSequences(reader io.Reader) ([]Sequence, error) {
+	var (
+		results  = make([]Sequence, 0, 20)
+		scanner  = bufio.NewScanner(reader)
+		lineNo   = 0
+		seqCount = 0
+	)
+	nextLine := func() (string, bool) {
+		for scanner.Scan() {
+			lineNo++
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				return line, true
+			}
+		}
+		return "", false
+	}
+	for {
+		header, ok := nextLine()
+		if !ok {
+			break
+		}
+		if !strings.HasPrefix(header, "@") {
+			return nil, fmt.Errorf(
+				"Expecting a FASTQ record starting with '@' at line %d", lineNo)
+		}
+		seqCount++
+		name := strings.TrimSpace(strings.TrimPrefix(header, "@"))
+		if name == "" {
+			name = fmt.Sprintf("unnamed sequence %d", seqCount)
+		}
+		var seqText, qualText string
+		for {
+			line, ok := nextLine()
+			if !ok {
+				return nil, fmt.Errorf("Missing the qualities of FASTQ record %v", name)
+			}
+			if strings.HasPrefix(line, "+") {
+				break
+			}
+			seqText += utils.StripWhiteSpace(line)
+		}
+		for len(qualText) < len(seqText) {
+			line, ok := nextLine()
+			if !ok {
+				break
+			}
+			qualText += line
+		}
+		if len(qualText) != len(seqText) {
+			return nil, fmt.Errorf(
+				"FASTQ record %v has %d nucleotides but %d qualities",
+				name, len(seqText), len(qualText))
+		}
+		qualities := make([]int, len(qualText))
+		for idx, char := range qualText {
+			if char < phredOffset || char > '~' {
+				return nil, fmt.Errorf(
+					"Invalid quality '%c' in FASTQ record %v", char, name)
+			}
+			qualities[idx] = int(char - phredOffset)
+		}
+		results = append(results, Sequence{
+			Name:      name,
+			Sequence:  n.ReadString(seqText),
+			Qualities: qualities,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// MaskLowQuality returns a copy of the sequence whose nucleotides with
+// a lower quality than minQuality are N, and tells which nucleotides
+// were so. Sequences without qualities are returned as they are, with
+// a nil mask.
+func (self Sequence) MaskLowQuality(minQuality int) (Sequence, []bool) {
+	if self.Qualities == nil {
+		return self, nil
+	}
+	var (
+		masked = make([]n.NucleicAcid, len(self.Sequence))
+		mask   = make([]bool, len(self.Sequence))
+	)
+	copy(masked, self.Sequence)
+	for idx, quality := range self.Qualities {
+		if quality < minQuality {
+			masked[idx], mask[idx] = n.N, true
+		}
+	}
+	self.Sequence = masked
+	return self, mask
+}
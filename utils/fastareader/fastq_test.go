@@ -0,0 +1,96 @@
+package fastareader
+
+import (
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFastqSequences(t *testing.T) {
+	reader := strings.NewReader(`
+@TestRead1 sample A
+ACGT
+TG
++
+II5+
+!~
+
+@
+ACG
++TestRead2
+@@+
+`)
+	seqs, err := ReadFastqSequences(reader)
+	if err != nil {
+		t.Fatalf(MSG_NOT_EQUAL, nil, err)
+	}
+	expect := []Sequence{
+		{
+			Name:      "TestRead1 sample A",
+			Sequence:  []n.NucleicAcid{n.A, n.C, n.G, n.T, n.T, n.G},
+			Qualities: []int{40, 40, 20, 10, 0, 93},
+		},
+		{
+			Name:      "unnamed sequence 2",
+			Sequence:  []n.NucleicAcid{n.A, n.C, n.G},
+			Qualities: []int{31, 31, 10},
+		},
+	}
+	if !reflect.DeepEqual(seqs, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, seqs)
+	}
+}
+
+func TestReadFastqSequencesInvalid(t *testing.T) {
+	for _, text := range []string{
+		">TestSeq\nACGT\n",
+		"@TestRead\nACGT\n",
+		"@TestRead\nACGT\n+\nIII\n",
+		"@TestRead\nACGT\n+\nII I\n",
+	} {
+		if seqs, err := ReadFastqSequences(strings.NewReader(text)); err == nil {
+			t.Errorf("Expected an error for %q but received %v", text, seqs)
+		}
+	}
+}
+
+func TestReadNucleicAcidSequences(t *testing.T) {
+	seqs, err := ReadNucleicAcidSequences(strings.NewReader("\n  @TestRead\nAC\n+\nII\n"))
+	if err != nil || len(seqs) != 1 || seqs[0].Qualities == nil {
+		t.Errorf("Expected a FASTQ read but received %v, %v", seqs, err)
+	}
+	seqs, err = ReadNucleicAcidSequences(strings.NewReader("\n>TestSeq\nAC\n"))
+	if err != nil || len(seqs) != 1 || seqs[0].Name != "TestSeq" || seqs[0].Qualities != nil {
+		t.Errorf("Expected a FASTA sequence but received %v, %v", seqs, err)
+	}
+	seqs, err = ReadNucleicAcidSequences(strings.NewReader(""))
+	if err != nil || len(seqs) != 0 {
+		t.Errorf("Expected no sequences but received %v, %v", seqs, err)
+	}
+}
+
+func TestMaskLowQuality(t *testing.T) {
+	seq := Sequence{
+		Name:      "TestRead",
+		Sequence:  []n.NucleicAcid{n.A, n.C, n.G, n.T},
+		Qualities: []int{30, 19, 20, 2},
+	}
+	masked, mask := seq.MaskLowQuality(20)
+	expectSeq := []n.NucleicAcid{n.A, n.N, n.G, n.N}
+	if !reflect.DeepEqual(masked.Sequence, expectSeq) {
+		t.Errorf(MSG_NOT_EQUAL, expectSeq, masked.Sequence)
+	}
+	expectMask := []bool{false, true, false, true}
+	if !reflect.DeepEqual(mask, expectMask) {
+		t.Errorf(MSG_NOT_EQUAL, expectMask, mask)
+	}
+	// the original sequence is left untouched
+	if seq.Sequence[1] != n.C {
+		t.Errorf(MSG_NOT_EQUAL, n.C, seq.Sequence[1])
+	}
+	fasta := Sequence{Name: "TestSeq", Sequence: []n.NucleicAcid{n.A}}
+	if masked, mask := fasta.MaskLowQuality(20); mask != nil || !reflect.DeepEqual(masked, fasta) {
+		t.Errorf(MSG_NOT_EQUAL, fasta, masked)
+	}
+}